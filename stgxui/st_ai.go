@@ -0,0 +1,407 @@
+package stgxui
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/google/gxui"
+	"github.com/yofu/st/stlib"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AiParameter holds the coefficients of the seismic shear of Building Standard Law.
+// Ci = Locate * Rt * Ai * Base, T = H * Tfact, Gperiod is Tc of the ground.
+type AiParameter struct {
+	Base    float64
+	Locate  float64
+	Tfact   float64
+	Gperiod float64
+}
+
+func NewAiParameter() *AiParameter {
+	return &AiParameter{
+		Base:    0.2,
+		Locate:  1.0,
+		Tfact:   0.02,
+		Gperiod: 0.6,
+	}
+}
+
+// Set parses "BASE", "LOCATE" (or "Z"), "TFACT" and "GPERIOD" (or "TC") in argdict.
+func (ap *AiParameter) Set(argdict map[string]string) error {
+	for _, key := range []string{"BASE", "C0", "LOCATE", "Z", "TFACT", "GPERIOD", "TC"} {
+		str, ok := argdict[key]
+		if !ok || str == "" {
+			continue
+		}
+		val, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return err
+		}
+		if val <= 0.0 {
+			return errors.New(fmt.Sprintf("Set: %s must be positive", strings.ToLower(key)))
+		}
+		switch key {
+		case "BASE", "C0":
+			ap.Base = val
+		case "LOCATE", "Z":
+			ap.Locate = val
+		case "TFACT":
+			ap.Tfact = val
+		case "GPERIOD", "TC":
+			ap.Gperiod = val
+		}
+	}
+	return nil
+}
+
+// AiParameterOf returns the coefficients stored in frame.Ai.
+func AiParameterOf(frame *st.Frame) *AiParameter {
+	ap := NewAiParameter()
+	if len(frame.Ai.Base) > 0 && frame.Ai.Base[0] > 0.0 {
+		ap.Base = frame.Ai.Base[0]
+	}
+	if frame.Ai.Locate > 0.0 {
+		ap.Locate = frame.Ai.Locate
+	}
+	if frame.Ai.Tfact > 0.0 {
+		ap.Tfact = frame.Ai.Tfact
+	}
+	if frame.Ai.Gperiod > 0.0 {
+		ap.Gperiod = frame.Ai.Gperiod
+	}
+	return ap
+}
+
+// Store writes the coefficients to frame.Ai, which is saved in the .inp
+// and from which st generates the horizontal loads when the arclm frames are extracted again.
+// frame.Ai.Base has the coefficients of X and Y, allocated if the model has none.
+func (ap *AiParameter) Store(frame *st.Frame) {
+	if len(frame.Ai.Base) == 0 {
+		frame.Ai.Base = make([]float64, 2)
+	}
+	for i := range frame.Ai.Base {
+		frame.Ai.Base[i] = ap.Base
+	}
+	frame.Ai.Locate = ap.Locate
+	frame.Ai.Tfact = ap.Tfact
+	frame.Ai.Gperiod = ap.Gperiod
+}
+
+func (ap *AiParameter) String() string {
+	return fmt.Sprintf("C0: %.3f Z: %.3f TFACT: %.3f Tc: %.3f", ap.Base, ap.Locate, ap.Tfact, ap.Gperiod)
+}
+
+// AiFloor is a floor of AiDistribution.
+// Weight is the weight of the floor, Wi is the sum of the weights above the story below it.
+// Qi is the design shear of the story, Hi = Qi - Qi+1 is the horizontal force of the floor.
+type AiFloor struct {
+	Floor  int
+	Level  float64
+	Nodes  []*st.Node
+	Weight float64
+	Wi     float64
+	Alpha  float64
+	Ai     float64
+	Ci     float64
+	Qi     float64
+	Hi     float64
+}
+
+func (af *AiFloor) String() string {
+	return fmt.Sprintf("%5d %8.3f %10.3f %10.3f %6.3f %6.3f %6.3f %10.3f %10.3f", af.Floor, af.Level, af.Weight, af.Wi, af.Alpha, af.Ai, af.Ci, af.Qi, af.Hi)
+}
+
+type AiDistribution struct {
+	Parameter *AiParameter
+	H         float64
+	T         float64
+	Rt        float64
+	Floors    []*AiFloor
+}
+
+// Rt returns the vibration characteristic coefficient of period t on the ground of period tc.
+func Rt(t, tc float64) float64 {
+	switch {
+	case t < tc:
+		return 1.0
+	case t < 2.0*tc:
+		return 1.0 - 0.2*math.Pow(t/tc-1.0, 2.0)
+	default:
+		return 1.6 * tc / t
+	}
+}
+
+// Ai returns the distribution coefficient of the story whose weight ratio to the first story is alpha.
+func Ai(alpha, t float64) float64 {
+	if alpha <= 0.0 {
+		return 0.0
+	}
+	return 1.0 + (1.0/math.Sqrt(alpha)-alpha)*2.0*t/(1.0+3.0*t)
+}
+
+// NewAiDistribution groups nodes into floors by Ai.Boundary and computes the shear of each story with seismic weights (Weight[2]).
+// Nodes below Boundary[1] belong to floor 0, whose weight doesn't make any story shear.
+func NewAiDistribution(frame *st.Frame, ap *AiParameter) (*AiDistribution, error) {
+	nfloor := len(frame.Ai.Boundary) - 1
+	if nfloor < 2 {
+		return nil, errors.New("AiDistribution: Ai.Boundary is not defined")
+	}
+	floors := make([]*AiFloor, nfloor)
+	for i := 0; i < nfloor; i++ {
+		floors[i] = &AiFloor{Floor: i, Nodes: make([]*st.Node, 0)}
+	}
+	for _, n := range sortednodes(frame) {
+		l := level(frame.Ai.Boundary, n.Coord[2])
+		if l < 0 {
+			continue
+		}
+		floors[l].Nodes = append(floors[l].Nodes, n)
+		if len(n.Weight) > 2 {
+			floors[l].Weight += n.Weight[2]
+		}
+	}
+	for _, f := range floors {
+		if len(f.Nodes) == 0 {
+			f.Level = frame.Ai.Boundary[f.Floor]
+			continue
+		}
+		for _, n := range f.Nodes {
+			f.Level += n.Coord[2]
+		}
+		f.Level /= float64(len(f.Nodes))
+	}
+	for i := nfloor - 1; i >= 1; i-- {
+		floors[i].Wi = floors[i].Weight
+		if i < nfloor-1 {
+			floors[i].Wi += floors[i+1].Wi
+		}
+	}
+	if floors[1].Wi <= 0.0 {
+		return nil, errors.New("AiDistribution: no weight above the ground (read .wgt file)")
+	}
+	ad := &AiDistribution{
+		Parameter: ap,
+		H:         floors[nfloor-1].Level - floors[0].Level,
+		Floors:    floors,
+	}
+	ad.T = ad.H * ap.Tfact
+	ad.Rt = Rt(ad.T, ap.Gperiod)
+	for i := 1; i < nfloor; i++ {
+		f := floors[i]
+		f.Alpha = f.Wi / floors[1].Wi
+		f.Ai = Ai(f.Alpha, ad.T)
+		f.Ci = ap.Locate * ad.Rt * f.Ai * ap.Base
+		f.Qi = f.Ci * f.Wi
+	}
+	for i := 1; i < nfloor; i++ {
+		floors[i].Hi = floors[i].Qi
+		if i < nfloor-1 {
+			floors[i].Hi -= floors[i+1].Qi
+		}
+	}
+	return ad, nil
+}
+
+func (ad *AiDistribution) String() string {
+	var otp bytes.Buffer
+	otp.WriteString(fmt.Sprintf("%s H: %.3f T: %.3f Rt: %.3f\n", ad.Parameter, ad.H, ad.T, ad.Rt))
+	otp.WriteString(aiheader())
+	otp.WriteString("\n")
+	for i := len(ad.Floors) - 1; i >= 0; i-- {
+		otp.WriteString(fmt.Sprintf("%s\n", ad.Floors[i]))
+	}
+	return strings.TrimSuffix(otp.String(), "\n")
+}
+
+func aiheader() string {
+	return "FLOOR    LEVEL     WEIGHT         Wi  ALPHA     Ai     Ci         Qi         Hi"
+}
+
+// ApplyLoads sets the horizontal force of each floor to the nodes of the floor in proportion to their weights
+// as the nodal loads of arclm frames of X and Y. The other horizontal loads of the periods are cleared.
+func (ad *AiDistribution) ApplyLoads(frame *st.Frame) error {
+	forces := make(map[int]float64)
+	for _, f := range ad.Floors[1:] {
+		if len(f.Nodes) == 0 {
+			if f.Hi != 0.0 {
+				return errors.New(fmt.Sprintf("ApplyLoads: no node on floor %d", f.Floor))
+			}
+			continue
+		}
+		for _, n := range f.Nodes {
+			if f.Weight > 0.0 {
+				forces[n.Num] = f.Hi * n.Weight[2] / f.Weight
+			} else {
+				forces[n.Num] = f.Hi / float64(len(f.Nodes))
+			}
+		}
+	}
+	for i, per := range []string{"X", "Y"} {
+		af, ok := frame.Arclms[per]
+		if !ok {
+			return errors.New(fmt.Sprintf("ApplyLoads: period %s doesn't exist (:read $data)", per))
+		}
+		for _, n := range af.Nodes {
+			n.Force[i] = forces[n.Num]
+		}
+	}
+	return nil
+}
+
+// ApplyAiLoads stores the boundaries and the coefficients of ad in the model, takes a snapshot for undo
+// and then sets the horizontal forces to the arclm frames of X and Y.
+func (stw *Window) ApplyAiLoads(ad *AiDistribution) error {
+	ad.Parameter.Store(stw.Frame)
+	stw.Snapshot()
+	return ad.ApplyLoads(stw.Frame)
+}
+
+// SetBoundary replaces Ai.Boundary with str such as "-1000,1.0,4.5,8.0,1000".
+func SetBoundary(frame *st.Frame, str string) error {
+	lis := strings.Split(strings.Replace(str, " ", "", -1), ",")
+	bs := make([]float64, len(lis))
+	for i, s := range lis {
+		val, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		bs[i] = val
+	}
+	if len(bs) < 3 {
+		return errors.New("SetBoundary: at least 3 boundaries are needed")
+	}
+	if !sort.Float64sAreSorted(bs) {
+		return errors.New("SetBoundary: boundaries must be in ascending order")
+	}
+	frame.Ai.Boundary = bs
+	frame.Ai.Nfloor = len(bs) - 1
+	return nil
+}
+
+func BoundaryString(frame *st.Frame) string {
+	lis := make([]string, len(frame.Ai.Boundary))
+	for i, b := range frame.Ai.Boundary {
+		lis[i] = strconv.FormatFloat(b, 'f', -1, 64)
+	}
+	return strings.Join(lis, ",")
+}
+
+// ShowAiDistribution opens the panel to edit the boundaries and the coefficients.
+// "Update" recomputes the distribution and "Apply Loads" regenerates the horizontal loads of X and Y.
+func (stw *Window) ShowAiDistribution() {
+	theme := stw.theme
+	frame := stw.Frame
+	adapter := gxui.CreateDefaultAdapter()
+	adapter.SetItemSizeAsLargest(theme)
+	list := theme.CreateList()
+	list.SetAdapter(adapter)
+	list.OnSelectionChanged(func(item gxui.AdapterItem) {
+		if f, ok := item.(*AiFloor); ok && stw.Frame == frame {
+			stw.Deselect()
+			stw.SelectNode = f.Nodes
+			stw.Redraw()
+		}
+	})
+	summary := theme.CreateLabel()
+	header := theme.CreateLabel()
+	header.SetText(aiheader())
+	layout := theme.CreateLinearLayout()
+	layout.SetDirection(gxui.TopToBottom)
+	boxes := make(map[string]gxui.TextBox)
+	for _, key := range []string{"BOUNDARY", "BASE", "LOCATE", "TFACT", "GPERIOD"} {
+		row := theme.CreateLinearLayout()
+		row.SetDirection(gxui.LeftToRight)
+		label := theme.CreateLabel()
+		label.SetText(fmt.Sprintf("%-8s", key))
+		box := theme.CreateTextBox()
+		box.SetDesiredWidth(400)
+		row.AddChild(label)
+		row.AddChild(box)
+		layout.AddChild(row)
+		boxes[key] = box
+	}
+	var ad *AiDistribution
+	fill := func() {
+		boxes["BOUNDARY"].SetText(BoundaryString(frame))
+		boxes["BASE"].SetText(fmt.Sprintf("%g", stw.aiparam.Base))
+		boxes["LOCATE"].SetText(fmt.Sprintf("%g", stw.aiparam.Locate))
+		boxes["TFACT"].SetText(fmt.Sprintf("%g", stw.aiparam.Tfact))
+		boxes["GPERIOD"].SetText(fmt.Sprintf("%g", stw.aiparam.Gperiod))
+	}
+	update := func() error {
+		argdict := make(map[string]string)
+		for _, key := range []string{"BASE", "LOCATE", "TFACT", "GPERIOD"} {
+			argdict[key] = boxes[key].Text()
+		}
+		err := stw.aiparam.Set(argdict)
+		if err != nil {
+			return err
+		}
+		if b := boxes["BOUNDARY"].Text(); b != BoundaryString(frame) {
+			err := SetBoundary(frame, b)
+			if err != nil {
+				return err
+			}
+			stw.Snapshot()
+		}
+		ad, err = NewAiDistribution(frame, stw.aiparam)
+		if err != nil {
+			adapter.SetItems([]*AiFloor{})
+			summary.SetText(err.Error())
+			return err
+		}
+		floors := make([]*AiFloor, len(ad.Floors))
+		for i, f := range ad.Floors {
+			floors[len(ad.Floors)-1-i] = f
+		}
+		adapter.SetItems(floors)
+		summary.SetText(fmt.Sprintf("%s H: %.3f T: %.3f Rt: %.3f", ad.Parameter, ad.H, ad.T, ad.Rt))
+		return nil
+	}
+	buttons := theme.CreateLinearLayout()
+	buttons.SetDirection(gxui.LeftToRight)
+	ubutton := theme.CreateButton()
+	ubutton.SetText("Update")
+	ubutton.OnClick(func(gxui.MouseEvent) {
+		err := update()
+		if err != nil {
+			stw.ErrorMessage(err, ERROR)
+		}
+	})
+	abutton := theme.CreateButton()
+	abutton.SetText("Apply Loads")
+	abutton.OnClick(func(gxui.MouseEvent) {
+		err := update()
+		if err != nil {
+			stw.ErrorMessage(err, ERROR)
+			return
+		}
+		if stw.Frame != frame {
+			stw.ErrorMessage(errors.New("AI: the frame has been changed"), ERROR)
+			return
+		}
+		err = stw.ApplyAiLoads(ad)
+		if err != nil {
+			stw.ErrorMessage(err, ERROR)
+			return
+		}
+		stw.ErrorMessage(errors.New("AI: horizontal loads of X and Y are regenerated"), INFO)
+	})
+	buttons.AddChild(ubutton)
+	buttons.AddChild(abutton)
+	layout.AddChild(buttons)
+	layout.AddChild(summary)
+	layout.AddChild(header)
+	layout.AddChild(list)
+	w := theme.CreateWindow(700, 500, fmt.Sprintf("Ai: %s", frame.Name))
+	w.AddChild(layout)
+	w.OnClose(func() {
+		stw.dlg.SetFocus(stw.cline)
+	})
+	fill()
+	update()
+}
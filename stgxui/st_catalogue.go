@@ -0,0 +1,387 @@
+package stgxui
+
+import (
+	"errors"
+	"fmt"
+	"github.com/yofu/st/stlib"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dimensions of standard shapes in [mm].
+// H: H, B, tw, tf (JIS G 3192), BOX: D, t (square tubes), P: D, t (STK pipes),
+// C: H, B, tw, tf (channels), L: A, B, t (angles).
+var (
+	catalogueH = []string{
+		"100x50x5x7", "125x60x6x8", "150x75x5x7", "175x90x5x8", "198x99x4.5x7", "200x100x5.5x8",
+		"248x124x5x8", "250x125x6x9", "298x149x5.5x8", "300x150x6.5x9", "346x174x6x9", "350x175x7x11",
+		"396x199x7x11", "400x200x8x13", "446x199x8x12", "450x200x9x14", "496x199x9x14", "500x200x10x16",
+		"596x199x10x15", "600x200x11x17", "700x300x13x24", "800x300x14x26", "900x300x16x28",
+		"148x100x6x9", "194x150x6x9", "244x175x7x11", "294x200x8x12", "340x250x9x14", "390x300x10x16",
+		"440x300x11x18", "488x300x11x18", "588x300x12x20",
+		"100x100x6x8", "125x125x6.5x9", "150x150x7x10", "175x175x7.5x11", "200x200x8x12", "250x250x9x14",
+		"300x300x10x15", "350x350x12x19", "400x400x13x21",
+	}
+	catalogueBOX = []string{
+		"100x3.2", "100x4.5", "100x6", "125x4.5", "125x6", "150x4.5", "150x6", "150x9",
+		"200x6", "200x9", "200x12", "250x9", "250x12", "300x9", "300x12", "300x16",
+		"350x12", "350x16", "350x19", "400x12", "400x16", "400x19", "400x22",
+		"450x16", "450x19", "450x22", "500x19", "500x22",
+	}
+	cataloguePIPE = []string{
+		"60.5x3.2", "76.3x3.2", "89.1x3.2", "101.6x3.2", "114.3x3.5", "139.8x4.5", "165.2x5",
+		"190.7x5.3", "216.3x5.8", "267.4x6", "318.5x6.9", "355.6x7.9", "406.4x9", "457.2x9.5", "508x9.5",
+	}
+	catalogueC = []string{
+		"75x40x5x7", "100x50x5x7.5", "125x65x6x8", "150x75x6.5x10", "150x75x9x12.5", "180x75x7x10.5",
+		"200x80x7.5x11", "200x90x8x13.5", "250x90x9x13", "250x90x11x14.5", "300x90x9x13", "300x90x10x15.5",
+		"300x90x12x16", "380x100x10.5x16", "380x100x13x16.5", "380x100x13x20",
+	}
+	catalogueL = []string{
+		"40x40x3", "40x40x5", "45x45x4", "50x50x4", "50x50x6", "60x60x4", "60x60x5", "65x65x6", "65x65x8",
+		"70x70x6", "75x75x6", "75x75x9", "80x80x6", "90x90x7", "90x90x10", "100x100x7", "100x100x10",
+		"100x100x13", "120x120x8", "130x130x9", "130x130x12", "150x150x12", "150x150x15", "175x175x12",
+		"200x200x15", "200x200x20", "250x250x25",
+	}
+	SteelCatalogue = buildCatalogue()
+	SHAPEKINDS     = []string{"H", "BOX", "P", "C", "L"}
+	re_shapename   = regexp.MustCompile("(?i)^(H|BOX|P|C|L)-([0-9.]+(?:[xX*][0-9.]+)*)$")
+	re_shapedim    = regexp.MustCompile("[xX*]")
+)
+
+// CatalogueShape is a standard steel shape of Kind "H", "BOX", "P", "C" or "L".
+type CatalogueShape struct {
+	Kind string
+	Dims []string
+}
+
+func buildCatalogue() []*CatalogueShape {
+	rtn := make([]*CatalogueShape, 0)
+	for _, lis := range []struct {
+		kind string
+		dims []string
+	}{
+		{"H", catalogueH},
+		{"BOX", catalogueBOX},
+		{"P", cataloguePIPE},
+		{"C", catalogueC},
+		{"L", catalogueL},
+	} {
+		for _, d := range lis.dims {
+			rtn = append(rtn, &CatalogueShape{lis.kind, strings.Split(d, "x")})
+		}
+	}
+	return rtn
+}
+
+// Name returns the name such as "H-400x200x8x13", which ParseShapeName accepts.
+func (cs *CatalogueShape) Name() string {
+	return fmt.Sprintf("%s-%s", cs.Kind, strings.Join(cs.Dims, "x"))
+}
+
+func (cs *CatalogueShape) String() string {
+	return cs.Name()
+}
+
+// Args returns the arguments of the constructor of Kind (:hkyou, :rpipe, :cpipe or :ckyou), or nil.
+func (cs *CatalogueShape) Args() []string {
+	switch cs.Kind {
+	case "H", "C":
+		if len(cs.Dims) == 4 {
+			return cs.Dims
+		}
+	case "BOX":
+		if len(cs.Dims) == 2 {
+			return []string{cs.Dims[0], cs.Dims[0], cs.Dims[1], cs.Dims[1]}
+		}
+	case "P":
+		if len(cs.Dims) == 2 {
+			return cs.Dims
+		}
+	case "L":
+		if len(cs.Dims) == 3 {
+			return cs.Dims
+		}
+	}
+	return nil
+}
+
+// Shape creates the shape with the constructors used by :hkyou, :rpipe, :cpipe and :ckyou.
+// st has no constructor for angles, so they are created by NewLKYOU.
+func (cs *CatalogueShape) Shape() (st.Shape, error) {
+	args := cs.Args()
+	if args == nil {
+		return nil, errors.New(fmt.Sprintf("Shape: invalid dimensions %s", cs.Name()))
+	}
+	switch cs.Kind {
+	case "H":
+		return st.NewHKYOU(args)
+	case "BOX":
+		return st.NewRPIPE(args)
+	case "P":
+		return st.NewCPIPE(args)
+	case "C":
+		return st.NewCKYOU(args)
+	case "L":
+		return NewLKYOU(args)
+	}
+	return nil, errors.New(fmt.Sprintf("Shape: %s can't be used as a section", cs.Name()))
+}
+
+// LKYOU is an angle of legs LegA (along y) and LegB (along x) and thickness t [mm] without fillets.
+// Properties are about the axes through the centroid parallel to the legs in [cm], as those of st's shapes.
+type LKYOU struct {
+	LegA, LegB, T float64
+}
+
+// NewLKYOU creates an angle from A, B and t [mm] as st.NewHKYOU does from its arguments.
+func NewLKYOU(lis []string) (*LKYOU, error) {
+	if len(lis) < 3 {
+		return nil, errors.New("NewLKYOU: not enough arguments")
+	}
+	vals := make([]float64, 3)
+	for i := 0; i < 3; i++ {
+		val, err := strconv.ParseFloat(lis[i], 64)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = val
+	}
+	if vals[2] <= 0.0 || vals[2] >= vals[0] || vals[2] >= vals[1] {
+		return nil, errors.New(fmt.Sprintf("NewLKYOU: invalid dimensions %sx%sx%s", lis[0], lis[1], lis[2]))
+	}
+	return &LKYOU{vals[0], vals[1], vals[2]}, nil
+}
+
+func (l *LKYOU) String() string {
+	return fmt.Sprintf("LKYOU %5.1f %5.1f %5.1f", l.LegA, l.LegB, l.T)
+}
+
+func (l *LKYOU) Description() string {
+	return fmt.Sprintf("L-%sx%sx%s", strconv.FormatFloat(l.LegA, 'f', -1, 64), strconv.FormatFloat(l.LegB, 'f', -1, 64), strconv.FormatFloat(l.T, 'f', -1, 64))
+}
+
+// cm returns the dimensions in [cm].
+func (l *LKYOU) cm() (float64, float64, float64) {
+	return l.LegA * 0.1, l.LegB * 0.1, l.T * 0.1
+}
+
+func (l *LKYOU) A() float64 {
+	a, b, t := l.cm()
+	return t * (a + b - t)
+}
+
+// Asx is the area of the leg along x.
+func (l *LKYOU) Asx() float64 {
+	_, b, t := l.cm()
+	return b * t
+}
+
+// Asy is the area of the leg along y.
+func (l *LKYOU) Asy() float64 {
+	a, _, t := l.cm()
+	return a * t
+}
+
+// centroid returns the distances of the centroid from the back of the leg along y and from the back of the leg along x.
+func (l *LKYOU) centroid() (float64, float64) {
+	a, b, t := l.cm()
+	area := l.A()
+	cx := (a*t*t*0.5 + (b-t)*t*(t+(b-t)*0.5)) / area
+	cy := (b*t*t*0.5 + (a-t)*t*(t+(a-t)*0.5)) / area
+	return cx, cy
+}
+
+func (l *LKYOU) Ix() float64 {
+	a, b, t := l.cm()
+	_, cy := l.centroid()
+	return b*t*t*t/12.0 + b*t*math.Pow(t*0.5-cy, 2) + t*math.Pow(a-t, 3)/12.0 + t*(a-t)*math.Pow(t+(a-t)*0.5-cy, 2)
+}
+
+func (l *LKYOU) Iy() float64 {
+	a, b, t := l.cm()
+	cx, _ := l.centroid()
+	return a*t*t*t/12.0 + a*t*math.Pow(t*0.5-cx, 2) + t*math.Pow(b-t, 3)/12.0 + t*(b-t)*math.Pow(t+(b-t)*0.5-cx, 2)
+}
+
+// J is the torsional constant of thin plates.
+func (l *LKYOU) J() float64 {
+	a, b, t := l.cm()
+	return (a + b - t) * t * t * t / 3.0
+}
+
+func (l *LKYOU) Zx() float64 {
+	a, _, _ := l.cm()
+	_, cy := l.centroid()
+	return l.Ix() / math.Max(cy, a-cy)
+}
+
+func (l *LKYOU) Zy() float64 {
+	_, b, _ := l.cm()
+	cx, _ := l.centroid()
+	return l.Iy() / math.Max(cx, b-cx)
+}
+
+// ParseShapeName parses a name such as "H-400x200x8x13", "BOX-200x9" or "P-216.3x5.8".
+// The shape need not be in SteelCatalogue.
+func ParseShapeName(name string) (*CatalogueShape, error) {
+	fs := re_shapename.FindStringSubmatch(strings.TrimSpace(name))
+	if fs == nil {
+		return nil, errors.New(fmt.Sprintf("ParseShapeName: invalid name %s", name))
+	}
+	dims := re_shapedim.Split(fs[2], -1)
+	for _, d := range dims {
+		if _, err := strconv.ParseFloat(d, 64); err != nil {
+			return nil, err
+		}
+	}
+	cs := &CatalogueShape{strings.ToUpper(fs[1]), dims}
+	if cs.Args() == nil {
+		return nil, errors.New(fmt.Sprintf("ParseShapeName: invalid dimensions %s", name))
+	}
+	return cs, nil
+}
+
+type catalogueMatch struct {
+	shape *CatalogueShape
+	score int
+}
+
+type catalogueMatches []*catalogueMatch
+
+func (cm catalogueMatches) Len() int           { return len(cm) }
+func (cm catalogueMatches) Swap(i, j int)      { cm[i], cm[j] = cm[j], cm[i] }
+func (cm catalogueMatches) Less(i, j int) bool { return cm[i].score < cm[j].score }
+
+// matchDims reports whether the dimensions in word equal the leading dimensions of cs.
+func (cs *CatalogueShape) matchDims(word string) bool {
+	ds := re_shapedim.Split(word, -1)
+	if len(ds) > len(cs.Dims) {
+		return false
+	}
+	for i, d := range ds {
+		v1, err := strconv.ParseFloat(d, 64)
+		if err != nil {
+			return false
+		}
+		v2, err := strconv.ParseFloat(cs.Dims[i], 64)
+		if err != nil || v1 != v2 {
+			return false
+		}
+	}
+	return true
+}
+
+func subsequence(word, str string) bool {
+	i := 0
+	for _, c := range str {
+		if i < len(word) && rune(word[i]) == c {
+			i++
+		}
+	}
+	return i == len(word)
+}
+
+// SearchCatalogue returns the shapes of kind ("" for all kinds) matching word, best first.
+// A kind prefix in word such as "H-" or "BOX" restricts the kind.
+// Shapes whose leading dimensions are those of word ("400x200" finds H-400x200x8x13) come first,
+// then the names containing word, then the names containing the characters of word in order.
+func SearchCatalogue(kind, word string) []*CatalogueShape {
+	word = strings.ToUpper(strings.Replace(strings.TrimSpace(word), " ", "", -1))
+	word = strings.Replace(word, "*", "X", -1)
+	for _, k := range SHAPEKINDS {
+		if strings.HasPrefix(word, k) && (len(word) == len(k) || !strings.ContainsAny(word[len(k):len(k)+1], "ABCDEFGHIJKLMNOPQRSTUVWXYZ")) {
+			kind = k
+			word = strings.TrimPrefix(strings.TrimPrefix(word, k), "-")
+			break
+		}
+	}
+	matches := make(catalogueMatches, 0)
+	for _, cs := range SteelCatalogue {
+		if kind != "" && cs.Kind != kind {
+			continue
+		}
+		dims := strings.ToUpper(strings.Join(cs.Dims, "x"))
+		switch {
+		case word == "":
+			matches = append(matches, &catalogueMatch{cs, 0})
+		case cs.matchDims(word):
+			matches = append(matches, &catalogueMatch{cs, 0})
+		case strings.Contains(dims, word):
+			matches = append(matches, &catalogueMatch{cs, 1})
+		case subsequence(word, dims):
+			matches = append(matches, &catalogueMatch{cs, 2})
+		}
+	}
+	sort.Stable(matches)
+	rtn := make([]*CatalogueShape, len(matches))
+	for i, m := range matches {
+		rtn[i] = m.shape
+	}
+	return rtn
+}
+
+// LookupShape returns the shape of kind named by word: a full name such as "H-400x200x8x13"
+// or the leading dimensions such as "400x200". When several shapes have the dimensions the first is returned with all of them.
+func LookupShape(kind, word string) (*CatalogueShape, []*CatalogueShape, error) {
+	if cs, err := ParseShapeName(word); err == nil {
+		if kind != "" && cs.Kind != kind {
+			return nil, nil, errors.New(fmt.Sprintf("LookupShape: %s is not %s", word, kind))
+		}
+		return cs, []*CatalogueShape{cs}, nil
+	}
+	if kind != "" {
+		if cs, err := ParseShapeName(fmt.Sprintf("%s-%s", kind, word)); err == nil {
+			return cs, []*CatalogueShape{cs}, nil
+		}
+	}
+	candidates := make([]*CatalogueShape, 0)
+	for _, cs := range SearchCatalogue(kind, word) {
+		if cs.matchDims(strings.Replace(strings.ToUpper(word), "*", "X", -1)) {
+			candidates = append(candidates, cs)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil, errors.New(fmt.Sprintf("LookupShape: %s %s is not in the catalogue", kind, word))
+	}
+	return candidates[0], candidates, nil
+}
+
+func catalogueNames(shapes []*CatalogueShape) string {
+	names := make([]string, len(shapes))
+	for i, cs := range shapes {
+		names[i] = cs.Name()
+	}
+	return strings.Join(names, " ")
+}
+
+// lookupShapeArgs replaces args[1] such as "400x200" with the dimensions of the catalogue.
+func (stw *Window) lookupShapeArgs(kind string, args []string) ([]string, error) {
+	cs, candidates, err := LookupShape(kind, args[1])
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) > 1 {
+		stw.History(fmt.Sprintf("CANDIDATES: %s", catalogueNames(candidates)))
+	}
+	stw.History(fmt.Sprintf("SHAPE: %s", cs.Name()))
+	return append([]string{args[0]}, cs.Args()...), nil
+}
+
+// SetSectShape sets sh to the first figure of sec, creating it with prop 101 (or the default prop) if sec has no area.
+func SetSectShape(frame *st.Frame, sec *st.Sect, sh st.Shape) {
+	if len(sec.Figs) == 0 || !sec.HasArea(0) {
+		f := st.NewFig()
+		if p, ok := frame.Props[101]; ok {
+			f.Prop = p
+		} else {
+			f.Prop = frame.DefaultProp()
+		}
+		sec.Figs = []*st.Fig{f}
+	}
+	sec.Figs[0].SetShapeProperty(sh)
+	sec.Name = sh.Description()
+}
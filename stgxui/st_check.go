@@ -0,0 +1,293 @@
+package stgxui
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/google/gxui"
+	"github.com/yofu/st/stlib"
+	"math"
+	"sort"
+)
+
+// Finding is a problem found by CheckFrame with the entities concerned.
+type Finding struct {
+	Kind    string
+	Message string
+	Nodes   []*st.Node
+	Elems   []*st.Elem
+}
+
+func (f *Finding) String() string {
+	return fmt.Sprintf("%s: %s", f.Kind, f.Message)
+}
+
+type findingsByKind []*Finding
+
+func (f findingsByKind) Len() int           { return len(f) }
+func (f findingsByKind) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f findingsByKind) Less(i, j int) bool { return f[i].Kind < f[j].Kind }
+
+func nodefinding(kind string, n *st.Node, format string, a ...interface{}) *Finding {
+	return &Finding{
+		Kind:    kind,
+		Message: fmt.Sprintf(format, a...),
+		Nodes:   []*st.Node{n},
+		Elems:   make([]*st.Elem, 0),
+	}
+}
+
+func elemfinding(kind string, el *st.Elem, format string, a ...interface{}) *Finding {
+	return &Finding{
+		Kind:    kind,
+		Message: fmt.Sprintf(format, a...),
+		Nodes:   make([]*st.Node, 0),
+		Elems:   []*st.Elem{el},
+	}
+}
+
+func sortednodes(frame *st.Frame) []*st.Node {
+	nodes := make([]*st.Node, len(frame.Nodes))
+	i := 0
+	for _, n := range frame.Nodes {
+		nodes[i] = n
+		i++
+	}
+	sort.Sort(st.NodeByNum{nodes})
+	return nodes
+}
+
+func sortedelems(frame *st.Frame) []*st.Elem {
+	els := make([]*st.Elem, len(frame.Elems))
+	i := 0
+	for _, el := range frame.Elems {
+		els[i] = el
+		i++
+	}
+	sort.Sort(st.ElemByNum{els})
+	return els
+}
+
+func distance(n1, n2 *st.Node) float64 {
+	var sum float64
+	for i := 0; i < 3; i++ {
+		sum += (n2.Coord[i] - n1.Coord[i]) * (n2.Coord[i] - n1.Coord[i])
+	}
+	return math.Sqrt(sum)
+}
+
+// planedistance returns the distance between n and the plane through n1, n2 and n3.
+func planedistance(n, n1, n2, n3 *st.Node) float64 {
+	v1 := make([]float64, 3)
+	v2 := make([]float64, 3)
+	v := make([]float64, 3)
+	for i := 0; i < 3; i++ {
+		v1[i] = n2.Coord[i] - n1.Coord[i]
+		v2[i] = n3.Coord[i] - n1.Coord[i]
+		v[i] = n.Coord[i] - n1.Coord[i]
+	}
+	nv := []float64{v1[1]*v2[2] - v1[2]*v2[1], v1[2]*v2[0] - v1[0]*v2[2], v1[0]*v2[1] - v1[1]*v2[0]}
+	l := math.Sqrt(nv[0]*nv[0] + nv[1]*nv[1] + nv[2]*nv[2])
+	if l == 0.0 {
+		return 0.0
+	}
+	return math.Abs(nv[0]*v[0]+nv[1]*v[1]+nv[2]*v[2]) / l
+}
+
+// CheckFrame validates the model and returns the findings sorted by kind.
+func CheckFrame(frame *st.Frame) []*Finding {
+	rtn := make([]*Finding, 0)
+	nodes := sortednodes(frame)
+	els := sortedelems(frame)
+	for _, n := range frame.NodeNoReference() {
+		rtn = append(rtn, nodefinding("UNCONNECTED", n, "NODE %d", n.Num))
+	}
+	for _, el := range els {
+		if el.Sect == nil {
+			rtn = append(rtn, elemfinding("NOSECT", el, "ELEM %d has no sect", el.Num))
+			continue
+		}
+		if _, ok := frame.Sects[el.Sect.Num]; !ok {
+			rtn = append(rtn, elemfinding("NOSECT", el, "ELEM %d: SECT %d doesn't exist", el.Num, el.Sect.Num))
+		}
+		if el.IsLineElem() {
+			if distance(el.Enod[0], el.Enod[1]) <= EPS {
+				rtn = append(rtn, elemfinding("ZEROLENGTH", el, "ELEM %d: NODE %d - %d", el.Num, el.Enod[0].Num, el.Enod[1].Num))
+			}
+			if el.Bonds[3] && el.Bonds[9] {
+				rtn = append(rtn, elemfinding("UNSTABLE", el, "ELEM %d: torsion released at both ends", el.Num))
+			}
+		} else if el.Enods >= 4 {
+			if d := planedistance(el.Enod[3], el.Enod[0], el.Enod[1], el.Enod[2]); d > EPS {
+				rtn = append(rtn, elemfinding("NONPLANAR", el, "ELEM %d: %.3f", el.Num, d))
+			}
+		}
+	}
+	dups := frame.ElemDuplication(nil)
+	if len(dups) > 0 {
+		dupels := make([]*st.Elem, 0)
+		for el := range dups {
+			dupels = append(dupels, el)
+		}
+		sort.Sort(st.ElemByNum{dupels})
+		for _, el := range dupels {
+			rtn = append(rtn, elemfinding("DUPLICATED", el, "ELEM %d", el.Num))
+		}
+	}
+	if len(nodes) > 0 {
+		minz := nodes[0].Coord[2]
+		for _, n := range nodes {
+			if n.Coord[2] < minz {
+				minz = n.Coord[2]
+			}
+		}
+	groundloop:
+		for _, n := range nodes {
+			if math.Abs(n.Coord[2]-minz) > EPS {
+				continue
+			}
+			for i := 0; i < 6; i++ {
+				if n.Conf[i] {
+					continue groundloop
+				}
+			}
+			rtn = append(rtn, nodefinding("NOCONF", n, "NODE %d at ground level is free", n.Num))
+		}
+	}
+	noallow := make(map[int][]*st.Elem)
+	for _, el := range els {
+		if el.Sect == nil {
+			continue
+		}
+		switch el.Etype {
+		case st.COLUMN, st.GIRDER, st.BRACE, st.WALL:
+			if _, ok := frame.Allows[el.Sect.Num]; !ok {
+				noallow[el.Sect.Num] = append(noallow[el.Sect.Num], el)
+			}
+		}
+	}
+	snums := make([]int, 0)
+	for snum := range noallow {
+		snums = append(snums, snum)
+	}
+	sort.Ints(snums)
+	for _, snum := range snums {
+		rtn = append(rtn, &Finding{
+			Kind:    "NOALLOW",
+			Message: fmt.Sprintf("SECT %d (%d elems)", snum, len(noallow[snum])),
+			Nodes:   make([]*st.Node, 0),
+			Elems:   noallow[snum],
+		})
+	}
+	for _, n := range nodes {
+		conn := frame.SearchElem(n)
+		if len(conn) == 0 {
+			continue
+		}
+		for j := 4; j < 6; j++ {
+			if n.Conf[j] {
+				continue
+			}
+			released := true
+			for _, el := range conn {
+				if !el.IsLineElem() {
+					released = false
+					break
+				}
+				for i := 0; i < 2; i++ {
+					if el.Enod[i] == n && !el.Bonds[6*i+j] {
+						released = false
+						break
+					}
+				}
+				if !released {
+					break
+				}
+			}
+			if released {
+				rtn = append(rtn, &Finding{
+					Kind:    "UNSTABLE",
+					Message: fmt.Sprintf("NODE %d: all elems pinned (bond %d)", n.Num, j),
+					Nodes:   []*st.Node{n},
+					Elems:   conn,
+				})
+				break
+			}
+		}
+	}
+	sort.Stable(findingsByKind(rtn))
+	return rtn
+}
+
+// FindingsString returns the number of findings of each kind.
+// The findings themselves are listed by ShowFindings, so that a large model doesn't flood the history.
+func FindingsString(findings []*Finding) string {
+	if len(findings) == 0 {
+		return "NO PROBLEM"
+	}
+	var otp bytes.Buffer
+	count := make(map[string]int)
+	for _, f := range findings {
+		count[f.Kind]++
+	}
+	kinds := make([]string, 0)
+	for k := range count {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	otp.WriteString(fmt.Sprintf("%d PROBLEMS:", len(findings)))
+	for _, k := range kinds {
+		otp.WriteString(fmt.Sprintf(" %s=%d", k, count[k]))
+	}
+	return otp.String()
+}
+
+// FindingsEntities returns the nodes and the elems of findings without duplication.
+func FindingsEntities(findings []*Finding) ([]*st.Node, []*st.Elem) {
+	nodes := make([]*st.Node, 0)
+	els := make([]*st.Elem, 0)
+	nmap := make(map[*st.Node]bool)
+	emap := make(map[*st.Elem]bool)
+	for _, f := range findings {
+		for _, n := range f.Nodes {
+			if !nmap[n] {
+				nmap[n] = true
+				nodes = append(nodes, n)
+			}
+		}
+		for _, el := range f.Elems {
+			if !emap[el] {
+				emap[el] = true
+				els = append(els, el)
+			}
+		}
+	}
+	return nodes, els
+}
+
+func (stw *Window) SelectFinding(f *Finding) {
+	stw.Deselect()
+	stw.SelectNode = append(stw.SelectNode, f.Nodes...)
+	stw.SelectElem = append(stw.SelectElem, f.Elems...)
+	stw.Redraw()
+}
+
+// ShowFindings opens a list of findings. Choosing an item selects its entities.
+func (stw *Window) ShowFindings(title string, findings []*Finding) {
+	if len(findings) == 0 {
+		return
+	}
+	adapter := gxui.CreateDefaultAdapter()
+	adapter.SetItems(findings)
+	list := stw.theme.CreateList()
+	list.SetAdapter(adapter)
+	list.OnSelectionChanged(func(item gxui.AdapterItem) {
+		if f, ok := item.(*Finding); ok {
+			stw.SelectFinding(f)
+		}
+	})
+	w := stw.theme.CreateWindow(500, 400, title)
+	w.AddChild(list)
+	w.OnClose(func() {
+		stw.dlg.SetFocus(stw.cline)
+	})
+}
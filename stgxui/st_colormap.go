@@ -0,0 +1,331 @@
+package stgxui
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/gxui"
+	"github.com/yofu/st/stlib"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ECOLOR_MAP follows the color modes of st and colors elems by Window.colormap.
+	ECOLOR_MAP = uint(len(st.ECOLORS))
+	COLORNAMES = map[string]int{
+		"WHITE":   0xFFFFFF,
+		"BLACK":   0x000000,
+		"GREY":    0x9E9E9E,
+		"GRAY":    0x9E9E9E,
+		"RED":     0xF44336,
+		"ORANGE":  0xFF9800,
+		"YELLOW":  0xFFEB3B,
+		"GREEN":   0x4CAF50,
+		"CYAN":    0x00BCD4,
+		"BLUE":    0x2196F3,
+		"PURPLE":  0x9C27B0,
+		"MAGENTA": 0xE91E63,
+	}
+	COLORSCALARS = []string{"RATE", "N", "QX", "QY", "MT", "MX", "MY", "DISP", "AREA"}
+	// Boundaries of presets are in [0, 1] and scaled to the range of the map.
+	COLORMAPPRESETS = map[string]*ColorMap{
+		"TRAFFIC": &ColorMap{
+			Boundaries: []float64{0.8, 1.0},
+			Colors:     []int{0x4CAF50, 0xFFEB3B, 0xF44336},
+		},
+		"VIRIDIS": &ColorMap{
+			Boundaries: []float64{0.0, 0.25, 0.5, 0.75, 1.0},
+			Colors:     []int{0x440154, 0x3B528B, 0x21908C, 0x5DC863, 0xFDE725},
+			Continuous: true,
+		},
+		"CIVIDIS": &ColorMap{
+			Boundaries: []float64{0.0, 0.25, 0.5, 0.75, 1.0},
+			Colors:     []int{0x00204D, 0x414D6B, 0x7C7B78, 0xBCAF6F, 0xFFEA46},
+			Continuous: true,
+		},
+		"OKABEITO": &ColorMap{
+			Boundaries: []float64{0.5, 0.8, 1.0},
+			Colors:     []int{0x0072B2, 0x56B4E9, 0xE69F00, 0xD55E00},
+		},
+		"BLUERED": &ColorMap{
+			Boundaries: []float64{0.0, 0.5, 1.0},
+			Colors:     []int{0x2166AC, 0xF7F7F7, 0xB2182B},
+			Continuous: true,
+		},
+	}
+)
+
+// ColorMap colors elems by a scalar.
+// A stepped map uses Colors[i] below Boundaries[i] and the last color above all of them (len(Colors) = len(Boundaries)+1).
+// A continuous map interpolates Colors placed at Boundaries (len(Colors) = len(Boundaries)).
+// An Auto map keeps the boundaries of its preset in [0, 1] in Fractions and is scaled to ScalarRange by Resolve.
+type ColorMap struct {
+	Name       string
+	Scalar     string
+	Boundaries []float64
+	Colors     []int
+	Continuous bool
+	Auto       bool
+	Fractions  []float64
+}
+
+func (cm *ColorMap) check() error {
+	if !sort.Float64sAreSorted(cm.Boundaries) {
+		return errors.New("ColorMap: boundaries must be in ascending order")
+	}
+	if cm.Continuous {
+		if len(cm.Colors) != len(cm.Boundaries) || len(cm.Colors) < 2 {
+			return errors.New(fmt.Sprintf("ColorMap: a continuous map needs as many colors as boundaries (%d colors, %d boundaries)", len(cm.Colors), len(cm.Boundaries)))
+		}
+	} else if len(cm.Colors) != len(cm.Boundaries)+1 {
+		return errors.New(fmt.Sprintf("ColorMap: a stepped map needs one more color than boundaries (%d colors, %d boundaries)", len(cm.Colors), len(cm.Boundaries)))
+	}
+	if cm.Auto && len(cm.Fractions) != len(cm.Boundaries) {
+		return errors.New(fmt.Sprintf("ColorMap: an auto map needs as many fractions as boundaries (%d fractions, %d boundaries)", len(cm.Fractions), len(cm.Boundaries)))
+	}
+	return nil
+}
+
+// AutoScale returns a copy of cm (a preset) whose range follows the values of the visible elems at each redraw.
+func (cm *ColorMap) AutoScale() *ColorMap {
+	rtn := cm.Scale(0.0, 1.0)
+	rtn.Auto = true
+	rtn.Fractions = cm.Boundaries
+	return rtn
+}
+
+// Resolve scales the boundaries of an Auto map to the current range of its scalar in frame.
+// The range depends on the period and the results, so it is called when the frame is drawn.
+func (cm *ColorMap) Resolve(frame *st.Frame) {
+	if !cm.Auto {
+		return
+	}
+	min, max, err := ScalarRange(frame, cm.Scalar)
+	if err != nil {
+		min, max = 0.0, 1.0
+	}
+	for i, b := range cm.Fractions {
+		cm.Boundaries[i] = min + b*(max-min)
+	}
+}
+
+// Scale returns a copy of cm whose boundaries in [0, 1] are mapped to [min, max].
+func (cm *ColorMap) Scale(min, max float64) *ColorMap {
+	rtn := &ColorMap{
+		Name:       cm.Name,
+		Scalar:     cm.Scalar,
+		Boundaries: make([]float64, len(cm.Boundaries)),
+		Colors:     cm.Colors,
+		Continuous: cm.Continuous,
+	}
+	for i, b := range cm.Boundaries {
+		rtn.Boundaries[i] = min + b*(max-min)
+	}
+	return rtn
+}
+
+func interpolateColor(c1, c2 int, t float64) int {
+	rtn := 0
+	for _, shift := range []uint{16, 8, 0} {
+		v1 := float64((c1 >> shift) & 0xFF)
+		v2 := float64((c2 >> shift) & 0xFF)
+		rtn |= int(v1+(v2-v1)*t+0.5) << shift
+	}
+	return rtn
+}
+
+func (cm *ColorMap) Color(val float64) int {
+	if !cm.Continuous {
+		for i, b := range cm.Boundaries {
+			if val < b {
+				return cm.Colors[i]
+			}
+		}
+		return cm.Colors[len(cm.Colors)-1]
+	}
+	l := len(cm.Boundaries)
+	if val <= cm.Boundaries[0] {
+		return cm.Colors[0]
+	}
+	for i := 1; i < l; i++ {
+		if val <= cm.Boundaries[i] {
+			t := (val - cm.Boundaries[i-1]) / (cm.Boundaries[i] - cm.Boundaries[i-1])
+			return interpolateColor(cm.Colors[i-1], cm.Colors[i], t)
+		}
+	}
+	return cm.Colors[l-1]
+}
+
+func (cm *ColorMap) String() string {
+	bs := make([]string, len(cm.Boundaries))
+	for i, b := range cm.Boundaries {
+		bs[i] = strconv.FormatFloat(b, 'g', 4, 64)
+	}
+	cs := make([]string, len(cm.Colors))
+	for i, c := range cm.Colors {
+		cs[i] = fmt.Sprintf("#%06X", c)
+	}
+	kind := "STEPPED"
+	if cm.Continuous {
+		kind = "CONTINUOUS"
+	}
+	if cm.Auto {
+		kind += " AUTO"
+	}
+	return fmt.Sprintf("%s %s %s BOUNDARIES: %s COLORS: %s", cm.Name, cm.Scalar, kind, strings.Join(bs, ","), strings.Join(cs, ","))
+}
+
+// ParseColor parses a name in COLORNAMES, "#RRGGBB" or "0xRRGGBB".
+func ParseColor(str string) (int, error) {
+	if c, ok := COLORNAMES[strings.ToUpper(str)]; ok {
+		return c, nil
+	}
+	tmp, err := strconv.ParseInt(strings.Replace(str, "#", "0x", 1), 0, 64)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("ParseColor: unknown color %s", str))
+	}
+	return int(tmp), nil
+}
+
+// ParseColorMap parses boundaries "0.8,1.0" and colors "green,yellow,red".
+func ParseColorMap(boundaries, colors string, continuous bool) (*ColorMap, error) {
+	cm := &ColorMap{
+		Name:       "CUSTOM",
+		Continuous: continuous,
+	}
+	for _, b := range strings.Split(boundaries, ",") {
+		val, err := strconv.ParseFloat(b, 64)
+		if err != nil {
+			return nil, err
+		}
+		cm.Boundaries = append(cm.Boundaries, val)
+	}
+	for _, c := range strings.Split(colors, ",") {
+		val, err := ParseColor(c)
+		if err != nil {
+			return nil, err
+		}
+		cm.Colors = append(cm.Colors, val)
+	}
+	err := cm.check()
+	if err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// ElemScalar returns the value of el colored by a map: the rate, the stress of the current period
+// (N of end 0, the larger absolute value of the two ends for the others), the largest displacement of the enods or the area of the sect.
+func ElemScalar(el *st.Elem, scalar string, show *st.Show) (float64, error) {
+	switch scalar {
+	case "RATE":
+		return el.RateMax(show)
+	case "N":
+		return el.N(show.Period, 0), nil
+	case "QX", "QY", "MT", "MX", "MY":
+		if !el.IsLineElem() {
+			return 0.0, errors.New("ElemScalar: not a line elem")
+		}
+		if _, ok := el.Stress[show.Period]; !ok {
+			return 0.0, errors.New(fmt.Sprintf("ElemScalar: no stress of %s", show.Period))
+		}
+		ind := 0
+		for i, s := range []string{"QX", "QY", "MT", "MX", "MY"} {
+			if s == scalar {
+				ind = i + 1
+			}
+		}
+		return math.Max(math.Abs(el.ReturnStress(show.Period, 0, ind)), math.Abs(el.ReturnStress(show.Period, 1, ind))), nil
+	case "DISP":
+		rtn := 0.0
+		for _, n := range el.Enod[:el.Enods] {
+			d := 0.0
+			for i := 0; i < 3; i++ {
+				d += math.Pow(n.ReturnDisp(show.Period, i), 2.0)
+			}
+			rtn = math.Max(rtn, math.Sqrt(d))
+		}
+		return rtn, nil
+	case "AREA":
+		return el.Sect.Area(0)
+	}
+	return 0.0, errors.New(fmt.Sprintf("ElemScalar: unknown scalar %s", scalar))
+}
+
+// ScalarRange returns the minimum and maximum of scalar of the visible elems.
+func ScalarRange(frame *st.Frame, scalar string) (float64, float64, error) {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, el := range frame.Elems {
+		if el.IsHidden(frame.Show) {
+			continue
+		}
+		val, err := ElemScalar(el, scalar, frame.Show)
+		if err != nil {
+			continue
+		}
+		min = math.Min(min, val)
+		max = math.Max(max, val)
+	}
+	if min > max {
+		return 0.0, 0.0, errors.New(fmt.Sprintf("ScalarRange: no elem has %s", scalar))
+	}
+	if min == max {
+		max = min + 1.0
+	}
+	return min, max, nil
+}
+
+// ColorMapPenBrush returns the pen and brush of el colored by the colormap of the window.
+func (stw *Window) ColorMapPenBrush(el *st.Elem, selected bool) (gxui.Pen, gxui.Brush) {
+	if stw.colormap == nil {
+		return gxui.WhitePen, gxui.WhiteBrush
+	}
+	val, err := ElemScalar(el, stw.colormap.Scalar, stw.Frame.Show)
+	if err != nil {
+		return Pen(st.GREY_500, selected), Brush(st.GREY_500, selected)
+	}
+	c := stw.colormap.Color(val)
+	return Pen(c, selected), Brush(c, selected)
+}
+
+func IntColor(col int) gxui.Color {
+	c := IntColorFloat32(col)
+	return gxui.Color{c[0], c[1], c[2], 1.0}
+}
+
+// DrawColorMapLegend draws the colors and the boundaries of the colormap at Show.LegendPosition.
+func (stw *Window) DrawColorMapLegend(canvas gxui.Canvas, font gxui.Font) {
+	cm := stw.colormap
+	if cm == nil || stw.Frame.Show.NoLegend {
+		return
+	}
+	x, y := stw.Frame.Show.LegendPosition[0], stw.Frame.Show.LegendPosition[1]
+	if x == 0 && y == 0 {
+		x, y = 20, 40
+	}
+	size := 15
+	Text(canvas, font, gxui.White, x, y, cm.Scalar)
+	y += 5
+	label := func(i int) string {
+		return strconv.FormatFloat(cm.Boundaries[i], 'g', 4, 64)
+	}
+	for i, c := range cm.Colors {
+		vs := [][]int{{x, y}, {x + size, y}, {x + size, y + size}, {x, y + size}}
+		Polygon(canvas, gxui.CreatePen(1.0, IntColor(c)), gxui.CreateBrush(IntColor(c)), vs)
+		var txt string
+		switch {
+		case cm.Continuous:
+			txt = label(i)
+		case i == 0:
+			txt = fmt.Sprintf("< %s", label(0))
+		case i == len(cm.Colors)-1:
+			txt = fmt.Sprintf(">= %s", label(i-1))
+		default:
+			txt = fmt.Sprintf("%s - %s", label(i-1), label(i))
+		}
+		Text(canvas, font, gxui.White, x+size+5, y+size, txt)
+		y += size + 2
+	}
+}
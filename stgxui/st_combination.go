@@ -0,0 +1,370 @@
+package stgxui
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/yofu/st/stlib"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	re_combterm  = regexp.MustCompile("^([+-]?)([0-9]*[.]?[0-9]*)[*]?([A-Z][A-Z0-9@]*)$")
+	re_combsplit = regexp.MustCompile("[+-]?[^+-]+")
+)
+
+// LoadCombination is a virtual period whose results are the linear combination of other periods.
+type LoadCombination struct {
+	Name    string
+	Periods []string
+	Factors []float64
+}
+
+// Envelope is a pair of virtual periods NAMEMAX and NAMEMIN holding the extremes of periods.
+type Envelope struct {
+	Name    string
+	Periods []string
+}
+
+// ParseLoadCombination parses "NAME=L+0.5X-0.3Y" or "L+X" (named after the expression).
+func ParseLoadCombination(str string) (*LoadCombination, error) {
+	str = strings.ToUpper(strings.Replace(str, " ", "", -1))
+	name := str
+	expr := str
+	if i := strings.Index(str, "="); i >= 0 {
+		name = str[:i]
+		expr = str[i+1:]
+	}
+	if name == "" || expr == "" {
+		return nil, errors.New(fmt.Sprintf("ParseLoadCombination: invalid combination %s", str))
+	}
+	lc := &LoadCombination{
+		Name:    name,
+		Periods: make([]string, 0),
+		Factors: make([]float64, 0),
+	}
+	for _, term := range re_combsplit.FindAllString(expr, -1) {
+		fs := re_combterm.FindStringSubmatch(term)
+		if fs == nil {
+			return nil, errors.New(fmt.Sprintf("ParseLoadCombination: invalid term %s", term))
+		}
+		val := 1.0
+		if fs[2] != "" {
+			tmp, err := strconv.ParseFloat(fs[2], 64)
+			if err != nil {
+				return nil, err
+			}
+			val = tmp
+		}
+		if fs[1] == "-" {
+			val = -val
+		}
+		lc.Periods = append(lc.Periods, fs[3])
+		lc.Factors = append(lc.Factors, val)
+	}
+	if len(lc.Periods) == 0 {
+		return nil, errors.New(fmt.Sprintf("ParseLoadCombination: no period in %s", str))
+	}
+	return lc, nil
+}
+
+func (lc *LoadCombination) String() string {
+	var otp bytes.Buffer
+	for i, per := range lc.Periods {
+		switch {
+		case lc.Factors[i] == 1.0:
+			if i > 0 {
+				otp.WriteString("+")
+			}
+		case lc.Factors[i] == -1.0:
+			otp.WriteString("-")
+		case lc.Factors[i] >= 0.0 && i > 0:
+			otp.WriteString(fmt.Sprintf("+%g", lc.Factors[i]))
+		default:
+			otp.WriteString(fmt.Sprintf("%g", lc.Factors[i]))
+		}
+		otp.WriteString(per)
+	}
+	if otp.String() == lc.Name {
+		return lc.Name
+	}
+	return fmt.Sprintf("%s=%s", lc.Name, otp.String())
+}
+
+func combine(vals [][]float64, factors []float64) []float64 {
+	if len(vals) == 0 {
+		return nil
+	}
+	rtn := make([]float64, len(vals[0]))
+	for i, v := range vals {
+		for j := 0; j < len(rtn) && j < len(v); j++ {
+			rtn[j] += factors[i] * v[j]
+		}
+	}
+	return rtn
+}
+
+func extreme(vals [][]float64, max bool) []float64 {
+	if len(vals) == 0 {
+		return nil
+	}
+	rtn := make([]float64, len(vals[0]))
+	copy(rtn, vals[0])
+	for _, v := range vals[1:] {
+		for j := 0; j < len(rtn) && j < len(v); j++ {
+			if max {
+				rtn[j] = math.Max(rtn[j], v[j])
+			} else {
+				rtn[j] = math.Min(rtn[j], v[j])
+			}
+		}
+	}
+	return rtn
+}
+
+// hasResult reports whether any elem or node of frame has the result of period.
+func hasResult(frame *st.Frame, period string) bool {
+	for _, el := range frame.Elems {
+		if _, ok := el.Stress[period]; ok {
+			return true
+		}
+	}
+	for _, n := range frame.Nodes {
+		if _, ok := n.Disp[period]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// applyPeriods stores f(results of periods) in frame as the result of name.
+func applyPeriods(frame *st.Frame, name string, periods []string, f func([][]float64) []float64) error {
+	for _, per := range periods {
+		if !hasResult(frame, per) {
+			return errors.New(fmt.Sprintf("no result for period %s", per))
+		}
+	}
+	collect := func(get func(string) ([]float64, bool)) ([][]float64, bool) {
+		vals := make([][]float64, len(periods))
+		for i, per := range periods {
+			v, ok := get(per)
+			if !ok {
+				return nil, false
+			}
+			vals[i] = v
+		}
+		return vals, true
+	}
+	for _, n := range frame.Nodes {
+		if vals, ok := collect(func(per string) ([]float64, bool) { v, ok := n.Disp[per]; return v, ok }); ok {
+			n.Disp[name] = f(vals)
+		}
+		if vals, ok := collect(func(per string) ([]float64, bool) { v, ok := n.Reaction[per]; return v, ok }); ok {
+			n.Reaction[name] = f(vals)
+		}
+	}
+	for _, el := range frame.Elems {
+		if _, ok := el.Stress[periods[0]]; !ok {
+			continue
+		}
+		stress := make(map[int][]float64)
+		for nnum := range el.Stress[periods[0]] {
+			if vals, ok := collect(func(per string) ([]float64, bool) { v, ok := el.Stress[per][nnum]; return v, ok }); ok {
+				stress[nnum] = f(vals)
+			}
+		}
+		el.Stress[name] = stress
+	}
+	return nil
+}
+
+func (lc *LoadCombination) Apply(frame *st.Frame) error {
+	return applyPeriods(frame, lc.Name, lc.Periods, func(vals [][]float64) []float64 {
+		return combine(vals, lc.Factors)
+	})
+}
+
+func (env *Envelope) Apply(frame *st.Frame) error {
+	err := applyPeriods(frame, fmt.Sprintf("%sMAX", env.Name), env.Periods, func(vals [][]float64) []float64 {
+		return extreme(vals, true)
+	})
+	if err != nil {
+		return err
+	}
+	return applyPeriods(frame, fmt.Sprintf("%sMIN", env.Name), env.Periods, func(vals [][]float64) []float64 {
+		return extreme(vals, false)
+	})
+}
+
+func (env *Envelope) String() string {
+	return fmt.Sprintf("%sMAX/%sMIN=ENVELOPE(%s)", env.Name, env.Name, strings.Join(env.Periods, ","))
+}
+
+// checkVirtualPeriod returns an error if a virtual period named name would overwrite real results:
+// L, X, Y, a period of an analysis or any period with results except own, the virtual periods of the same kind,
+// which are redefined. The virtual periods of the other kind are rejected.
+func (stw *Window) checkVirtualPeriod(name string, own, other map[string]bool) error {
+	switch name {
+	case "L", "X", "Y":
+		return errors.New(fmt.Sprintf("%s is a period of the analysis", name))
+	}
+	if _, ok := stw.Frame.Arclms[name]; ok {
+		return errors.New(fmt.Sprintf("%s is a period of the analysis", name))
+	}
+	if other[name] {
+		return errors.New(fmt.Sprintf("%s is already used by another combination or envelope", name))
+	}
+	if !own[name] && hasResult(stw.Frame, name) {
+		return errors.New(fmt.Sprintf("%s is a period with results", name))
+	}
+	return nil
+}
+
+// virtualPeriods returns the names of the combinations and of the periods of the envelopes.
+func (stw *Window) virtualPeriods() (map[string]bool, map[string]bool) {
+	combs := make(map[string]bool)
+	envs := make(map[string]bool)
+	for _, lc := range stw.combinations {
+		combs[lc.Name] = true
+	}
+	for _, env := range stw.envelopes {
+		envs[fmt.Sprintf("%sMAX", env.Name)] = true
+		envs[fmt.Sprintf("%sMIN", env.Name)] = true
+	}
+	return combs, envs
+}
+
+// AddLoadCombination defines a virtual period and computes its results.
+func (stw *Window) AddLoadCombination(lc *LoadCombination) error {
+	combs, envs := stw.virtualPeriods()
+	err := stw.checkVirtualPeriod(lc.Name, combs, envs)
+	if err != nil {
+		return errors.New(fmt.Sprintf("AddLoadCombination: %s", err.Error()))
+	}
+	err = lc.Apply(stw.Frame)
+	if err != nil {
+		return err
+	}
+	for i, c := range stw.combinations {
+		if c.Name == lc.Name {
+			stw.combinations[i] = lc
+			return nil
+		}
+	}
+	stw.combinations = append(stw.combinations, lc)
+	return nil
+}
+
+func (stw *Window) AddEnvelope(env *Envelope) error {
+	combs, envs := stw.virtualPeriods()
+	for _, name := range []string{fmt.Sprintf("%sMAX", env.Name), fmt.Sprintf("%sMIN", env.Name)} {
+		err := stw.checkVirtualPeriod(name, envs, combs)
+		if err != nil {
+			return errors.New(fmt.Sprintf("AddEnvelope: %s", err.Error()))
+		}
+	}
+	err := env.Apply(stw.Frame)
+	if err != nil {
+		return err
+	}
+	for i, e := range stw.envelopes {
+		if e.Name == env.Name {
+			stw.envelopes[i] = env
+			return nil
+		}
+	}
+	stw.envelopes = append(stw.envelopes, env)
+	return nil
+}
+
+// UpdateCombinations recomputes the virtual periods after the results are changed.
+// Those whose periods have no result yet are left as they are.
+func (stw *Window) UpdateCombinations() {
+	if stw.Frame == nil {
+		return
+	}
+	ready := func(periods []string) bool {
+		for _, per := range periods {
+			if !hasResult(stw.Frame, per) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, lc := range stw.combinations {
+		if ready(lc.Periods) {
+			lc.Apply(stw.Frame)
+		}
+	}
+	for _, env := range stw.envelopes {
+		if ready(env.Periods) {
+			env.Apply(stw.Frame)
+		}
+	}
+}
+
+func (stw *Window) ClearCombinations() {
+	if stw.Frame != nil {
+		names := make([]string, 0)
+		for _, lc := range stw.combinations {
+			names = append(names, lc.Name)
+		}
+		for _, env := range stw.envelopes {
+			names = append(names, fmt.Sprintf("%sMAX", env.Name), fmt.Sprintf("%sMIN", env.Name))
+		}
+		for _, name := range names {
+			for _, n := range stw.Frame.Nodes {
+				delete(n.Disp, name)
+				delete(n.Reaction, name)
+			}
+			for _, el := range stw.Frame.Elems {
+				delete(el.Stress, name)
+			}
+		}
+	}
+	stw.combinations = make([]*LoadCombination, 0)
+	stw.envelopes = make([]*Envelope, 0)
+}
+
+func (stw *Window) CombinationString() string {
+	if len(stw.combinations) == 0 && len(stw.envelopes) == 0 {
+		return "no combinations"
+	}
+	var otp bytes.Buffer
+	for _, lc := range stw.combinations {
+		otp.WriteString(fmt.Sprintf("%s\n", lc))
+	}
+	for _, env := range stw.envelopes {
+		otp.WriteString(fmt.Sprintf("%s\n", env))
+	}
+	return strings.TrimSuffix(otp.String(), "\n")
+}
+
+func (stw *Window) PeriodCandidates(str string) []string {
+	str = strings.ToUpper(str)
+	pers := make(map[string]bool)
+	if stw.Frame != nil {
+		for per := range stw.Frame.Arclms {
+			pers[per] = true
+		}
+	}
+	for _, lc := range stw.combinations {
+		pers[lc.Name] = true
+	}
+	for _, env := range stw.envelopes {
+		pers[fmt.Sprintf("%sMAX", env.Name)] = true
+		pers[fmt.Sprintf("%sMIN", env.Name)] = true
+	}
+	rtn := make([]string, 0)
+	for per := range pers {
+		if strings.HasPrefix(per, str) {
+			rtn = append(rtn, per)
+		}
+	}
+	sort.Strings(rtn)
+	return rtn
+}
@@ -0,0 +1,269 @@
+package stgxui
+
+import (
+	"fmt"
+	"github.com/yofu/st/stlib"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Comparison holds the result of matching the current frame with another one.
+// Nodes are matched by number and coordinates, elems by their matched enods.
+type Comparison struct {
+	Frame    *st.Frame
+	NodeMap  map[*st.Node]*st.Node
+	ElemMap  map[*st.Elem]*st.Elem
+	Added    []*st.Elem
+	Deleted  []*st.Elem
+	Modified map[*st.Elem][]string
+	NodeDiff map[*st.Node][]string
+	added    map[*st.Elem]bool
+}
+
+// CompareReportLines is the number of changes shown on a page of Report.
+var CompareReportLines = 50
+
+func samecoord(n1, n2 *st.Node) bool {
+	for i := 0; i < 3; i++ {
+		if math.Abs(n1.Coord[i]-n2.Coord[i]) > EPS {
+			return false
+		}
+	}
+	return true
+}
+
+// coordcell returns the cell of a grid with spacing EPS containing coord.
+func coordcell(coord []float64) [3]int64 {
+	var rtn [3]int64
+	for i := 0; i < 3; i++ {
+		rtn[i] = int64(math.Floor(coord[i] / EPS))
+	}
+	return rtn
+}
+
+// nodeGrid indexes nodes by coordcell so that a node at the same coordinates is found
+// in the 27 cells around it instead of by a scan of all the nodes.
+type nodeGrid map[[3]int64][]*st.Node
+
+func newNodeGrid(nodes map[int]*st.Node) nodeGrid {
+	rtn := make(nodeGrid)
+	for _, n := range nodes {
+		c := coordcell(n.Coord)
+		rtn[c] = append(rtn[c], n)
+	}
+	return rtn
+}
+
+// find returns a node at the coordinates of n for which ok returns true.
+func (g nodeGrid) find(n *st.Node, ok func(*st.Node) bool) *st.Node {
+	c := coordcell(n.Coord)
+	for i := c[0] - 1; i <= c[0]+1; i++ {
+		for j := c[1] - 1; j <= c[1]+1; j++ {
+			for k := c[2] - 1; k <= c[2]+1; k++ {
+				for _, m := range g[[3]int64{i, j, k}] {
+					if ok(m) && samecoord(n, m) {
+						return m
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func enodkey(el *st.Elem, nmap map[*st.Node]*st.Node) (string, bool) {
+	nums := make([]int, el.Enods)
+	for i, en := range el.Enod[:el.Enods] {
+		if nmap == nil {
+			nums[i] = en.Num
+			continue
+		}
+		if m, ok := nmap[en]; ok {
+			nums[i] = m.Num
+		} else {
+			return "", false
+		}
+	}
+	sort.Ints(nums)
+	return fmt.Sprintf("%d:%v", el.Etype, nums), true
+}
+
+func CompareFrame(frame, other *st.Frame) *Comparison {
+	c := &Comparison{
+		Frame:    other,
+		NodeMap:  make(map[*st.Node]*st.Node),
+		ElemMap:  make(map[*st.Elem]*st.Elem),
+		Added:    make([]*st.Elem, 0),
+		Deleted:  make([]*st.Elem, 0),
+		Modified: make(map[*st.Elem][]string),
+		NodeDiff: make(map[*st.Node][]string),
+		added:    make(map[*st.Elem]bool),
+	}
+	matched := make(map[*st.Node]bool)
+	rest := make([]*st.Node, 0)
+	for num, n := range frame.Nodes {
+		if m, ok := other.Nodes[num]; ok && samecoord(n, m) {
+			c.NodeMap[n] = m
+			matched[m] = true
+		} else {
+			rest = append(rest, n)
+		}
+	}
+	if len(rest) > 0 {
+		grid := newNodeGrid(other.Nodes)
+		for _, n := range rest {
+			m := grid.find(n, func(m *st.Node) bool {
+				return !matched[m]
+			})
+			if m != nil {
+				c.NodeMap[n] = m
+				matched[m] = true
+			}
+		}
+	}
+	ekeys := make(map[string]*st.Elem)
+	for _, el := range other.Elems {
+		if key, ok := enodkey(el, nil); ok {
+			ekeys[key] = el
+		}
+	}
+	ematched := make(map[*st.Elem]bool)
+	for num, el := range frame.Elems {
+		key, ok := enodkey(el, c.NodeMap)
+		if !ok {
+			c.Added = append(c.Added, el)
+			continue
+		}
+		if el2, ok := other.Elems[num]; ok && !ematched[el2] {
+			if key2, _ := enodkey(el2, nil); key2 == key {
+				c.ElemMap[el] = el2
+				ematched[el2] = true
+				continue
+			}
+		}
+		if el2, ok := ekeys[key]; ok && !ematched[el2] {
+			c.ElemMap[el] = el2
+			ematched[el2] = true
+			continue
+		}
+		c.Added = append(c.Added, el)
+	}
+	for _, el := range other.Elems {
+		if !ematched[el] {
+			c.Deleted = append(c.Deleted, el)
+		}
+	}
+	for _, el := range c.Added {
+		c.added[el] = true
+	}
+	sort.Sort(st.ElemByNum{c.Added})
+	sort.Sort(st.ElemByNum{c.Deleted})
+	for el, el2 := range c.ElemMap {
+		reasons := make([]string, 0)
+		if el.Sect.Num != el2.Sect.Num {
+			reasons = append(reasons, fmt.Sprintf("SECT %d -> %d", el2.Sect.Num, el.Sect.Num))
+		}
+		if el.IsLineElem() && el2.IsLineElem() {
+			for i := 0; i < 12; i++ {
+				if el.Bonds[i] != el2.Bonds[i] {
+					reasons = append(reasons, "BOND")
+					break
+				}
+			}
+			for i := 0; i < 12; i++ {
+				if math.Abs(el.Cmq[i]-el2.Cmq[i]) > EPS {
+					reasons = append(reasons, "CMQ")
+					break
+				}
+			}
+		}
+		if len(reasons) > 0 {
+			c.Modified[el] = reasons
+		}
+	}
+	for n, m := range c.NodeMap {
+		reasons := make([]string, 0)
+		for i := 0; i < 6; i++ {
+			if n.Conf[i] != m.Conf[i] {
+				reasons = append(reasons, "CONF")
+				break
+			}
+		}
+		for i := 0; i < 6; i++ {
+			if math.Abs(n.Load[i]-m.Load[i]) > EPS {
+				reasons = append(reasons, "LOAD")
+				break
+			}
+		}
+		if len(reasons) > 0 {
+			c.NodeDiff[n] = reasons
+		}
+	}
+	return c
+}
+
+// Report returns the summary and the page-th (1-based) page of CompareReportLines changes.
+func (c *Comparison) Report(page int) string {
+	lines := make([]string, 0)
+	for _, el := range c.Added {
+		lines = append(lines, fmt.Sprintf("+ ELEM %d SECT %d", el.Num, el.Sect.Num))
+	}
+	for _, el := range c.Deleted {
+		lines = append(lines, fmt.Sprintf("- ELEM %d SECT %d", el.Num, el.Sect.Num))
+	}
+	els := make([]*st.Elem, len(c.Modified))
+	i := 0
+	for el := range c.Modified {
+		els[i] = el
+		i++
+	}
+	sort.Sort(st.ElemByNum{els})
+	for _, el := range els {
+		lines = append(lines, fmt.Sprintf("* ELEM %d: %s", el.Num, strings.Join(c.Modified[el], ", ")))
+	}
+	nodes := make([]*st.Node, len(c.NodeDiff))
+	i = 0
+	for n := range c.NodeDiff {
+		nodes[i] = n
+		i++
+	}
+	sort.Sort(st.NodeByNum{nodes})
+	for _, n := range nodes {
+		lines = append(lines, fmt.Sprintf("* NODE %d: %s", n.Num, strings.Join(c.NodeDiff[n], ", ")))
+	}
+	pages := (len(lines) + CompareReportLines - 1) / CompareReportLines
+	if page < 1 {
+		page = 1
+	} else if pages > 0 && page > pages {
+		page = pages
+	}
+	rtn := []string{
+		fmt.Sprintf("COMPARE: %s", c.Frame.Path),
+		fmt.Sprintf("ADDED: %d, DELETED: %d, MODIFIED: %d, NODES: %d", len(c.Added), len(c.Deleted), len(c.Modified), len(c.NodeDiff)),
+	}
+	if pages <= 1 {
+		return strings.Join(append(rtn, lines...), "\n")
+	}
+	start := (page - 1) * CompareReportLines
+	end := start + CompareReportLines
+	if end > len(lines) {
+		end = len(lines)
+	}
+	rtn = append(rtn, lines[start:end]...)
+	if page < pages {
+		rtn = append(rtn, fmt.Sprintf("PAGE %d/%d (:compare -page=%d for more)", page, pages, page+1))
+	} else {
+		rtn = append(rtn, fmt.Sprintf("PAGE %d/%d", page, pages))
+	}
+	return strings.Join(rtn, "\n")
+}
+
+func (c *Comparison) IsAdded(el *st.Elem) bool {
+	return c.added[el]
+}
+
+func (c *Comparison) IsModified(el *st.Elem) bool {
+	_, ok := c.Modified[el]
+	return ok
+}
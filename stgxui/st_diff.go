@@ -0,0 +1,130 @@
+package stgxui
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/yofu/st/stlib"
+	"math"
+	"sort"
+)
+
+type FrameDiff struct {
+	AddedNodes   []int
+	RemovedNodes []int
+	MovedNodes   []int
+	AddedElems   []int
+	RemovedElems []int
+	ChangedEnod  []int
+	ChangedSect  map[int][]int
+}
+
+// DiffFrame compares two frames matching nodes and elems by their numbers.
+func DiffFrame(from, to *st.Frame) *FrameDiff {
+	fd := &FrameDiff{
+		AddedNodes:   make([]int, 0),
+		RemovedNodes: make([]int, 0),
+		MovedNodes:   make([]int, 0),
+		AddedElems:   make([]int, 0),
+		RemovedElems: make([]int, 0),
+		ChangedEnod:  make([]int, 0),
+		ChangedSect:  make(map[int][]int),
+	}
+	for num, n := range from.Nodes {
+		if m, ok := to.Nodes[num]; ok {
+			for i := 0; i < 3; i++ {
+				if math.Abs(n.Coord[i]-m.Coord[i]) > EPS {
+					fd.MovedNodes = append(fd.MovedNodes, num)
+					break
+				}
+			}
+		} else {
+			fd.RemovedNodes = append(fd.RemovedNodes, num)
+		}
+	}
+	for num := range to.Nodes {
+		if _, ok := from.Nodes[num]; !ok {
+			fd.AddedNodes = append(fd.AddedNodes, num)
+		}
+	}
+	for num, el := range from.Elems {
+		if el2, ok := to.Elems[num]; ok {
+			if !sameEnod(el, el2) {
+				fd.ChangedEnod = append(fd.ChangedEnod, num)
+			}
+			if el.Sect.Num != el2.Sect.Num {
+				fd.ChangedSect[num] = []int{el.Sect.Num, el2.Sect.Num}
+			}
+		} else {
+			fd.RemovedElems = append(fd.RemovedElems, num)
+		}
+	}
+	for num := range to.Elems {
+		if _, ok := from.Elems[num]; !ok {
+			fd.AddedElems = append(fd.AddedElems, num)
+		}
+	}
+	for _, l := range [][]int{fd.AddedNodes, fd.RemovedNodes, fd.MovedNodes, fd.AddedElems, fd.RemovedElems, fd.ChangedEnod} {
+		sort.Ints(l)
+	}
+	return fd
+}
+
+func sameEnod(el1, el2 *st.Elem) bool {
+	if el1.Enods != el2.Enods {
+		return false
+	}
+	for i := 0; i < el1.Enods; i++ {
+		if el1.Enod[i].Num != el2.Enod[i].Num {
+			return false
+		}
+	}
+	return true
+}
+
+func (fd *FrameDiff) Empty() bool {
+	return len(fd.AddedNodes)+len(fd.RemovedNodes)+len(fd.MovedNodes)+len(fd.AddedElems)+len(fd.RemovedElems)+len(fd.ChangedEnod)+len(fd.ChangedSect) == 0
+}
+
+func (fd *FrameDiff) String() string {
+	if fd.Empty() {
+		return "NO DIFFERENCE"
+	}
+	var otp bytes.Buffer
+	for _, l := range []struct {
+		name string
+		nums []int
+	}{
+		{"NODE ADDED", fd.AddedNodes},
+		{"NODE REMOVED", fd.RemovedNodes},
+		{"NODE MOVED", fd.MovedNodes},
+		{"ELEM ADDED", fd.AddedElems},
+		{"ELEM REMOVED", fd.RemovedElems},
+		{"ELEM RECONNECTED", fd.ChangedEnod},
+	} {
+		if len(l.nums) == 0 {
+			continue
+		}
+		otp.WriteString(fmt.Sprintf("%s: %d\n", l.name, len(l.nums)))
+		for i, num := range l.nums {
+			if i > 0 && i%10 == 0 {
+				otp.WriteString("\n")
+			}
+			otp.WriteString(fmt.Sprintf(" %d", num))
+		}
+		otp.WriteString("\n")
+	}
+	if len(fd.ChangedSect) > 0 {
+		otp.WriteString(fmt.Sprintf("SECT CHANGED: %d\n", len(fd.ChangedSect)))
+		nums := make([]int, len(fd.ChangedSect))
+		i := 0
+		for num := range fd.ChangedSect {
+			nums[i] = num
+			i++
+		}
+		sort.Ints(nums)
+		for _, num := range nums {
+			otp.WriteString(fmt.Sprintf(" ELEM %d: %d -> %d\n", num, fd.ChangedSect[num][0], fd.ChangedSect[num][1]))
+		}
+	}
+	return otp.String()
+}
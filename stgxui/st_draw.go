@@ -32,6 +32,12 @@ var (
 	StressTextColor = gxui.White
 	YieldedTextColor = gxui.Yellow
 	BrittleTextColor = gxui.Red
+	AddedPen = gxui.CreatePen(1.5, gxui.Green)
+	AddedBrush = gxui.CreateBrush(OpaqueColor(gxui.Green, PLANE_OPACITY))
+	DeletedPen = gxui.CreatePen(1.5, gxui.Red)
+	DeletedBrush = gxui.CreateBrush(OpaqueColor(gxui.Red, PLANE_OPACITY))
+	ModifiedPen = gxui.CreatePen(1.5, gxui.Yellow)
+	ModifiedBrush = gxui.CreateBrush(OpaqueColor(gxui.Yellow, PLANE_OPACITY))
 )
 
 func IntColorFloat32(col int) []float32 {
@@ -302,6 +308,9 @@ func (stw *Window) DrawFrame() gxui.Canvas {
 	var brush gxui.Brush
 	font := stw.theme.DefaultFont()
 	stw.Frame.View.Set(1)
+	if stw.colormap != nil && stw.Frame.Show.ColorMode == ECOLOR_MAP {
+		stw.colormap.Resolve(stw.Frame)
+	}
 	nodes := make([]*st.Node, len(stw.Frame.Nodes))
 	i := 0
 	for _, n := range stw.Frame.Nodes {
@@ -360,6 +369,8 @@ func (stw *Window) DrawFrame() gxui.Canvas {
 					pen = Pen(st.Rainbow(val, st.RateBoundary), false)
 					brush = Brush(st.Rainbow(val, st.RateBoundary), false)
 				}
+			case ECOLOR_MAP:
+				pen, brush = stw.ColorMapPenBrush(el, false)
 			case st.ECOLOR_N:
 				if el.N(stw.Frame.Show.Period, 0) >= 0.0 {
 					pen = Pen(st.RainbowColor[0], false) // Compression: Blue
@@ -383,8 +394,25 @@ func (stw *Window) DrawFrame() gxui.Canvas {
 					pen = Pen(st.RainbowColor[6], false) // Weak: Red
 				}
 			}
+			if stw.compare != nil {
+				if stw.compare.IsAdded(el) {
+					pen = AddedPen
+					brush = AddedBrush
+				} else if stw.compare.IsModified(el) {
+					pen = ModifiedPen
+					brush = ModifiedBrush
+				}
+			}
 			DrawElem(el, canvas, pen, brush, font, gxui.White, false, stw.Frame.Show)
 		}
+		if stw.compare != nil {
+			for _, el := range stw.compare.Deleted {
+				for _, en := range el.Enod[:el.Enods] {
+					stw.Frame.View.ProjectNode(en)
+				}
+				DrawElem(el, canvas, DeletedPen, DeletedBrush, font, gxui.Red, false, stw.Frame.Show)
+			}
+		}
 	}
 	if stw.SelectElem != nil {
 		nomv := stw.Frame.Show.NoMomentValue
@@ -415,6 +443,8 @@ func (stw *Window) DrawFrame() gxui.Canvas {
 					pen = Pen(st.Rainbow(val, st.RateBoundary), true)
 					brush = Brush(st.Rainbow(val, st.RateBoundary), true)
 				}
+			case ECOLOR_MAP:
+				pen, brush = stw.ColorMapPenBrush(el, true)
 			case st.ECOLOR_N:
 				if el.N(stw.Frame.Show.Period, 0) >= 0.0 {
 					pen = Pen(st.RainbowColor[0], true) // Compression: Blue
@@ -442,6 +472,9 @@ func (stw *Window) DrawFrame() gxui.Canvas {
 		}
 		stw.Frame.Show.NoMomentValue = nomv
 	}
+	if stw.Frame.Show.ColorMode == ECOLOR_MAP {
+		stw.DrawColorMapLegend(canvas, font)
+	}
 	canvas.Complete()
 	return canvas
 }
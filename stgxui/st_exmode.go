@@ -18,11 +18,13 @@ import (
 )
 
 var (
+	re_let   = regexp.MustCompile("^ *:let!? +([a-zA-Z_][a-zA-Z0-9_]*) *=? *(.*?) *$")
 	exabbrev = []string{
 		"e/dit", "q/uit", "vi/m", "hk/you", "hw/eak", "rp/ipe", "cp/ipe", "tk/you", "ck/you", "pla/te", "fixr/otate", "fixm/ove", "noun/do", "un/do", "w/rite", "sav/e", "inc/rement", "c/heck", "r/ead",
 		"ins/ert", "p/rop/s/ect", "w/rite/o/utput", "w/rite/rea/ction", "nmi/nteraction", "fi/g2", "fe/nce", "no/de", "xsc/ale", "ysc/ale", "zsc/ale", "pl/oad", "z/oubun/d/isp", "z/oubun/r/eaction",
 		"fac/ts", "go/han/l/st", "el/em", "ave/rage", "bo/nd", "ax/is/2//c/ang", "resul/tant", "prest/ress", "therm/al", "div/ide", "e/lem/dup/lication", "i/ntersect/a/ll", "co/nf",
 		"pi/le", "sec/tion", "an/alysis", "f/ilter", "h/eigh/t/", "h/eigh/t+/", "h/eigh/t-/", "sec/tion/+/", "col/or", "a/rclm/001/", "a/rclm/201/", "a/rclm/301/",
+		"comb/ination", "env/elope",
 	}
 )
 
@@ -46,18 +48,23 @@ func exmodecomplete(command string) (string, bool, bool) {
 	return rtn, bang, usage
 }
 
-func (stw *Window) emptyexmodech() {
-ex_empty:
-	for {
-		select {
-		case <-time.After(time.Second):
-			break ex_empty
-		case <-stw.exmodeend:
-			break ex_empty
-		case <-stw.exmodech:
-			continue ex_empty
+func exmodecandidates(command string) []string {
+	cname := strings.ToLower(strings.TrimPrefix(command, ":"))
+	rtn := make([]string, 0)
+	for _, ab := range exabbrev {
+		pat := abbrev.MustCompile(ab)
+		name := pat.Longest()
+		if strings.HasPrefix(name, cname) || pat.MatchString(cname) {
+			rtn = append(rtn, fmt.Sprintf(":%s", name))
+		}
+	}
+	for _, h := range exhelps {
+		if h.Abbrev == "" && strings.HasPrefix(h.Name, cname) {
+			rtn = append(rtn, fmt.Sprintf(":%s", h.Name))
 		}
 	}
+	sort.Strings(rtn)
+	return rtn
 }
 
 func (stw *Window) exmode(command string) error {
@@ -65,7 +72,7 @@ func (stw *Window) exmode(command string) error {
 		return stw.exmode(stw.lastexcommand)
 	}
 	stw.lastexcommand = command
-	if !strings.Contains(command, "|") {
+	if !strings.Contains(command, "|") || re_let.MatchString(command) {
 		err := stw.excommand(command, false)
 		if u, ok := err.(st.Messager); ok {
 			stw.History(u.Message())
@@ -75,7 +82,9 @@ func (stw *Window) exmode(command string) error {
 		}
 	}
 	excms := strings.Split(command, "|")
-	defer stw.emptyexmodech()
+	defer func() {
+		stw.pipe = nil
+	}()
 	for _, com := range excms {
 		err := stw.excommand(com, true)
 		if err != nil {
@@ -90,6 +99,8 @@ func (stw *Window) exmode(command string) error {
 }
 
 func (stw *Window) excommand(command string, pipe bool) error {
+	input := stw.pipe
+	stw.pipe = nil
 	if len(command) == 1 {
 		return st.NotEnoughArgs("exmode")
 	}
@@ -132,25 +143,22 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 	}
 	cname, bang, usage := exmodecomplete(args[0])
+	if usage {
+		if h, ok := ExHelp[cname]; ok {
+			return st.Usage(h.UsageString())
+		}
+	}
 	evaluated := true
-	var sender []interface{}
+	var sender *PipeValue
 	defer func() {
 		if pipe {
-			go func(ents []interface{}) {
-				for _, e := range ents {
-					stw.exmodech <- e
-				}
-				stw.exmodeend <- 1
-			}(sender)
+			stw.pipe = sender
 		}
 	}()
 	switch cname {
 	default:
 		evaluated = false
 	case "edit":
-		if usage {
-			return st.Usage(":edit filename {-u=.strc}")
-		}
 		if !bang && stw.Changed {
 			if stw.Yn("CHANGED", "変更を保存しますか") {
 				stw.SaveAS("hogtxt.inp")
@@ -186,14 +194,8 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			stw.Reload()
 		}
 	case "quit":
-		if usage {
-			return st.Usage(":quit")
-		}
 		stw.Close(bang)
 	case "eps":
-		if usage {
-			return st.Usage(":eps val")
-		}
 		if narg < 2 {
 			return st.NotEnoughArgs(":eps")
 		}
@@ -204,9 +206,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		EPS = val
 		return st.Message(fmt.Sprintf("EPS=%.3E", EPS))
 	case "fitscale":
-		if usage {
-			return st.Usage(":fitscale val")
-		}
 		if narg < 2 {
 			return st.NotEnoughArgs(":fitscale")
 		}
@@ -217,23 +216,19 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		CanvasFitScale = val
 		return st.Message(fmt.Sprintf("FITSCALE=%.3E", CanvasFitScale))
 	case "mkdir":
-		if usage {
-			return st.Usage(":mkdir dirname")
-		}
 		os.MkdirAll(fn, 0644)
 	case "#":
-		if usage {
-			return st.Usage(":#")
-		}
 		stw.ShowRecently()
 	case "vim":
-		if usage {
-			return st.Usage(":vim filename")
-		}
 		Vim(fn)
 	case "hkyou":
-		if usage {
-			return st.Usage(":hkyou h b tw tf")
+		if narg == 2 && strings.ContainsAny(args[1], "xX*") {
+			tmp, err := stw.lookupShapeArgs("H", args)
+			if err != nil {
+				return err
+			}
+			args = tmp
+			narg = len(args)
 		}
 		if narg < 5 {
 			return st.NotEnoughArgs(":hkyou")
@@ -244,11 +239,16 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.ShapeData(al)
 		if pipe {
-			sender = []interface{}{al}
+			sender = &PipeValue{Shape: al}
 		}
 	case "hweak":
-		if usage {
-			return st.Usage(":hweak h b tw tf")
+		if narg == 2 && strings.ContainsAny(args[1], "xX*") {
+			tmp, err := stw.lookupShapeArgs("H", args)
+			if err != nil {
+				return err
+			}
+			args = tmp
+			narg = len(args)
 		}
 		if narg < 5 {
 			return st.NotEnoughArgs(":hweak")
@@ -259,11 +259,16 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.ShapeData(al)
 		if pipe {
-			sender = []interface{}{al}
+			sender = &PipeValue{Shape: al}
 		}
 	case "rpipe":
-		if usage {
-			return st.Usage(":rpipe h b tw tf")
+		if narg == 2 && strings.ContainsAny(args[1], "xX*") {
+			tmp, err := stw.lookupShapeArgs("BOX", args)
+			if err != nil {
+				return err
+			}
+			args = tmp
+			narg = len(args)
 		}
 		if narg < 5 {
 			return st.NotEnoughArgs(":rpipe")
@@ -274,11 +279,16 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.ShapeData(al)
 		if pipe {
-			sender = []interface{}{al}
+			sender = &PipeValue{Shape: al}
 		}
 	case "cpipe":
-		if usage {
-			return st.Usage(":cpipe d t")
+		if narg == 2 && strings.ContainsAny(args[1], "xX*") {
+			tmp, err := stw.lookupShapeArgs("P", args)
+			if err != nil {
+				return err
+			}
+			args = tmp
+			narg = len(args)
 		}
 		if narg < 3 {
 			return st.NotEnoughArgs(":cpipe")
@@ -289,12 +299,9 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.ShapeData(al)
 		if pipe {
-			sender = []interface{}{al}
+			sender = &PipeValue{Shape: al}
 		}
 	case "tkyou":
-		if usage {
-			return st.Usage(":tkyou h b tw tf")
-		}
 		if narg < 5 {
 			return st.NotEnoughArgs(":tkyou")
 		}
@@ -304,11 +311,16 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.ShapeData(al)
 		if pipe {
-			sender = []interface{}{al}
+			sender = &PipeValue{Shape: al}
 		}
 	case "ckyou":
-		if usage {
-			return st.Usage(":ckyou h b tw tf")
+		if narg == 2 && strings.ContainsAny(args[1], "xX*") {
+			tmp, err := stw.lookupShapeArgs("C", args)
+			if err != nil {
+				return err
+			}
+			args = tmp
+			narg = len(args)
 		}
 		if narg < 5 {
 			return st.NotEnoughArgs(":ckyou")
@@ -319,12 +331,9 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.ShapeData(al)
 		if pipe {
-			sender = []interface{}{al}
+			sender = &PipeValue{Shape: al}
 		}
 	case "plate":
-		if usage {
-			return st.Usage(":plate h b")
-		}
 		if narg < 3 {
 			return st.NotEnoughArgs(":plate")
 		}
@@ -334,7 +343,46 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.ShapeData(al)
 		if pipe {
-			sender = []interface{}{al}
+			sender = &PipeValue{Shape: al}
+		}
+	case "shape":
+		kind := strings.ToUpper(argdict["KIND"])
+		word := strings.Join(args[1:], "")
+		if _, ok := argdict["LIST"]; ok {
+			shapes := SearchCatalogue(kind, word)
+			if len(shapes) == 0 {
+				return errors.New(fmt.Sprintf(":shape no shape matches %s", word))
+			}
+			var otp bytes.Buffer
+			for _, cs := range shapes {
+				otp.WriteString(fmt.Sprintf("%s\n", cs.Name()))
+			}
+			return st.Message(strings.TrimSuffix(otp.String(), "\n"))
+		}
+		if narg < 2 {
+			return st.NotEnoughArgs(":shape")
+		}
+		cs, candidates, err := LookupShape(kind, word)
+		if err != nil {
+			shapes := SearchCatalogue(kind, word)
+			switch len(shapes) {
+			case 0:
+				return err
+			case 1:
+				cs = shapes[0]
+			default:
+				return errors.New(fmt.Sprintf(":shape %d shapes match %s: %s", len(shapes), word, catalogueNames(shapes)))
+			}
+		} else if len(candidates) > 1 {
+			stw.History(fmt.Sprintf("CANDIDATES: %s", catalogueNames(candidates)))
+		}
+		al, err := cs.Shape()
+		if err != nil {
+			return err
+		}
+		stw.ShapeData(al)
+		if pipe {
+			sender = &PipeValue{Shape: al}
 		}
 	case "fixrotate":
 		fixRotate = !fixRotate
@@ -355,9 +403,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			return st.Message("select elem with Alt key")
 		}
 	case "procs":
-		if usage {
-			return st.Usage(":procs numcpu")
-		}
 		if narg < 2 {
 			current := runtime.GOMAXPROCS(-1)
 			return st.Message(fmt.Sprintf("PROCS: %d", current))
@@ -371,11 +416,180 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			old := runtime.GOMAXPROCS(val)
 			return st.Message(fmt.Sprintf("PROCS: %d -> %d", old, val))
 		}
+	case "let":
+		if narg < 2 {
+			if len(stw.variables) == 0 {
+				return st.Message("no variables")
+			}
+			return st.Message(stw.VariablesString())
+		}
+		fs := re_let.FindStringSubmatch(command)
+		if fs == nil {
+			return st.Usage(ExHelp["let"].UsageString())
+		}
+		if fs[2] == "" && !strings.Contains(command, "=") {
+			if val, ok := stw.variables[fs[1]]; ok {
+				return st.Message(fmt.Sprintf("%s = %s", fs[1], val))
+			}
+			return errors.New(fmt.Sprintf(":let %s is not defined", fs[1]))
+		}
+		err := stw.SetVariable(fs[1], fs[2])
+		if err != nil {
+			return err
+		}
+		return st.Message(fmt.Sprintf("%s = %s", fs[1], stw.variables[fs[1]]))
+	case "unlet":
+		if narg < 2 {
+			return st.NotEnoughArgs(":unlet")
+		}
+		for _, name := range args[1:] {
+			delete(stw.variables, name)
+		}
+	case "source":
+		if narg < 2 {
+			return st.NotEnoughArgs(":source")
+		}
+		if !st.FileExists(fn) {
+			return errors.New(fmt.Sprintf(":source %s doesn't exist", fn))
+		}
+		err := stw.SourceFile(fn)
+		if err != nil {
+			return err
+		}
+	case "script":
+		if narg < 2 {
+			return st.NotEnoughArgs(":script")
+		}
+		if !st.FileExists(fn) {
+			return errors.New(fmt.Sprintf(":script %s doesn't exist", fn))
+		}
+		timeout := LuaTimeout
+		if t, ok := argdict["TIMEOUT"]; ok {
+			tmp, err := strconv.ParseFloat(t, 64)
+			if err != nil {
+				return errors.New(fmt.Sprintf(":script invalid timeout %s", t))
+			}
+			timeout = time.Duration(tmp * float64(time.Second))
+		}
+		err := stw.RunLuaFile(fn, args[2:], timeout)
+		if err != nil {
+			return err
+		}
+	case "serve":
+		if narg < 2 {
+			if stw.server == nil || stw.server.Addr == "" {
+				return st.Message("serve: off")
+			}
+			return st.Message(fmt.Sprintf("serve: http://%s %s: %s", stw.server.Addr, ServerTokenHeader, stw.server.Token))
+		}
+		if stw.server != nil {
+			err := stw.server.Stop()
+			stw.server = nil
+			if err != nil {
+				return err
+			}
+		}
+		if strings.EqualFold(args[1], "off") {
+			return st.Message("serve: off")
+		}
+		srv, err := NewServer(stw)
+		if err != nil {
+			return err
+		}
+		err = srv.Start(args[1])
+		if err != nil {
+			return err
+		}
+		stw.server = srv
+		return st.Message(fmt.Sprintf("serve: http://%s %s: %s", srv.Addr, ServerTokenHeader, srv.Token))
+	case "watch":
+		if narg < 2 {
+			if stw.watcher == nil {
+				return st.Message("watch: off")
+			}
+			return st.Message(stw.watcher.String())
+		}
+		switch strings.ToLower(args[1]) {
+		default:
+			return st.Usage(ExHelp["watch"].UsageString())
+		case "on":
+			if stw.Frame == nil {
+				return errors.New(":watch no frame")
+			}
+			interval := watchinterval
+			if s, ok := argdict["INTERVAL"]; ok {
+				tmp, err := strconv.ParseFloat(s, 64)
+				if err != nil || tmp <= 0.0 {
+					return errors.New(fmt.Sprintf(":watch invalid interval %s", s))
+				}
+				interval = time.Duration(tmp * float64(time.Second))
+			}
+			if stw.watcher != nil {
+				stw.watcher.Stop()
+			}
+			w, err := NewWatcher(stw, interval)
+			if err != nil {
+				return err
+			}
+			stw.watcher = w
+			return st.Message(w.String())
+		case "off":
+			if stw.watcher != nil {
+				stw.watcher.Stop()
+				stw.watcher = nil
+			}
+			return st.Message("watch: off")
+		}
+	case "for", "endfor", "if", "else", "endif":
+		return errors.New(fmt.Sprintf(":%s can be used only in scripts (.strc, :source)", cname))
 	case "empty":
-		if usage {
-			return st.Usage(":empty")
+		input = nil
+	case "jobs":
+		return st.Message(stw.jobs.String())
+	case "cancel":
+		if narg < 2 {
+			return st.NotEnoughArgs(":cancel")
+		}
+		msgs := make([]string, 0)
+		for _, num := range SplitNums(strings.Join(args[1:], " ")) {
+			msg, err := stw.jobs.Cancel(num)
+			if err != nil {
+				return err
+			}
+			msgs = append(msgs, msg)
+		}
+		return st.Message(strings.Join(msgs, "\n"))
+	case "help":
+		if md, ok := argdict["MARKDOWN"]; ok {
+			if md == "" {
+				md = "stx.md"
+			}
+			if !filepath.IsAbs(md) {
+				md = filepath.Join(stw.Cwd, md)
+			}
+			err := WriteHelpMarkdown(md)
+			if err != nil {
+				return err
+			}
+			return st.Message(fmt.Sprintf("OUTPUT: %s", md))
+		}
+		if h, ok := argdict["HTML"]; ok {
+			if h == "" {
+				h = "stx.html"
+			}
+			if !filepath.IsAbs(h) {
+				h = filepath.Join(stw.Cwd, h)
+			}
+			err := WriteHelpHTML(h)
+			if err != nil {
+				return err
+			}
+			return st.Message(fmt.Sprintf("OUTPUT: %s", h))
 		}
-		stw.emptyexmodech()
+		if narg < 2 {
+			return stw.Help("")
+		}
+		return stw.Help(args[1])
 	}
 	if evaluated {
 		return nil
@@ -387,9 +601,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 	default:
 		return st.Message(fmt.Sprintf("no exmode command: %s", cname))
 	case "write":
-		if usage {
-			return st.Usage(":write")
-		}
 		if fn == "" {
 			stw.SaveFile(stw.Frame.Path)
 		} else {
@@ -404,9 +615,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			}
 		}
 	case "save":
-		if usage {
-			return st.Usage(":save filename {-u=.strc}")
-		}
 		if fn == "" {
 			return st.NotEnoughArgs(":save")
 		}
@@ -444,9 +652,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			stw.Rebase(fn)
 		}
 	case "increment":
-		if usage {
-			return st.Usage(":increment {times:1}")
-		}
 		if !bang && stw.Changed {
 			switch stw.Yna("CHANGED", "変更を保存しますか", "キャンセル") {
 			case 1:
@@ -484,36 +689,321 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			EditReadme(filepath.Dir(fn))
 		}
 	case "tag":
-		if usage {
-			return st.Usage(":tag name")
+		if _, ok := argdict["D"]; ok {
+			if narg < 2 {
+				return st.NotEnoughArgs(":tag -d")
+			}
+			for _, name := range args[1:] {
+				err := stw.DeleteTag(name)
+				if err != nil {
+					return err
+				}
+				stw.History(fmt.Sprintf("TAG DELETED: %s", name))
+			}
+			return nil
 		}
 		if narg < 2 {
 			return st.NotEnoughArgs(":tag")
 		}
 		name := args[1]
 		if !bang {
-			if _, exists := stw.taggedFrame[name]; exists {
+			if stw.TagExists(name) {
 				return errors.New(fmt.Sprintf("tag %s already exists", name))
 			}
 		}
-		stw.taggedFrame[name] = stw.Frame.Snapshot()
-	case "checkout":
-		if usage {
-			return st.Usage(":checkout name")
+		err := stw.SaveTag(name, stw.Frame.Snapshot())
+		if err != nil {
+			return err
 		}
+		stw.History(fmt.Sprintf("TAG: %s", stw.TagFileName(name)))
+	case "tags":
+		names := stw.TagNames()
+		if len(names) == 0 {
+			return st.Message("no tags")
+		}
+		var otp bytes.Buffer
+		for _, name := range names {
+			if st.FileExists(stw.TagFileName(name)) {
+				otp.WriteString(fmt.Sprintf("%s\n", name))
+			} else {
+				otp.WriteString(fmt.Sprintf("%s (not saved)\n", name))
+			}
+		}
+		return st.Message(strings.TrimSuffix(otp.String(), "\n"))
+	case "selset":
+		if narg < 2 {
+			return st.NotEnoughArgs(":selset")
+		}
+		switch strings.ToUpper(args[1]) {
+		default:
+			return errors.New(fmt.Sprintf(":selset: unknown operation %s", args[1]))
+		case "SAVE":
+			if narg < 3 {
+				return st.NotEnoughArgs(":selset save")
+			}
+			if len(stw.SelectNode) == 0 && len(stw.SelectElem) == 0 {
+				return errors.New(":selset save: nothing selected")
+			}
+			err := stw.SaveSelSet(args[2], bang)
+			if err != nil {
+				return err
+			}
+			stw.History(fmt.Sprintf("SELSET: %s (%d NODES, %d ELEMS) -> %s", args[2], len(stw.SelectNode), len(stw.SelectElem), stw.SelSetFileName()))
+		case "LOAD":
+			if narg < 3 {
+				return st.NotEnoughArgs(":selset load")
+			}
+			err := stw.LoadSelSet(args[2])
+			if err != nil {
+				return err
+			}
+			if pipe {
+				sender = &PipeValue{Nodes: stw.SelectNode, Elems: stw.SelectElem}
+			}
+		case "LIST":
+			sets, err := stw.ReadSelSets()
+			if err != nil {
+				return err
+			}
+			names, _ := stw.SelSetNames()
+			if len(names) == 0 {
+				return st.Message("no selsets")
+			}
+			var otp bytes.Buffer
+			for _, name := range names {
+				otp.WriteString(fmt.Sprintf("%s: %d NODES, %d ELEMS\n", name, len(sets[name].Nodes), len(sets[name].Elems)))
+			}
+			return st.Message(strings.TrimSuffix(otp.String(), "\n"))
+		case "DELETE":
+			if narg < 3 {
+				return st.NotEnoughArgs(":selset delete")
+			}
+			for _, name := range args[2:] {
+				err := stw.DeleteSelSet(name)
+				if err != nil {
+					return err
+				}
+				stw.History(fmt.Sprintf("SELSET DELETED: %s", name))
+			}
+		}
+	case "checkout":
 		if narg < 2 {
 			return st.NotEnoughArgs(":checkout")
 		}
-		name := args[1]
-		if f, exists := stw.taggedFrame[name]; exists {
-			stw.Frame = f
+		f, err := stw.TaggedFrame(args[1])
+		if err != nil {
+			return err
+		}
+		stw.Deselect()
+		stw.Frame = f
+	case "diff":
+		if narg < 2 {
+			return st.NotEnoughArgs(":diff")
+		}
+		from, err := stw.TaggedFrame(args[1])
+		if err != nil {
+			return err
+		}
+		to := stw.Frame
+		if narg >= 3 {
+			to, err = stw.TaggedFrame(args[2])
+			if err != nil {
+				return err
+			}
+		}
+		return st.Message(strings.TrimSuffix(DiffFrame(from, to).String(), "\n"))
+	case "combination":
+		if _, ok := argdict["CLEAR"]; ok {
+			stw.ClearCombinations()
+			return nil
+		}
+		if narg < 2 {
+			return st.Message(stw.CombinationString())
+		}
+		for _, c := range args[1:] {
+			lc, err := ParseLoadCombination(c)
+			if err != nil {
+				return err
+			}
+			err = stw.AddLoadCombination(lc)
+			if err != nil {
+				return err
+			}
+		}
+		return st.Message(stw.CombinationString())
+	case "envelope":
+		name := "ENV"
+		if n, ok := argdict["NAME"]; ok && n != "" {
+			name = strings.ToUpper(n)
+		}
+		pers := make([]string, 0)
+		if narg >= 2 {
+			for _, p := range args[1:] {
+				pers = append(pers, strings.ToUpper(p))
+			}
 		} else {
-			return errors.New(fmt.Sprintf("tag %s doesn't exist", name))
+			for _, lc := range stw.combinations {
+				pers = append(pers, lc.Name)
+			}
 		}
-	case "read":
-		if usage {
-			return st.Usage(":read {type} filename")
+		if len(pers) == 0 {
+			return errors.New(":envelope: no combinations")
+		}
+		err := stw.AddEnvelope(&Envelope{Name: name, Periods: pers})
+		if err != nil {
+			return err
+		}
+		return st.Message(stw.CombinationString())
+	case "export":
+		if narg < 2 {
+			return st.NotEnoughArgs(":export")
+		}
+		format := strings.ToLower(args[1])
+		var efn string
+		if narg < 3 {
+			efn = st.Ce(stw.Frame.Path, fmt.Sprintf(".%s", format))
+		} else {
+			efn = exportfilename(stw.CompleteFileName(args[2]), format)
+			if filepath.Dir(efn) == "." {
+				efn = filepath.Join(stw.Cwd, efn)
+			}
+		}
+		periods := []string{stw.Frame.Show.Period}
+		if p, ok := argdict["PERIOD"]; ok && p != "" {
+			periods = strings.Split(strings.ToUpper(p), ",")
+		}
+		for _, per := range periods {
+			if !hasResult(stw.Frame, per) {
+				return errors.New(fmt.Sprintf(":export no result for period %s", per))
+			}
+		}
+		var fields []string
+		if f, ok := argdict["FIELDS"]; ok && f != "" {
+			fields = strings.Split(f, ",")
+		}
+		nodes, els := stw.ExportTargets()
+		if _, ok := argdict["NODE"]; ok {
+			if len(nodes) == 0 {
+				nodes = ElemNodes(els)
+			}
+			els = nil
+		}
+		nnode, nelem, err := Export(efn, format, nodes, els, periods, fields)
+		if err != nil {
+			return err
+		}
+		return st.Message(fmt.Sprintf("EXPORT: %s (%d nodes, %d elems, PERIOD: %s)", efn, nnode, nelem, strings.Join(periods, ",")))
+	case "sectioneditor":
+		stw.ShowSectionEditor()
+	case "ai":
+		if b, ok := argdict["BOUNDARY"]; ok && b != "" {
+			err := SetBoundary(stw.Frame, b)
+			if err != nil {
+				return err
+			}
+			stw.Snapshot()
+		}
+		err := stw.aiparam.Set(argdict)
+		if err != nil {
+			return err
+		}
+		if _, ok := argdict["PANEL"]; ok {
+			stw.ShowAiDistribution()
+			return nil
+		}
+		ad, err := NewAiDistribution(stw.Frame, stw.aiparam)
+		if err != nil {
+			return err
+		}
+		if _, ok := argdict["APPLY"]; ok {
+			err := stw.ApplyAiLoads(ad)
+			if err != nil {
+				return err
+			}
+			stw.History("AI: horizontal loads of X and Y are regenerated")
+		}
+		return st.Message(ad.String())
+	case "storyreport":
+		pstr := "X,Y"
+		if p, ok := argdict["PERIOD"]; ok && p != "" {
+			pstr = p
+		}
+		periods, err := ParseStoryPeriods(pstr)
+		if err != nil {
+			return err
+		}
+		rows, err := StoryReport(stw.Frame, periods)
+		if err != nil {
+			return err
+		}
+		if c, ok := argdict["CSV"]; ok {
+			cfn := st.Ce(stw.Frame.Path, ".csv")
+			if c != "" {
+				cfn = exportfilename(c, "csv")
+				if filepath.Dir(cfn) == "." {
+					cfn = filepath.Join(stw.Cwd, cfn)
+				}
+			}
+			err := WriteStoryReport(cfn, rows)
+			if err != nil {
+				return err
+			}
+			stw.History(fmt.Sprintf("CSV: %s", cfn))
 		}
+		return st.Message(StoryReportString(rows))
+	case "table":
+		kind := TABLE_ELEM
+		if narg >= 2 {
+			switch {
+			case abbrev.For("n/ode", strings.ToLower(args[1])):
+				kind = TABLE_NODE
+			case abbrev.For("e/lem", strings.ToLower(args[1])):
+				kind = TABLE_ELEM
+			default:
+				return st.Usage(ExHelp["table"].UsageString())
+			}
+		}
+		if stw.table != nil {
+			stw.table.window.Close()
+		}
+		stw.table = NewResultTable(stw, kind)
+		stw.table.Show()
+		stw.table.Sync()
+	case "compare":
+		page := 1
+		if p, ok := argdict["PAGE"]; ok {
+			tmp, err := strconv.ParseInt(p, 10, 64)
+			if err != nil {
+				return err
+			}
+			page = int(tmp)
+			if fn == "" {
+				if stw.compare == nil {
+					return errors.New(":compare no comparison")
+				}
+				return st.Message(stw.compare.Report(page))
+			}
+		}
+		if fn == "" {
+			stw.compare = nil
+			stw.Redraw()
+			return nil
+		}
+		if filepath.Ext(fn) == "" {
+			fn += ".inp"
+		}
+		if !st.FileExists(fn) {
+			return errors.New(fmt.Sprintf("file %s doesn't exist", fn))
+		}
+		other := st.NewFrame()
+		err := other.ReadInp(fn, []float64{0.0, 0.0, 0.0}, 0.0, false)
+		if err != nil {
+			return err
+		}
+		stw.compare = CompareFrame(stw.Frame, other)
+		stw.Redraw()
+		return st.Message(stw.compare.Report(page))
+	case "read":
 		if narg < 2 {
 			return st.NotEnoughArgs(":read")
 		}
@@ -537,11 +1027,17 @@ func (stw *Window) excommand(command string, pipe bool) error {
 						mode = st.AddSearchResult
 					}
 				}
+				nread := 0
 				for _, ext := range []string{".otl", ".ohx", ".ohy"} {
 					err := stw.Frame.ReadResult(st.Ce(stw.Frame.Path, ext), uint(mode))
 					if err != nil {
 						stw.ErrorMessage(err, ERROR)
+						continue
 					}
+					nread++
+				}
+				if nread > 0 {
+					stw.ResultsRead()
 				}
 			default:
 				err := stw.ReadFile(fn)
@@ -573,11 +1069,14 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			if err != nil {
 				return err
 			}
+			stw.ResultsRead()
 		case abbrev.For("s/rcan", t):
 			err := stw.Frame.ReadRat(fn)
 			if err != nil {
 				return err
 			}
+			stw.resultgen++
+			stw.Redraw()
 		case abbrev.For("l/ist", t):
 			err := stw.Frame.ReadLst(fn)
 			if err != nil {
@@ -612,9 +1111,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		// 	aliases = al
 		}
 	case "insert":
-		if usage {
-			return st.Usage(":insert filename angle(deg)")
-		}
 		if narg > 2 && len(stw.SelectNode) >= 1 {
 			angle, err := strconv.ParseFloat(args[2], 64)
 			if err != nil {
@@ -627,18 +1123,12 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			}
 		}
 	case "propsect":
-		if usage {
-			return st.Usage(":propsect filename")
-		}
 		err := stw.AddPropAndSect(fn)
 		stw.Snapshot()
 		if err != nil {
 			return err
 		}
 	case "writeoutput":
-		if usage {
-			return st.Usage(":writeoutput filename period")
-		}
 		if narg < 3 {
 			return st.NotEnoughArgs(":wo")
 		}
@@ -653,9 +1143,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			return err
 		}
 	case "writereaction":
-		if usage {
-			return st.Usage(":writereaction filename direction")
-		}
 		if narg < 3 {
 			return st.NotEnoughArgs(":wr")
 		}
@@ -689,9 +1176,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			return err
 		}
 	case "writekijun":
-		if usage {
-			return st.Usage(":writekijun filename")
-		}
 		if fn == "" {
 			fn = st.Ce(stw.Frame.Path, ".kjn")
 		}
@@ -700,9 +1184,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			return err
 		}
 	case "zoubundisp":
-		if usage {
-			return st.Usage(":zoubundisp period direction")
-		}
 		if narg < 3 {
 			return st.NotEnoughArgs(":zoubundisp")
 		}
@@ -724,9 +1205,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			return err
 		}
 	case "zoubunreaction":
-		if usage {
-			return st.Usage(":zoubunreaction period direction")
-		}
 		if narg < 3 {
 			return st.NotEnoughArgs(":zoubunreaction")
 		}
@@ -748,9 +1226,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			return err
 		}
 	case "weightcopy":
-		if usage {
-			return st.Usage(":weightcopy {-si}")
-		}
 		wgt := filepath.Join(stw.Home, "hogtxt.wgt")
 		if fn == "" {
 			fn = st.Ce(stw.Frame.Path, ".wgt")
@@ -788,23 +1263,24 @@ func (stw *Window) excommand(command string, pipe bool) error {
 	// 		return err
 	// 	}
 	case "svg":
-		if usage {
-			return st.Usage(":svg filename")
-		}
 		err := stw.PrintSVG(fn)
 		if err != nil {
 			return err
 		}
-	// case "check":
-	// 	if usage {
-	// 		return st.Usage(":check")
-	// 	}
-	// 	checkframe(stw)
-	// 	return st.Message("CHECKED")
-	case "elemduplication":
-		if usage {
-			return st.Usage(":elemduplication {-ignoresect=code}")
+	case "check":
+		findings := CheckFrame(stw.Frame)
+		stw.ShowFindings("check", findings)
+		nodes, els := FindingsEntities(findings)
+		if pipe {
+			sender = &PipeValue{Nodes: nodes, Elems: els}
+		} else if len(findings) > 0 {
+			stw.Deselect()
+			stw.SelectNode = nodes
+			stw.SelectElem = els
+			stw.Redraw()
 		}
+		return st.Message(FindingsString(findings))
+	case "elemduplication":
 		stw.Deselect()
 		var isect []int
 		var m bytes.Buffer
@@ -829,9 +1305,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		return st.Message(m.String())
 	case "intersectall":
-		if usage {
-			return st.Usage(":intersectall")
-		}
 		l := len(stw.SelectElem)
 		if l <= 1 {
 			return nil
@@ -864,9 +1337,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}()
 		stw.Snapshot()
 	case "srcal":
-		if usage {
-			return st.Usage(":srcal {-fbold} {-noreload} {-tmp}")
-		}
 		var m bytes.Buffer
 		cond := st.NewCondition()
 		if _, ok := argdict["FBOLD"]; ok {
@@ -886,10 +1356,51 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.Frame.SectionRateCalculation(otp, "L", "X", "X", "Y", "Y", -1.0, cond)
 		return st.Message(m.String())
-	case "nminteraction":
-		if usage {
-			return st.Usage(":nminteraction sectcode")
+	case "rate":
+		cond := st.NewCondition()
+		if _, ok := argdict["FBOLD"]; ok {
+			cond.FbOld = true
+		}
+		flags := stw.Frame.Show.SrcanRate
+		if narg >= 2 {
+			var otp bytes.Buffer
+			for _, enum := range SplitNums(strings.Join(args[1:], " ")) {
+				el, ok := stw.Frame.Elems[enum]
+				if !ok {
+					return errors.New(fmt.Sprintf(":rate ELEM %d doesn't exist", enum))
+				}
+				rb, err := NewRateBreakdown(stw.Frame, el, flags, cond)
+				if err != nil {
+					return err
+				}
+				otp.WriteString(fmt.Sprintf("%s\n", rb.Report()))
+			}
+			return st.Message(strings.TrimSuffix(otp.String(), "\n"))
 		}
+		top := 50
+		if t, ok := argdict["TOP"]; ok && t != "" {
+			tmp, err := strconv.ParseInt(t, 10, 64)
+			if err != nil {
+				return err
+			}
+			top = int(tmp)
+		}
+		els := make([]*st.Elem, 0)
+		for _, el := range stw.SelectElem {
+			if el != nil {
+				els = append(els, el)
+			}
+		}
+		if len(els) == 0 {
+			els = sortedelems(stw.Frame)
+		}
+		rbs := WorstRates(stw.Frame, els, top, flags, cond)
+		if len(rbs) == 0 {
+			return errors.New(":rate no elem has allowables and stresses")
+		}
+		stw.ShowRates(rbs)
+		return st.Message(fmt.Sprintf("MAX: %s", rbs[0]))
+	case "nminteraction":
 		if narg < 2 {
 			return st.NotEnoughArgs(":nminteraction")
 		}
@@ -897,50 +1408,88 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		if err != nil {
 			return err
 		}
-		if al, ok := stw.Frame.Allows[int(tmp)]; ok {
-			var otp bytes.Buffer
-			var m bytes.Buffer
-			cond := st.NewCondition()
-			ndiv := 100
-			if nd, ok := argdict["NDIV"]; ok {
-				if nd != "" {
-					m.WriteString(fmt.Sprintf("NDIV: %s", nd))
-					tmp, err := strconv.ParseInt(nd, 10, 64)
-					if err == nil {
-						ndiv = int(tmp)
-					}
+		snum := int(tmp)
+		al, ok := stw.Frame.Allows[snum]
+		if !ok {
+			return errors.New(fmt.Sprintf(":nminteraction SECT %d has no allowable", snum))
+		}
+		var m bytes.Buffer
+		ndiv := 100
+		if nd, ok := argdict["NDIV"]; ok {
+			if nd != "" {
+				m.WriteString(fmt.Sprintf("NDIV: %s\n", nd))
+				tmp, err := strconv.ParseInt(nd, 10, 64)
+				if err == nil {
+					ndiv = int(tmp)
 				}
 			}
-			filename := "nmi.txt"
-			if o, ok := argdict["OUTPUT"]; ok {
-				if o != "" {
-					m.WriteString(fmt.Sprintf("OUTPUT: %s", o))
-					filename = o
+		}
+		filename := "nmi.txt"
+		if o, ok := argdict["OUTPUT"]; ok {
+			if o != "" {
+				m.WriteString(fmt.Sprintf("OUTPUT: %s\n", o))
+				filename = o
+			}
+		}
+		strong := true
+		if a, ok := argdict["AXIS"]; ok && strings.EqualFold(a, "weak") {
+			strong = false
+		}
+		plot := &NMPlot{Strong: strong}
+		curves := make([]*NMCurve, 0)
+		if nstr, ok := argdict["N"]; ok {
+			n, err := strconv.ParseFloat(nstr, 64)
+			if err != nil {
+				return err
+			}
+			alpha := 1.0
+			if a, ok := argdict["ALPHA"]; ok {
+				alpha, err = strconv.ParseFloat(a, 64)
+				if err != nil {
+					return err
 				}
 			}
-			switch al.(type) {
-			default:
-				return nil
-			case *st.RCColumn:
-				nmax := al.(*st.RCColumn).Nmax(cond)
-				nmin := al.(*st.RCColumn).Nmin(cond)
-				for i := 0; i <= ndiv; i++ {
-					cond.N = nmax - float64(i)*(nmax-nmin)/float64(ndiv)
-					otp.WriteString(fmt.Sprintf("%.5f %.5f\n", cond.N, al.Ma(cond)))
+			for _, per := range []string{"L", "S"} {
+				c, err := NMContour(al, per, n, alpha, ndiv)
+				if err != nil {
+					return err
 				}
+				curves = append(curves, c)
 			}
-			w, err := os.Create(filename)
-			defer w.Close()
-			if err != nil {
-				return err
+			plot.Contour = true
+			plot.Title = fmt.Sprintf("SECT %d Mx-My N=%.3f", snum, n)
+		} else {
+			for _, per := range []string{"L", "S"} {
+				c, err := NMInteraction(al, per, strong, ndiv)
+				if err != nil {
+					return err
+				}
+				curves = append(curves, c)
+			}
+			if strong {
+				plot.Title = fmt.Sprintf("SECT %d N-Mx", snum)
+			} else {
+				plot.Title = fmt.Sprintf("SECT %d N-My", snum)
 			}
-			otp.WriteTo(w)
-			return st.Message(m.String())
 		}
-	case "gohanlst":
-		if usage {
-			return st.Usage(":gohanlst factor sectcode...")
+		plot.Curves = curves
+		els := make([]*st.Elem, 0)
+		for _, el := range stw.SelectElem {
+			if el != nil && el.Sect.Num == snum {
+				els = append(els, el)
+			}
+		}
+		plot.Demands = NMDemands(els)
+		err = WriteNMCurves(filename, curves)
+		if err != nil {
+			return err
 		}
+		if _, ok := argdict["NOPLOT"]; !ok {
+			stw.ShowNMPlot(plot)
+		}
+		m.WriteString(fmt.Sprintf("%d DEMANDS OF %d ELEMS", len(plot.Demands), len(els)))
+		return st.Message(m.String())
+	case "gohanlst":
 		if narg < 3 {
 			return st.NotEnoughArgs(":gohanlst")
 		}
@@ -967,38 +1516,18 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		w, err := os.Create(filepath.Join(stw.Cwd, "gohan.lst"))
 		defer w.Close()
 		if err != nil {
-			return err
-		}
-		otp = st.AddCR(otp)
-		otp.WriteTo(w)
-	case "kaberyo":
-		if usage {
-			return st.Usage(":kaberyo")
-		}
-		var els []*st.Elem
-		if stw.SelectElem == nil || len(stw.SelectElem) == 0 {
-			enum := 0
-			els = make([]*st.Elem, 0)
-		ex_kaberyo:
-			for {
-				select {
-				case <-time.After(time.Second):
-					break ex_kaberyo
-				case <-stw.exmodeend:
-					break ex_kaberyo
-				case el := <-stw.exmodech:
-					if el, ok := el.(*st.Elem); ok {
-						els = append(els, el)
-						enum++
-					}
-				}
-			}
-			if enum == 0 {
-				return errors.New(":kaberyo no selected elem")
+			return err
+		}
+		otp = st.AddCR(otp)
+		otp.WriteTo(w)
+	case "kaberyo":
+		els := stw.SelectElem
+		if len(els) == 0 {
+			pels, err := input.ElemSet(":kaberyo")
+			if err != nil {
+				return err
 			}
-			els = els[:enum]
-		} else {
-			els = stw.SelectElem
+			els = pels
 		}
 		var m bytes.Buffer
 		var props []int
@@ -1063,9 +1592,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		m.WriteString(fmt.Sprintf("COLUMN: %.3f WALL: %.3f TOTAL: %.3f", sumcol, sumwall, total))
 		return st.Message(m.String())
 	case "facts":
-		if usage {
-			return st.Usage(":facts {-skipany=code} {-skipall=code}")
-		}
 		var m bytes.Buffer
 		fn = st.Ce(stw.Frame.Path, ".fes")
 		var skipany, skipall []int
@@ -1096,9 +1622,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		m.WriteString(fmt.Sprintf("Output: %s", fn))
 		return st.Message(m.String())
 	case "amountprop":
-		if usage {
-			return st.Usage(":amountprop propcode")
-		}
 		if narg < 2 {
 			return st.NotEnoughArgs(":amountprop")
 		}
@@ -1112,9 +1635,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			return err
 		}
 	case "amountlst":
-		if usage {
-			return st.Usage(":amountlst propcode")
-		}
 		var sects []int
 		if narg < 2 {
 			if _, ok := argdict["ALL"]; ok {
@@ -1145,14 +1665,9 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			return err
 		}
 	case "node":
-		if usage {
-			var m bytes.Buffer
-			m.WriteString(":node nnum\n")
-			m.WriteString(":node [x,y,z] [>,<,=] coord\n")
-			m.WriteString(":node {confed/pinned/fixed/free}\n")
-			m.WriteString(":node pile num")
-			return st.Usage(m.String())
-		}
+		// the selection is restored if the query fails, so that it can be refined again
+		current := stw.SelectNode
+		currentelem := stw.SelectElem
 		stw.Deselect()
 		var f func(*st.Node) bool
 		if narg >= 2 {
@@ -1161,8 +1676,16 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			numstr := regexp.MustCompile("^[0-9, ]+$")
 			pilestr := regexp.MustCompile("^ *PILE *([0-9, ]+)$")
 			switch {
+			case IsQuery(condition):
+				fallthrough
 			default:
-				return errors.New(":node: unknown format")
+				ns, err := stw.QueryNode(condition, current)
+				if err != nil {
+					stw.SelectNode = current
+					stw.SelectElem = currentelem
+					return err
+				}
+				stw.SelectNode = ns
 			case numstr.MatchString(condition):
 				nnums := SplitNums(condition)
 				stw.SelectNode = make([]*st.Node, len(nnums))
@@ -1298,16 +1821,9 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			stw.SelectNode = stw.SelectNode[:num]
 		}
 		if pipe {
-			num := len(stw.SelectNode)
-			sender = make([]interface{}, num)
-			for i:=0; i<num; i++ {
-				sender[i] = stw.SelectNode[i]
-			}
+			sender = &PipeValue{Nodes: stw.SelectNode}
 		}
 	case "conf":
-		if usage {
-			return st.Usage(":conf [0,1]{6}")
-		}
 		lis := make([]bool, 6)
 		if len(args[1]) >= 6 {
 			for i := 0; i < 6; i++ {
@@ -1337,9 +1853,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			return st.NotEnoughArgs(":conf")
 		}
 	case "pile":
-		if usage {
-			return st.Usage(":pile pilecode")
-		}
 		if stw.SelectNode == nil || len(stw.SelectNode) == 0 {
 			return errors.New(":pile no selected node")
 		}
@@ -1362,9 +1875,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			return errors.New(fmt.Sprintf(":pile PILE %d doesn't exist", val))
 		}
 	case "xscale":
-		if usage {
-			return st.Usage(":xscale factor coord")
-		}
 		if stw.SelectNode == nil || len(stw.SelectNode) == 0 {
 			return errors.New(":xscale no selected node")
 		}
@@ -1387,9 +1897,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.Snapshot()
 	case "yscale":
-		if usage {
-			return st.Usage(":yscale factor coord")
-		}
 		if stw.SelectNode == nil || len(stw.SelectNode) == 0 {
 			return errors.New(":yscale no selected node")
 		}
@@ -1412,9 +1919,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.Snapshot()
 	case "zscale":
-		if usage {
-			return st.Usage(":zscale factor coord")
-		}
 		if stw.SelectNode == nil || len(stw.SelectNode) == 0 {
 			return errors.New(":zscale no selected node")
 		}
@@ -1437,9 +1941,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.Snapshot()
 	case "pload":
-		if usage {
-			return st.Usage(":pload position value")
-		}
 		if stw.SelectNode == nil || len(stw.SelectNode) == 0 {
 			return errors.New(":pload no selected node")
 		}
@@ -1462,17 +1963,25 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.Snapshot()
 	case "elem":
-		if usage {
-			return st.Usage(":elem [elemcode,sect sectcode,etype]")
-		}
+		// the selection is restored if the query fails, so that it can be refined again
+		current := stw.SelectElem
+		currentnode := stw.SelectNode
 		stw.Deselect()
 		var f func(*st.Elem) bool
 		if narg >= 2 {
 			condition := strings.ToUpper(strings.Join(args[1:], " "))
 			numstr := regexp.MustCompile("^[0-9, ]+$")
 			switch {
+			case IsQuery(condition):
+				fallthrough
 			default:
-				return errors.New(":elem: unknown format")
+				els, err := stw.QueryElem(condition, current)
+				if err != nil {
+					stw.SelectNode = currentnode
+					stw.SelectElem = current
+					return err
+				}
+				stw.SelectElem = els
 			case numstr.MatchString(condition):
 				enums := SplitNums(condition)
 				stw.SelectElem = make([]*st.Elem, len(enums))
@@ -1559,16 +2068,9 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			stw.SelectElem = stw.SelectElem[:num]
 		}
 		if pipe {
-			num := len(stw.SelectElem)
-			sender = make([]interface{}, num)
-			for i:=0; i<num; i++ {
-				sender[i] = stw.SelectElem[i]
-			}
+			sender = &PipeValue{Elems: stw.SelectElem}
 		}
 	case "fence":
-		if usage {
-			return st.Usage(":fence axis coord {-plate}")
-		}
 		if narg < 3 {
 			return st.NotEnoughArgs(":fence")
 		}
@@ -1613,59 +2115,28 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.SelectElem = stw.Frame.Fence(axis, val, plate)
 		if pipe {
-			num := len(stw.SelectElem)
-			sender = make([]interface{}, num)
-			for i:=0; i<num; i++ {
-				sender[i] = stw.SelectElem[i]
-			}
+			sender = &PipeValue{Elems: stw.SelectElem}
 		}
 	case "filter":
-		if usage {
-			return st.Usage(":filter condition")
-		}
 		tmpels, err := stw.FilterElem(stw.SelectElem, strings.Join(args[1:], " "))
 		if err != nil {
 			return err
 		}
 		stw.SelectElem = tmpels
 		if pipe {
-			num := len(stw.SelectElem)
-			sender = make([]interface{}, num)
-			for i:=0; i<num; i++ {
-				sender[i] = stw.SelectElem[i]
-			}
+			sender = &PipeValue{Elems: stw.SelectElem}
 		}
 	case "bond":
-		if usage {
-			return st.Usage(":bond [pin,rigid,[01_t]{6}] [upper,lower,sect sectcode]")
-		}
 		if narg < 2 {
 			return st.NotEnoughArgs(":bond")
 		}
-		var els []*st.Elem
-		if stw.SelectElem == nil || len(stw.SelectElem) == 0 {
-			enum := 0
-			els = make([]*st.Elem, 0)
-		ex_bond:
-			for {
-				select {
-				case <-time.After(time.Second):
-					break ex_bond
-				case <-stw.exmodeend:
-					break ex_bond
-				case el := <-stw.exmodech:
-					if el, ok := el.(*st.Elem); ok {
-						els = append(els, el)
-						enum++
-					}
-				}
-			}
-			if enum == 0 {
-				return errors.New(":bond no selected elem")
+		els := stw.SelectElem
+		if len(els) == 0 {
+			pels, err := input.ElemSet(":bond")
+			if err != nil {
+				return err
 			}
-			els = els[:enum]
-		} else {
-			els = stw.SelectElem
+			els = pels
 		}
 		lis := make([]bool, 6)
 		pat := regexp.MustCompile("[01_t]{6}")
@@ -1733,9 +2204,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.Snapshot()
 	case "section+":
-		if usage {
-			return st.Usage(":section+ value")
-		}
 		if narg < 2 {
 			return st.NotEnoughArgs(":section+")
 		}
@@ -1757,9 +2225,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.Snapshot()
 	case "cang":
-		if usage {
-			return st.Usage(":cang val")
-		}
 		if narg < 2 {
 			return st.NotEnoughArgs(":cang")
 		}
@@ -1767,30 +2232,13 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		if err != nil {
 			return err
 		}
-		var els []*st.Elem
-		if stw.SelectElem == nil || len(stw.SelectElem) == 0 {
-			enum := 0
-			els = make([]*st.Elem, 0)
-		ex_cang:
-			for {
-				select {
-				case <-time.After(time.Second):
-					break ex_cang
-				case <-stw.exmodeend:
-					break ex_cang
-				case el := <-stw.exmodech:
-					if el, ok := el.(*st.Elem); ok {
-						els = append(els, el)
-						enum++
-					}
-				}
-			}
-			if enum == 0 {
-				return errors.New(":cang no selected elem")
+		els := stw.SelectElem
+		if len(els) == 0 {
+			pels, err := input.ElemSet(":cang")
+			if err != nil {
+				return err
 			}
-			els = els[:enum]
-		} else {
-			els = stw.SelectElem
+			els = pels
 		}
 		for _, el := range els {
 			if !el.IsLineElem() {
@@ -1801,9 +2249,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.Snapshot()
 	case "axis2cang":
-		if usage {
-			return st.Usage(":axis2cang n1 n2 [strong,weak]")
-		}
 		if narg < 4 {
 			return st.NotEnoughArgs(":axis2cang")
 		}
@@ -1846,33 +2291,13 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.Snapshot()
 	case "invert":
-		if usage {
-			return st.Usage(":invert")
-		}
-		var els []*st.Elem
-		if stw.SelectElem == nil || len(stw.SelectElem) == 0 {
-			enum := 0
-			els = make([]*st.Elem, 0)
-		ex_invert:
-			for {
-				select {
-				case <-time.After(time.Second):
-					break ex_invert
-				case <-stw.exmodeend:
-					break ex_invert
-				case el := <-stw.exmodech:
-					if el, ok := el.(*st.Elem); ok {
-						els = append(els, el)
-						enum++
-					}
-				}
-			}
-			if enum == 0 {
-				return errors.New(":invert no selected elem")
+		els := stw.SelectElem
+		if len(els) == 0 {
+			pels, err := input.ElemSet(":invert")
+			if err != nil {
+				return err
 			}
-			els = els[:enum]
-		} else {
-			els = stw.SelectElem
+			els = pels
 		}
 		for _, el := range els {
 			el.Invert()
@@ -1917,39 +2342,16 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		m.WriteString(fmt.Sprintf("X: %.3f Y: %.3f Z: %.3f F: %.3f", vec[0], vec[1], vec[2], v))
 		return st.Message(m.String())
 	case "prestress":
-		if usage {
-			return st.Usage(":prestress value")
-		}
 		if narg < 2 {
 			return st.NotEnoughArgs(":prestress")
 		}
-		var els []*st.Elem
-		if stw.SelectElem == nil || len(stw.SelectElem) == 0 {
-			enum := 0
-			els = make([]*st.Elem, 0)
-		ex_prestress:
-			for {
-				select {
-				case <-time.After(time.Second):
-					break ex_prestress
-				case <-stw.exmodeend:
-					break ex_prestress
-				case el := <-stw.exmodech:
-					if el == nil {
-						break ex_prestress
-					}
-					if el, ok := el.(*st.Elem); ok {
-						els = append(els, el)
-						enum++
-					}
-				}
-			}
-			if enum == 0 {
-				return errors.New(":prestress no selected elem")
+		els := stw.SelectElem
+		if len(els) == 0 {
+			pels, err := input.ElemSet(":prestress")
+			if err != nil {
+				return err
 			}
-			els = els[:enum]
-		} else {
-			els = stw.SelectElem
+			els = pels
 		}
 		val, err := strconv.ParseFloat(args[1], 64)
 		if err != nil {
@@ -1963,39 +2365,16 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.Snapshot()
 	case "thermal":
-		if usage {
-			return st.Usage(":thermal tmp[℃]")
-		}
 		if narg < 2 {
 			return st.NotEnoughArgs(":thermal")
 		}
-		var els []*st.Elem
-		if stw.SelectElem == nil || len(stw.SelectElem) == 0 {
-			enum := 0
-			els = make([]*st.Elem, 0)
-		ex_thermal:
-			for {
-				select {
-				case <-time.After(time.Second):
-					break ex_thermal
-				case <-stw.exmodeend:
-					break ex_thermal
-				case el := <-stw.exmodech:
-					if el == nil {
-						break ex_thermal
-					}
-					if el, ok := el.(*st.Elem); ok {
-						els = append(els, el)
-						enum++
-					}
-				}
-			}
-			if enum == 0 {
-				return errors.New(":thermal no selected elem")
+		els := stw.SelectElem
+		if len(els) == 0 {
+			pels, err := input.ElemSet(":thermal")
+			if err != nil {
+				return err
 			}
-			els = els[:enum]
-		} else {
-			els = stw.SelectElem
+			els = pels
 		}
 		tmp, err := strconv.ParseFloat(args[1], 64)
 		if err != nil {
@@ -2027,24 +2406,15 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		return st.Message(m.String())
 	case "divide":
 		if narg < 2 {
-			if usage {
-				return st.Usage(":divide [mid, n, elem, ons, axis, length]")
-			}
 			return st.NotEnoughArgs(":divide")
 		}
 		var divfunc func(*st.Elem) ([]*st.Node, []*st.Elem, error)
 		switch strings.ToLower(args[1]) {
 		case "mid":
-			if usage {
-				return st.Usage(":divide mid")
-			}
 			divfunc = func(el *st.Elem) ([]*st.Node, []*st.Elem, error) {
 				return el.DivideAtMid(EPS)
 			}
 		case "n":
-			if usage {
-				return st.Usage(":divide n div")
-			}
 			if narg < 3 {
 				return st.NotEnoughArgs(":divide n")
 			}
@@ -2057,9 +2427,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 				return el.DivideInN(ndiv, EPS)
 			}
 		case "elem":
-			if usage {
-				return st.Usage(":divide elem (eps)")
-			}
 			eps := EPS
 			if narg >= 3 {
 				val, err := strconv.ParseFloat(args[2], 64)
@@ -2072,9 +2439,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 				return nil, els, err
 			}
 		case "ons":
-			if usage {
-				return st.Usage(":divide ons (eps)")
-			}
 			eps := EPS
 			if narg >= 3 {
 				val, err := strconv.ParseFloat(args[2], 64)
@@ -2086,9 +2450,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 				return el.DivideAtOns(eps)
 			}
 		case "axis":
-			if usage {
-				return st.Usage(":divide axis [x, y, z] coord")
-			}
 			if narg < 4 {
 				return st.NotEnoughArgs(":divide axis")
 			}
@@ -2111,9 +2472,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 				return el.DivideAtAxis(axis, val, EPS)
 			}
 		case "length":
-			if usage {
-				return st.Usage(":divide length l")
-			}
 			if narg < 3 {
 				return st.NotEnoughArgs(":divide length")
 			}
@@ -2150,9 +2508,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		stw.SelectElem = tmpels[:enum]
 		stw.Snapshot()
 	case "section":
-		if usage {
-			return st.Usage(":section sectcode {-nodisp}")
-		}
 		nodisp := false
 		if _, ok := argdict["NODISP"]; ok {
 			nodisp = true
@@ -2163,7 +2518,7 @@ func (stw *Window) excommand(command string, pipe bool) error {
 					stw.SectionData(stw.SelectElem[0].Sect)
 				}
 				if pipe {
-					sender = []interface{}{stw.SelectElem[0].Sect}
+					sender = &PipeValue{Sects: []*st.Sect{stw.SelectElem[0].Sect}}
 				}
 				return nil
 			}
@@ -2201,11 +2556,7 @@ func (stw *Window) excommand(command string, pipe bool) error {
 				stw.SectionData(sects[0])
 			}
 			if pipe {
-				num := len(sects)
-				sender = make([]interface{}, num)
-				for i:=0; i<num; i++ {
-					sender[i] = sects[i]
-				}
+				sender = &PipeValue{Sects: sects}
 			}
 		default:
 			tmp, err := strconv.ParseInt(args[1], 10, 64)
@@ -2215,36 +2566,26 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			snum := int(tmp)
 			if sec, ok := stw.Frame.Sects[snum]; ok {
 				if narg >= 3 && args[2] == "<-" {
-					select {
-					case <-time.After(time.Second):
-						break
-					case al := <-stw.exmodech:
-						if al == nil {
-							break
-						}
-						switch al := al.(type) {
-						case st.Shape:
-							if sec.HasArea(0) {
-								sec.Figs[0].SetShapeProperty(al)
-								sec.Name = al.Description()
-							}
-						}
+					al, err := input.PipedShape(":section <-")
+					if err != nil {
+						return err
+					}
+					if sec.HasArea(0) {
+						sec.Figs[0].SetShapeProperty(al)
+						sec.Name = al.Description()
 					}
 				}
 				if !nodisp {
 					stw.SectionData(sec)
 				}
 				if pipe {
-					sender = []interface{}{sec}
+					sender = &PipeValue{Sects: []*st.Sect{sec}}
 				}
 			} else {
 				return errors.New(fmt.Sprintf(":section SECT %d doesn't exist", snum))
 			}
 		}
 	case "thick":
-		if usage {
-			return st.Usage(":thick nfig val")
-		}
 		if narg < 3 {
 			return st.NotEnoughArgs(":thick")
 		}
@@ -2257,31 +2598,19 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			return err
 		}
 		ind := int(tmp) - 1
-		select {
-		case <-time.After(time.Second):
-			break
-		case sec := <-stw.exmodech:
-			if sec == nil {
-				break
-			}
-			if sec, ok := sec.(*st.Sect); ok {
-				if sec.HasThick(ind) {
-					sec.Figs[ind].Value["THICK"] = val
-				}
-			}
+		sec, err := input.PipedSect(":thick")
+		if err != nil {
+			return err
+		}
+		if sec.HasThick(ind) {
+			sec.Figs[ind].Value["THICK"] = val
 		}
 	case "add":
 		if narg < 2 {
-			if usage {
-				return st.Usage(":add [elem, sect]")
-			}
 			return st.NotEnoughArgs(":add")
 		}
 		switch strings.ToLower(args[1]) {
 		case "elem":
-			if usage {
-				return st.Usage(":add elem {-sect=code} {-etype=type}")
-			}
 			var etype int
 			if et, ok := argdict["ETYPE"]; ok {
 				switch {
@@ -2319,25 +2648,16 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			} else {
 				return errors.New(":add elem: no sectcode selected")
 			}
-			enod := make([]*st.Node, 0)
-			enods := 0
-		ex_addelem:
-			for {
-				select {
-				case <-time.After(time.Second):
-					break ex_addelem
-				case <-stw.exmodeend:
-					break ex_addelem
-				case ent := <-stw.exmodech:
-					if n, ok := ent.(*st.Node); ok {
-						enod = append(enod, n)
-						enods++
-					}
-				}
+			enod, err := input.NodeSet(":add elem")
+			if err != nil {
+				return err
 			}
-			enod = enod[:enods]
+			enods := len(enod)
 			switch etype {
 			case st.COLUMN, st.GIRDER, st.BRACE:
+				if enods < 2 {
+					return errors.New(fmt.Sprintf(":add elem: %d nodes piped", enods))
+				}
 				stw.Frame.AddLineElem(-1, enod[:2], sect, etype)
 			case st.WALL, st.SLAB:
 				if enods > 4 {
@@ -2346,9 +2666,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 				stw.Frame.AddPlateElem(-1, enod, sect, etype)
 			}
 		case "sec", "sect":
-			if usage {
-				return st.Usage(":add sect sectcode")
-			}
 			if narg < 3 {
 				return st.NotEnoughArgs(":add sect")
 			}
@@ -2360,37 +2677,39 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			if _, ok := stw.Frame.Sects[snum]; ok && !bang {
 				return errors.New(fmt.Sprintf(":add sect: SECT %d already exists", snum))
 			}
-			sec := stw.Frame.AddSect(snum)
-			select {
-			case <-time.After(time.Second):
-				break
-			case al := <-stw.exmodech:
-				if a, ok := al.(st.Shape); ok {
-					sec.Figs = make([]*st.Fig, 1)
-					f := st.NewFig()
-					if p, ok := stw.Frame.Props[101]; ok {
-						f.Prop = p
-					} else {
-						f.Prop = stw.Frame.DefaultProp()
-					}
-					sec.Figs[0] = f
-					sec.Figs[0].SetShapeProperty(a)
-					sec.Name = a.Description()
+			if name, ok := argdict["SHAPE"]; ok {
+				cs, err := ParseShapeName(name)
+				if err != nil {
+					return err
+				}
+				sh, err := cs.Shape()
+				if err != nil {
+					return err
+				}
+				sec := stw.Frame.AddSect(snum)
+				sec.Figs = nil
+				SetSectShape(stw.Frame, sec, sh)
+				return nil
+			}
+			var a st.Shape
+			if input != nil {
+				a, err = input.PipedShape(":add sect")
+				if err != nil {
+					return err
 				}
 			}
+			sec := stw.Frame.AddSect(snum)
+			if a != nil {
+				sec.Figs = nil
+				SetSectShape(stw.Frame, sec, a)
+			}
 		}
 	case "copy":
 		if narg < 2 {
-			if usage {
-				return st.Usage(":copy [sect]")
-			}
 			return st.NotEnoughArgs(":copy")
 		}
 		switch strings.ToLower(args[1]) {
 		case "sec", "sect":
-			if usage {
-				return st.Usage(":copy sect sectcode")
-			}
 			if narg < 3 {
 				return st.NotEnoughArgs(":copy sect")
 			}
@@ -2402,22 +2721,16 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			if _, ok := stw.Frame.Sects[snum]; ok && !bang {
 				return errors.New(fmt.Sprintf(":copy sect: SECT %d already exists", snum))
 			}
-			select {
-			case <-time.After(time.Second):
-				break
-			case s := <-stw.exmodech:
-				if sec, ok := s.(*st.Sect); ok {
-					as := sec.Snapshot(stw.Frame)
-					as.Num = snum
-					stw.Frame.Sects[snum] = as
-					stw.Frame.Show.Sect[snum] = true
-				}
+			sec, err := input.PipedSect(":copy sect")
+			if err != nil {
+				return err
 			}
+			as := sec.Snapshot(stw.Frame)
+			as.Num = snum
+			stw.Frame.Sects[snum] = as
+			stw.Frame.Show.Sect[snum] = true
 		}
 	case "currentvalue":
-		if usage {
-			return st.Usage(":currentvalue {-abs}")
-		}
 		if stw.SelectElem != nil && len(stw.SelectElem) >= 1 {
 			var valfunc func(*st.Elem) float64
 			var m bytes.Buffer
@@ -2439,9 +2752,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			return st.Message(m.String())
 		}
 	case "max":
-		if usage {
-			return st.Usage(":max {-abs}")
-		}
 		if stw.SelectElem != nil && len(stw.SelectElem) >= 1 {
 			maxval := -1e16
 			var valfunc func(*st.Elem) float64
@@ -2502,9 +2812,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			return errors.New(":max no selected elem/node")
 		}
 	case "min":
-		if usage {
-			return st.Usage(":min {-abs}")
-		}
 		if stw.SelectElem != nil && len(stw.SelectElem) >= 1 {
 			minval := 1e16
 			var valfunc func(*st.Elem) float64
@@ -2565,9 +2872,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			return errors.New(":min no selected elem/node")
 		}
 	case "average":
-		if usage {
-			return st.Usage(":average {-abs}")
-		}
 		if stw.SelectElem != nil && len(stw.SelectElem) >= 1 {
 			var valfunc func(*st.Elem) float64
 			if _, ok := argdict["ABS"]; ok {
@@ -2618,9 +2922,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			return errors.New(":average no selected elem/node")
 		}
 	case "sum":
-		if usage {
-			return st.Usage(":sum {-abs}")
-		}
 		if stw.SelectElem != nil && len(stw.SelectElem) >= 1 {
 			var valfunc func(*st.Elem) float64
 			if _, ok := argdict["ABS"]; ok {
@@ -2671,27 +2972,18 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			return errors.New(":sum no selected elem/node")
 		}
 	case "erase":
-		if usage {
-			return st.Usage(":erase")
-		}
 		stw.Deselect()
-	ex_erase:
-		for {
-			select {
-			case <-time.After(time.Second):
-				break ex_erase
-			case <-stw.exmodeend:
-				break ex_erase
-			case ent := <-stw.exmodech:
-				switch ent := ent.(type) {
-				case *st.Node:
-					stw.Frame.DeleteNode(ent.Num)
-				case *st.Elem:
-					stw.Frame.DeleteElem(ent.Num)
-				}
-			}
-		}
-		ns := stw.Frame.NodeNoReference()
+		ns, els, err := input.Entities(":erase")
+		if err != nil {
+			return err
+		}
+		for _, n := range ns {
+			stw.Frame.DeleteNode(n.Num)
+		}
+		for _, el := range els {
+			stw.Frame.DeleteElem(el.Num)
+		}
+		ns = stw.Frame.NodeNoReference()
 		if len(ns) != 0 {
 			for _, n := range ns {
 				stw.Frame.DeleteNode(n.Num)
@@ -2699,65 +2991,37 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		stw.Snapshot()
 	case "count":
-		if usage {
-			return st.Usage(":count")
-		}
-		var nnode, nelem int
-	ex_count:
-		for {
-			select {
-			case <-time.After(time.Second):
-				break ex_count
-			case <-stw.exmodeend:
-				break ex_count
-			case ent := <-stw.exmodech:
-				switch ent.(type) {
-				case *st.Node:
-					nnode++
-				case *st.Elem:
-					nelem++
-				}
-			}
-		}
-		return st.Message(fmt.Sprintf("NODES: %d, ELEMS: %d", nnode, nelem))
+		ns, els, err := input.Entities(":count")
+		if err != nil {
+			return err
+		}
+		if pipe {
+			sender = input
+		}
+		return st.Message(fmt.Sprintf("NODES: %d, ELEMS: %d", len(ns), len(els)))
 	case "show":
-		if usage {
-			return st.Usage(":show")
+		ns, els, err := input.Entities(":show")
+		if err != nil {
+			return err
 		}
-	ex_show:
-		for {
-			select {
-			case <-time.After(time.Second):
-				break ex_show
-			case <-stw.exmodeend:
-				break ex_show
-			case ent := <-stw.exmodech:
-				if h, ok := ent.(st.Hider); ok {
-					h.Show()
-				}
-			}
+		for _, n := range ns {
+			n.Show()
+		}
+		for _, el := range els {
+			el.Show()
 		}
 	case "hide":
-		if usage {
-			return st.Usage(":hide")
+		ns, els, err := input.Entities(":hide")
+		if err != nil {
+			return err
 		}
-	ex_hide:
-		for {
-			select {
-			case <-time.After(time.Second):
-				break ex_hide
-			case <-stw.exmodeend:
-				break ex_hide
-			case ent := <-stw.exmodech:
-				if h, ok := ent.(st.Hider); ok {
-					h.Hide()
-				}
-			}
+		for _, n := range ns {
+			n.Hide()
 		}
-	case "range":
-		if usage {
-			return st.Usage(":range [x,y,z] min max")
+		for _, el := range els {
+			el.Hide()
 		}
+	case "range":
 		if narg == 1 {
 			for i:=0; i<3; i++ {
 				axisrange(stw, i, -100.0, 1000.0, false)
@@ -2796,9 +3060,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		axisrange(stw, axis, min, max, false)
 	case "height":
-		if usage {
-			return st.Usage(":height f1 f2")
-		}
 		if narg == 1 {
 			axisrange(stw, 2, -100.0, 1000.0, false)
 			return nil
@@ -2831,9 +3092,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		axisrange(stw, 2, stw.Frame.Ai.Boundary[min], stw.Frame.Ai.Boundary[max], false)
 	case "story", "storey":
-		if usage {
-			return st.Usage(":storey n")
-		}
 		if narg < 2 {
 			return st.NotEnoughArgs(":storey")
 		}
@@ -2847,9 +3105,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		}
 		return stw.exmode(fmt.Sprintf("height %d %d", n-1, n+1))
 	case "floor":
-		if usage {
-			return st.Usage(":floor n")
-		}
 		if narg < 2 {
 			return st.NotEnoughArgs(":floor")
 		}
@@ -2875,9 +3130,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 	case "height-":
 		stw.PrevFloor()
 	case "view":
-		if usage {
-			return st.Usage(":view [top,front,back,right,left]")
-		}
 		switch strings.ToUpper(args[1]) {
 		case "TOP":
 			stw.SetAngle(90.0, -90.0)
@@ -2891,9 +3143,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			stw.SetAngle(0.0, 180.0)
 		}
 	case "printrange":
-		if usage {
-			return st.Usage(":printrange [on,true,yes/off,false,no] [a3tate,a3yoko,a4tate,a4yoko]")
-		}
 		if narg < 2 {
 			showprintrange = !showprintrange
 			break
@@ -2917,9 +3166,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			showprintrange = true
 		}
 	case "paper":
-		if usage {
-			return st.Usage(":paper [a3tate,a3yoko,a4tate,a4yoko]")
-		}
 		if narg < 2 {
 			return st.NotEnoughArgs(":paper")
 		}
@@ -2947,9 +3193,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			return errors.New(":paper unknown papersize")
 		}
 	case "color":
-		if usage {
-			return st.Usage(":color [n,sect,rate,white,mono,strong]")
-		}
 		if narg < 2 {
 			stw.SetColorMode(st.ECOLOR_SECT)
 			break
@@ -2965,7 +3208,99 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			stw.SetColorMode(st.ECOLOR_WHITE)
 		case "STRONG":
 			stw.SetColorMode(st.ECOLOR_STRONG)
+		case "MAP":
+			if stw.colormap == nil {
+				return errors.New(":color no colormap (:colormap)")
+			}
+			stw.SetColorMode(ECOLOR_MAP)
+		}
+	case "colormap":
+		if narg < 2 {
+			names := make([]string, 0, len(COLORMAPPRESETS))
+			for name := range COLORMAPPRESETS {
+				names = append(names, strings.ToLower(name))
+			}
+			sort.Strings(names)
+			current := "no colormap"
+			if stw.colormap != nil {
+				current = stw.colormap.String()
+			}
+			return st.Message(fmt.Sprintf("%s\nPRESETS: %s", current, strings.Join(names, " ")))
+		}
+		scalar := "RATE"
+		if sc, ok := argdict["SCALAR"]; ok && sc != "" {
+			scalar = strings.ToUpper(sc)
+			valid := false
+			for _, s := range COLORSCALARS {
+				if s == scalar {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				return errors.New(fmt.Sprintf(":colormap unknown scalar %s (%s)", sc, strings.Join(COLORSCALARS, ",")))
+			}
+		}
+		var cm *ColorMap
+		name := strings.ToUpper(args[1])
+		if name == "OFF" {
+			stw.colormap = nil
+			if stw.Frame.Show.ColorMode == ECOLOR_MAP {
+				stw.SetColorMode(st.ECOLOR_SECT)
+			}
+			stw.Redraw()
+			return nil
+		}
+		if preset, ok := COLORMAPPRESETS[name]; ok {
+			min, max := 0.0, 1.0
+			r, ok := argdict["RANGE"]
+			switch {
+			case ok && r != "" && !strings.EqualFold(r, "auto"):
+				lis := strings.Split(r, ",")
+				if len(lis) != 2 {
+					return errors.New(":colormap -range=min,max")
+				}
+				tmp, err := strconv.ParseFloat(lis[0], 64)
+				if err != nil {
+					return err
+				}
+				min = tmp
+				tmp, err = strconv.ParseFloat(lis[1], 64)
+				if err != nil {
+					return err
+				}
+				max = tmp
+			case ok || scalar != "RATE":
+				_, _, err := ScalarRange(stw.Frame, scalar)
+				if err != nil {
+					return err
+				}
+				cm = preset.AutoScale()
+				cm.Scalar = scalar
+				cm.Resolve(stw.Frame)
+			}
+			if cm == nil {
+				cm = preset.Scale(min, max)
+			}
+		} else {
+			if narg < 3 {
+				return st.NotEnoughArgs(":colormap")
+			}
+			_, continuous := argdict["CONTINUOUS"]
+			tmp, err := ParseColorMap(args[1], args[2], continuous)
+			if err != nil {
+				return err
+			}
+			cm = tmp
+		}
+		if cm.Name == "" {
+			cm.Name = name
 		}
+		cm.Scalar = scalar
+		stw.colormap = cm
+		stw.SetColorMode(ECOLOR_MAP)
+		stw.Redraw()
+		return st.Message(cm.String())
 	case "mono":
 		stw.SetColorMode(st.ECOLOR_WHITE)
 	// case "postscript":
@@ -3033,9 +3368,6 @@ func (stw *Window) excommand(command string, pipe bool) error {
 	// 		}
 	// 	}
 	case "arclm001":
-		if usage {
-			return st.Usage(":arclm001 {-period=name} {-all} {-solver=name} {-eps=value} {-noinit} filename")
-		}
 		var otp string
 		if fn == "" {
 			otp = st.Ce(stw.Frame.Path, ".otp")
@@ -3096,34 +3428,34 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			init = false
 			m.WriteString("NO INITIALISATION")
 		}
-		af := stw.Frame.Arclms[per]
-		go func() {
-			err := af.Arclm001(otps, init, sol, eps, extra...)
-			af.Endch <- err
-		}()
-		stw.CurrentLap("Calculating...", 0, lap)
-		go func() {
-		read001:
-			for {
-				select {
-				case nlap := <-af.Lapch:
-					stw.Frame.ReadArclmData(af, pers[nlap])
-					af.Lapch <- 1
-					stw.CurrentLap("Calculating...", nlap, lap)
-					stw.Redraw()
-				case <-af.Endch:
-					stw.CurrentLap("Completed", lap, lap)
+		frame := stw.Frame
+		af, ok := stw.Frame.Arclms[per]
+		if !ok {
+			return errors.New(fmt.Sprintf("period %s doesn't exist", per))
+		}
+		err := stw.jobs.Submit(&Job{
+			Name:  fmt.Sprintf("arclm001 %s", strings.Join(pers, ",")),
+			Laps:  lap,
+			Frame: frame,
+			Arclm: af,
+			Run: func() error {
+				return af.Arclm001(otps, init, sol, eps, extra...)
+			},
+			Lap: func(nlap int) {
+				frame.ReadArclmData(af, pers[nlap])
+			},
+			Done: func() {
+				if stw.Frame == frame {
+					stw.UpdateCombinations()
 					stw.SetPeriod(per)
-					stw.Redraw()
-					break read001
 				}
-			}
-		}()
+			},
+		})
+		if err != nil {
+			return err
+		}
 		return st.Message(m.String())
 	case "arclm201":
-		if usage {
-			return st.Usage(":arclm201 {-period=name} {-lap=nlap} {-safety=val} {-start=val} {-noinit} filename")
-		}
 		var otp string
 		if fn == "" {
 			otp = st.Ce(stw.Frame.Path, ".otp")
@@ -3169,33 +3501,28 @@ func (stw *Window) excommand(command string, pipe bool) error {
 			init = false
 			m.WriteString("NO INITIALISATION")
 		}
-		af := stw.Frame.Arclms[per]
-		go func() {
-			err := af.Arclm201(otp, init, lap, safety, start, 1.0)
-			af.Endch <- err
-		}()
-		stw.CurrentLap("Calculating...", 0, lap)
-		go func() {
-		read201:
-			for {
-				select {
-				case nlap := <-af.Lapch:
-					stw.Frame.ReadArclmData(af, per)
-					af.Lapch <- 1
-					stw.CurrentLap("Calculating...", nlap, lap)
-					stw.Redraw()
-				case <-af.Endch:
-					stw.CurrentLap("Completed", lap, lap)
-					stw.Redraw()
-					break read201
-				}
-			}
-		}()
+		af, ok := stw.Frame.Arclms[per]
+		if !ok {
+			return errors.New(fmt.Sprintf("period %s doesn't exist", per))
+		}
+		frame := stw.Frame
+		err := stw.jobs.Submit(&Job{
+			Name:  fmt.Sprintf("arclm201 %s", per),
+			Laps:  lap,
+			Frame: frame,
+			Arclm: af,
+			Run: func() error {
+				return af.Arclm201(otp, init, lap, safety, start, 1.0)
+			},
+			Lap: func(nlap int) {
+				frame.ReadArclmData(af, per)
+			},
+		})
+		if err != nil {
+			return err
+		}
 		return st.Message(m.String())
 	case "arclm301":
-		if usage {
-			return st.Usage(":arclm301 {-period=name} {-sects=val} {-eps=val} {-noinit} filename")
-		}
 		var otp string
 		var sects []int
 		var m bytes.Buffer
@@ -3227,33 +3554,31 @@ func (stw *Window) excommand(command string, pipe bool) error {
 		m.WriteString(fmt.Sprintf("PERIOD: %s", per))
 		m.WriteString(fmt.Sprintf("OUTPUT: %s", otp))
 		m.WriteString(fmt.Sprintf("EPS: %.3E", eps))
-		af := stw.Frame.Arclms[per]
+		af, ok := stw.Frame.Arclms[per]
+		if !ok {
+			return errors.New(fmt.Sprintf("period %s doesn't exist", per))
+		}
 		init := true
 		if _, ok := argdict["NOINIT"]; ok {
 			init = false
 			m.WriteString("NO INITIALISATION")
 		}
-		go func() {
-			err := af.Arclm301(otp, init, sects, eps)
-			af.Endch <- err
-		}()
-		stw.CurrentLap("Calculating...", 0, 0)
-		go func() {
-		read301:
-			for {
-				select {
-				case nlap := <-af.Lapch:
-					stw.Frame.ReadArclmData(af, per)
-					af.Lapch <- 1
-					stw.CurrentLap("Calculating...", nlap, 0)
-					stw.Redraw()
-				case <-af.Endch:
-					stw.CurrentLap("Completed", 0, 0)
-					stw.Redraw()
-					break read301
-				}
-			}
-		}()
+		frame := stw.Frame
+		err := stw.jobs.Submit(&Job{
+			Name:  fmt.Sprintf("arclm301 %s", per),
+			Laps:  0,
+			Frame: frame,
+			Arclm: af,
+			Run: func() error {
+				return af.Arclm301(otp, init, sects, eps)
+			},
+			Lap: func(nlap int) {
+				frame.ReadArclmData(af, per)
+			},
+		})
+		if err != nil {
+			return err
+		}
 		return st.Message(m.String())
 	}
 	return nil
@@ -0,0 +1,263 @@
+package stgxui
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/yofu/st/stlib"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	EXPORTNODECOLUMNS = []string{"NODE", "X", "Y", "Z", "PERIOD", "DX", "DY", "DZ", "TX", "TY", "TZ", "RX", "RY", "RZ", "MX", "MY", "MZ"}
+	EXPORTELEMCOLUMNS = []string{"ELEM", "SECT", "ETYPE", "NODE", "X", "Y", "Z", "PERIOD", "N", "QX", "QY", "MT", "MX", "MY"}
+)
+
+// ExportTable is a table of results in long format: a row for each entity (and elem end) and period.
+// Count is the number of entities in the table.
+type ExportTable struct {
+	Columns []string
+	Rows    [][]interface{}
+	Count   int
+}
+
+func NodeExportTable(nodes []*st.Node, periods []string) *ExportTable {
+	et := &ExportTable{
+		Columns: EXPORTNODECOLUMNS,
+		Rows:    make([][]interface{}, 0),
+	}
+	for _, n := range nodes {
+		for _, per := range periods {
+			row := []interface{}{n.Num, n.Coord[0], n.Coord[1], n.Coord[2], per}
+			for i := 0; i < 6; i++ {
+				row = append(row, n.ReturnDisp(per, i))
+			}
+			for i := 0; i < 6; i++ {
+				row = append(row, n.ReturnReaction(per, i))
+			}
+			et.Rows = append(et.Rows, row)
+		}
+		et.Count++
+	}
+	return et
+}
+
+func ElemExportTable(els []*st.Elem, periods []string) *ExportTable {
+	et := &ExportTable{
+		Columns: EXPORTELEMCOLUMNS,
+		Rows:    make([][]interface{}, 0),
+	}
+	for _, el := range els {
+		if !el.IsLineElem() {
+			continue
+		}
+		for end := 0; end < 2; end++ {
+			for _, per := range periods {
+				en := el.Enod[end]
+				row := []interface{}{el.Num, el.Sect.Num, st.ETYPES[el.Etype], en.Num, en.Coord[0], en.Coord[1], en.Coord[2], per}
+				for i := 0; i < 6; i++ {
+					row = append(row, el.ReturnStress(per, end, i))
+				}
+				et.Rows = append(et.Rows, row)
+			}
+		}
+		et.Count++
+	}
+	return et
+}
+
+// Select keeps the columns in fields. Unknown fields are an error.
+func (et *ExportTable) Select(fields []string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	ind := make([]int, len(fields))
+	for i, f := range fields {
+		ind[i] = -1
+		for j, c := range et.Columns {
+			if strings.EqualFold(c, f) {
+				ind[i] = j
+				break
+			}
+		}
+		if ind[i] < 0 {
+			return errors.New(fmt.Sprintf("Select: unknown field %s (%s)", f, strings.Join(et.Columns, ",")))
+		}
+	}
+	cols := make([]string, len(ind))
+	for i, j := range ind {
+		cols[i] = et.Columns[j]
+	}
+	for r, row := range et.Rows {
+		newrow := make([]interface{}, len(ind))
+		for i, j := range ind {
+			newrow[i] = row[j]
+		}
+		et.Rows[r] = newrow
+	}
+	et.Columns = cols
+	return nil
+}
+
+func (et *ExportTable) WriteCSV(fn string) error {
+	w, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	cw := csv.NewWriter(w)
+	cw.Write(et.Columns)
+	for _, row := range et.Rows {
+		rec := make([]string, len(row))
+		for i, v := range row {
+			switch v := v.(type) {
+			case float64:
+				rec[i] = strconv.FormatFloat(v, 'g', -1, 64)
+			default:
+				rec[i] = fmt.Sprint(v)
+			}
+		}
+		cw.Write(rec)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (et *ExportTable) records() []map[string]interface{} {
+	rtn := make([]map[string]interface{}, len(et.Rows))
+	for r, row := range et.Rows {
+		rec := make(map[string]interface{})
+		for i, v := range row {
+			rec[strings.ToLower(et.Columns[i])] = v
+		}
+		rtn[r] = rec
+	}
+	return rtn
+}
+
+// Export writes the results of nodes and elems for periods and returns the numbers of nodes and elems written.
+// JSON has both "nodes" and "elems". CSV has one kind, line elems if any.
+// Unknown fields are ignored in JSON as they may belong to the other kind.
+func Export(fn, format string, nodes []*st.Node, els []*st.Elem, periods []string, fields []string) (int, int, error) {
+	nt := NodeExportTable(nodes, periods)
+	et := ElemExportTable(els, periods)
+	if nt.Count == 0 && et.Count == 0 {
+		return 0, 0, errors.New("Export: no node or line elem to export")
+	}
+	switch strings.ToLower(format) {
+	default:
+		return 0, 0, errors.New(fmt.Sprintf("Export: unknown format %s", format))
+	case "csv":
+		if et.Count > 0 {
+			err := et.Select(fields)
+			if err != nil {
+				return 0, 0, err
+			}
+			return 0, et.Count, et.WriteCSV(fn)
+		}
+		err := nt.Select(fields)
+		if err != nil {
+			return 0, 0, err
+		}
+		return nt.Count, 0, nt.WriteCSV(fn)
+	case "json":
+		if len(fields) > 0 {
+			var nf, ef []string
+			for _, f := range fields {
+				for _, c := range nt.Columns {
+					if strings.EqualFold(c, f) {
+						nf = append(nf, f)
+						break
+					}
+				}
+				for _, c := range et.Columns {
+					if strings.EqualFold(c, f) {
+						ef = append(ef, f)
+						break
+					}
+				}
+			}
+			if len(nf) > 0 {
+				nt.Select(nf)
+			}
+			if len(ef) > 0 {
+				et.Select(ef)
+			}
+		}
+		data, err := json.MarshalIndent(map[string]interface{}{
+			"periods": periods,
+			"nodes":   nt.records(),
+			"elems":   et.records(),
+		}, "", "  ")
+		if err != nil {
+			return 0, 0, err
+		}
+		w, err := os.Create(fn)
+		if err != nil {
+			return 0, 0, err
+		}
+		defer w.Close()
+		_, err = w.Write(data)
+		if err != nil {
+			return 0, 0, err
+		}
+		return nt.Count, et.Count, nil
+	}
+}
+
+// ExportTargets returns the selected nodes and elems, or all visible ones when nothing is selected.
+func (stw *Window) ExportTargets() ([]*st.Node, []*st.Elem) {
+	nodes := make([]*st.Node, 0)
+	els := make([]*st.Elem, 0)
+	for _, n := range stw.SelectNode {
+		if n != nil {
+			nodes = append(nodes, n)
+		}
+	}
+	for _, el := range stw.SelectElem {
+		if el != nil {
+			els = append(els, el)
+		}
+	}
+	if len(nodes) == 0 && len(els) == 0 {
+		for _, n := range sortednodes(stw.Frame) {
+			if !n.IsHidden(stw.Frame.Show) {
+				nodes = append(nodes, n)
+			}
+		}
+		for _, el := range sortedelems(stw.Frame) {
+			if !el.IsHidden(stw.Frame.Show) {
+				els = append(els, el)
+			}
+		}
+	}
+	return nodes, els
+}
+
+// ElemNodes returns the enods of els without duplication, sorted by number.
+func ElemNodes(els []*st.Elem) []*st.Node {
+	rtn := make([]*st.Node, 0)
+	nmap := make(map[*st.Node]bool)
+	for _, el := range els {
+		for _, en := range el.Enod[:el.Enods] {
+			if !nmap[en] {
+				nmap[en] = true
+				rtn = append(rtn, en)
+			}
+		}
+	}
+	sort.Sort(st.NodeByNum{rtn})
+	return rtn
+}
+
+func exportfilename(fn, format string) string {
+	if filepath.Ext(fn) == "" {
+		return fmt.Sprintf("%s.%s", fn, strings.ToLower(format))
+	}
+	return fn
+}
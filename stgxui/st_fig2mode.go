@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"github.com/yofu/abbrev"
 	"github.com/yofu/st/stlib"
-	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -69,6 +69,33 @@ func fig2keywordcomplete(command string) (string, bool) {
 	return rtn, usage
 }
 
+func (stw *Window) fig2keywordcandidates(command string) []string {
+	prefix := "'"
+	cname := strings.TrimPrefix(command, "'")
+	if strings.HasPrefix(cname, "!") {
+		prefix = "'!"
+		cname = cname[1:]
+	}
+	cname = strings.ToLower(cname)
+	rtn := make([]string, 0)
+	for _, ab := range fig2abbrev {
+		pat := abbrev.MustCompile(ab)
+		name := pat.Longest()
+		if strings.HasPrefix(name, cname) || pat.MatchString(cname) {
+			rtn = append(rtn, fmt.Sprintf("%s%s", prefix, name))
+		}
+	}
+	if stw.Frame != nil {
+		for name := range stw.Frame.Kijuns {
+			if strings.HasPrefix(name, cname) {
+				rtn = append(rtn, fmt.Sprintf("%s%s", prefix, name))
+			}
+		}
+	}
+	sort.Strings(rtn)
+	return rtn
+}
+
 func (stw *Window) fig2keyword(lis []string, un bool) error {
 	if len(lis) < 1 {
 		return st.NotEnoughArgs("Fig2Keyword")
@@ -76,11 +103,19 @@ func (stw *Window) fig2keyword(lis []string, un bool) error {
 	showhtml := func(fn string) {
 		f := filepath.Join(tooldir, "fig2/keywords", fn)
 		if st.FileExists(f) {
-			cmd := exec.Command("cmd", "/C", "start", f)
-			cmd.Start()
+			Browse(f)
 		}
 	}
 	key, usage := fig2keywordcomplete(strings.ToLower(lis[0]))
+	if usage {
+		if h, ok := Fig2Help[key]; ok {
+			stw.History(h.UsageString())
+			showhtml(fmt.Sprintf("%s.html", strings.ToUpper(key)))
+		}
+		if key != "unit" {
+			return nil
+		}
+	}
 	switch key {
 	default:
 		if k, ok := stw.Frame.Kijuns[key]; ok {
@@ -211,10 +246,8 @@ func (stw *Window) fig2keyword(lis []string, un bool) error {
 		stw.Frame.View.Perspective = false
 	case "unit":
 		if usage {
-			stw.History("'unit force,length")
 			stw.History(fmt.Sprintf("CURRENT FORCE UNIT: %s %.3f", stw.Frame.Show.UnitName[0], stw.Frame.Show.Unit[0]))
 			stw.History(fmt.Sprintf("CURRENT LENGTH UNIT: %s %.3f", stw.Frame.Show.UnitName[1], stw.Frame.Show.Unit[1]))
-			showhtml("UNIT.html")
 			return nil
 		}
 		if un {
@@ -391,12 +424,6 @@ func (stw *Window) fig2keyword(lis []string, un bool) error {
 			// stw.Labels["KIJUN"].SetAttribute("FGCOLOR", labelFGColor)
 		}
 	case "measure":
-		if usage {
-			stw.History("'measure kijun x1 x2 offset dotsize rotate overwrite")
-			stw.History("'measure nnum1 nnum2 direction offset dotsize rotate overwrite")
-			showhtml("MEASURE.html")
-			return nil
-		}
 		if un {
 			stw.Frame.Show.Measure = false
 		} else {
@@ -540,10 +567,6 @@ func (stw *Window) fig2keyword(lis []string, un bool) error {
 			stw.SetColorMode(st.ECOLOR_RATE)
 		}
 	case "srcanrate":
-		if usage {
-			stw.History("'srcanrate [long/short]")
-			return nil
-		}
 		onoff := []bool{false, false, false, false} // long, short, q, m
 		if len(lis) >= 2 {
 			for _, str := range lis[1:] {
@@ -582,10 +605,6 @@ func (stw *Window) fig2keyword(lis []string, un bool) error {
 			stw.SrcanRateOn(names...)
 		}
 	case "stress":
-		if usage {
-			stw.History("'stress [etype/sectcode] [period] [stressname]")
-			return nil
-		}
 		l := len(lis)
 		if l < 2 {
 			if un {
@@ -713,10 +732,6 @@ func (stw *Window) fig2keyword(lis []string, un bool) error {
 			stw.ElemCaptionOn("EC_PREST")
 		}
 	case "stiff":
-		if usage {
-			stw.History("'stiff [x,y]")
-			return nil
-		}
 		if len(lis) < 2 {
 			if un {
 				stw.ElemCaptionOff("EC_STIFF_X")
@@ -743,10 +758,6 @@ func (stw *Window) fig2keyword(lis []string, un bool) error {
 			}
 		}
 	case "drift":
-		if usage {
-			stw.History("'drift [x,y]")
-			return nil
-		}
 		if len(lis) < 2 {
 			if un {
 				stw.ElemCaptionOff("EC_DRIFT_X")
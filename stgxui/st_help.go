@@ -0,0 +1,353 @@
+package stgxui
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/yofu/abbrev"
+	"html"
+	"os"
+	"sort"
+	"strings"
+)
+
+var (
+	ExHelp   = make(map[string]*CommandHelp, 0)
+	Fig2Help = make(map[string]*CommandHelp, 0)
+)
+
+type CommandHelp struct {
+	Name        string
+	Abbrev      string
+	Usage       []string
+	Flags       []string
+	Description string
+}
+
+func (h *CommandHelp) UsageString() string {
+	return strings.Join(h.Usage, "\n")
+}
+
+func (h *CommandHelp) String() string {
+	var otp bytes.Buffer
+	if h.Abbrev != "" {
+		otp.WriteString(fmt.Sprintf("%s (%s)\n", h.Name, h.Abbrev))
+	} else {
+		otp.WriteString(fmt.Sprintf("%s\n", h.Name))
+	}
+	otp.WriteString(fmt.Sprintf("  %s\n", h.Description))
+	for _, u := range h.Usage {
+		otp.WriteString(fmt.Sprintf("  %s\n", u))
+	}
+	for _, f := range h.Flags {
+		otp.WriteString(fmt.Sprintf("    %s\n", f))
+	}
+	return strings.TrimSuffix(otp.String(), "\n")
+}
+
+var exhelps = []*CommandHelp{
+	{Name: "edit", Usage: []string{":edit filename {-u=.strc}"}, Flags: []string{"-u=file: resource file read after opening (NONE: don't read)"}, Description: "open an input file. reload the current file without filename. ! discards changes"},
+	{Name: "quit", Usage: []string{":quit"}, Description: "close the window. ! discards changes"},
+	{Name: "eps", Usage: []string{":eps val"}, Description: "set the tolerance used for coordinate comparison"},
+	{Name: "fitscale", Usage: []string{":fitscale val"}, Description: "set the scale used when fitting the model to the canvas"},
+	{Name: "mkdir", Usage: []string{":mkdir dirname"}, Description: "make a directory"},
+	{Name: "#", Usage: []string{":#"}, Description: "show recently opened files"},
+	{Name: "vim", Usage: []string{":vim filename"}, Description: "edit a file with gvim"},
+	{Name: "hkyou", Usage: []string{":hkyou h b tw tf", ":hkyou hxb{xtwxtf}"}, Description: "show properties of H section (strong axis). pipeable"},
+	{Name: "hweak", Usage: []string{":hweak h b tw tf", ":hweak hxb{xtwxtf}"}, Description: "show properties of H section (weak axis). pipeable"},
+	{Name: "rpipe", Usage: []string{":rpipe h b tw tf", ":rpipe dxt"}, Description: "show properties of rectangular pipe. pipeable"},
+	{Name: "cpipe", Usage: []string{":cpipe d t", ":cpipe dxt"}, Description: "show properties of circular pipe. pipeable"},
+	{Name: "tkyou", Usage: []string{":tkyou h b tw tf"}, Description: "show properties of T section. pipeable"},
+	{Name: "ckyou", Usage: []string{":ckyou h b tw tf", ":ckyou hxb{xtwxtf}"}, Description: "show properties of channel section. pipeable"},
+	{Name: "shape", Usage: []string{":shape name", ":shape {word} -list {-kind=H|BOX|P|C|L}"}, Flags: []string{"-list: list the shapes of the catalogue matching word", "-kind=kind: search only the kind"}, Description: "show properties of a shape of the JIS steel catalogue named such as H-400x200x8x13, BOX-200x9, P-216.3x5.8 or C-200x80x7.5x11, or found by leading dimensions (400x200) or fuzzy search. a single dimension such as 400x200 after :hkyou, :hweak, :ckyou, :rpipe or :cpipe is also looked up. angles (L) are made without fillets. pipeable"},
+	{Name: "plate", Usage: []string{":plate h b"}, Description: "show properties of flat plate. pipeable"},
+	{Name: "fixrotate", Usage: []string{":fixrotate"}, Description: "toggle rotation lock of the view"},
+	{Name: "fixmove", Usage: []string{":fixmove"}, Description: "toggle move lock of the view"},
+	{Name: "noundo", Usage: []string{":noundo"}, Description: "turn undo/redo off"},
+	{Name: "undo", Usage: []string{":undo"}, Description: "turn undo/redo on"},
+	{Name: "alt", Usage: []string{":alt"}, Description: "toggle whether Alt key selects nodes or elems"},
+	{Name: "procs", Usage: []string{":procs numcpu"}, Description: "show or set GOMAXPROCS"},
+	{Name: "let", Usage: []string{":let", ":let name", ":let name = value", ":let name = :command"}, Description: "set a variable used as $name or ${name} in commands and scripts. a value beginning with : is run as an ex command and its count is set: its message if it is a number, or else the number of nodes, elems and sects it pipes (name_nodes and name_elems are set too), or else its message. without value, show the variables"},
+	{Name: "unlet", Usage: []string{":unlet name..."}, Description: "delete the variables"},
+	{Name: "source", Usage: []string{":source filename"}, Description: "run a script like .strc. in scripts, :for name in [floors,sects,periods,a..b,word...] ... :endfor repeats the lines and :if [:command,exists path,not cond,a op b,value] ... :else ... :endif runs them if the command succeeds with a nonzero count as :let (e.g. :elem column and rate > 1, :check) or the condition holds (op: ==, !=, <, <=, >, >=)"},
+	{Name: "script", Usage: []string{":script {-timeout=60} filename {args...}"}, Flags: []string{"-timeout=sec: stop the script after sec seconds (no limit with 0)"}, Description: "run a Lua script with args as arg[1]... the table st gives nodes, elems, sects, selection, queries, results and ex/fig2 commands (st.command); indices are 1-based as Lua tables; see st_lua.go"},
+	{Name: "serve", Usage: []string{":serve {127.0.0.1:port}", ":serve off"}, Description: "start a local HTTP server for external tools (loopback addresses only; port 0 picks a free one). POST /rpc takes JSON-RPC 2.0 with methods command {command}, selection, select {nodes,elems}, query {query,node,select}, nodes {nums,period}, elems {nums,period}, frame and redraw. POST /command {command}, GET /selection, /nodes, /elems, /frame (?num=1,2&period=L) and POST /redraw do the same. every request needs the token shown by :serve in the X-St-Token header; requests with an Origin header or a non-loopback Host are refused and POST bodies must be application/json. requests run on the UI thread. without address shows the current one and the token"},
+	{Name: "watch", Usage: []string{":watch on {-interval=sec}", ":watch off"}, Flags: []string{"-interval=sec: polling interval (default 2)"}, Description: "reload the sibling files of the frame (.inp, .otl, .ohx, .ohy, .rat2, .lst, .wgt, .kjn) when they change on disk, keeping the view and show settings. .inp is not reloaded over unsaved changes. without arguments shows the status"},
+	{Name: "empty", Usage: []string{":empty"}, Description: "discard the piped value"},
+	{Name: "jobs", Usage: []string{":jobs"}, Description: "list analysis jobs with their status. CANCELLING jobs are still running and writing their outputs"},
+	{Name: "cancel", Usage: []string{":cancel num..."}, Description: "remove queued analysis jobs from the queue. a running analysis can't be interrupted, so cancelling it doesn't free the queue: it runs to the end, still writes .otl/.ohx/.ohy and the next job starts only after it; only its results are not read"},
+	{Name: "help", Usage: []string{":help {command}", ":help {-markdown=filename} {-html=filename}"}, Flags: []string{"-markdown=file: write command reference in Markdown", "-html=file: write command reference in HTML"}, Description: "show help of ex-mode and fig2 commands"},
+	{Name: "write", Usage: []string{":write {filename}"}, Description: "save the frame. ! overwrites without asking"},
+	{Name: "save", Usage: []string{":save filename {-u=.strc} {-mkdir}"}, Flags: []string{"-u=file: resource file read after reopening (NONE: don't read)", "-mkdir: make the directory if it doesn't exist"}, Description: "save the frame (only selected elems if any) and continue with the new file"},
+	{Name: "increment", Usage: []string{":increment {times:1}"}, Description: "save the frame as the next numbered file"},
+	{Name: "tag", Usage: []string{":tag name", ":tag -d name..."}, Flags: []string{"-d: delete the tags"}, Description: "store a snapshot of the frame under .sttags/. ! overwrites"},
+	{Name: "tags", Usage: []string{":tags"}, Description: "list the tags of the frame"},
+	{Name: "selset", Usage: []string{":selset save name", ":selset load name", ":selset list", ":selset delete name..."}, Description: "save the selected nodes and elems as a named set in .selset file, or select them again. ! overwrites. load is pipeable. :elem selset=name and :node selset=name use the set in a query"},
+	{Name: "checkout", Usage: []string{":checkout name"}, Description: "restore a tagged snapshot"},
+	{Name: "diff", Usage: []string{":diff tag1 {tag2}"}, Description: "show added, removed and moved nodes and elems and changed sects between two tags (tag1 and the current frame without tag2)"},
+	{Name: "combination", Usage: []string{":combination {name=}expression...", ":combination -clear"}, Flags: []string{"-clear: remove all combinations and envelopes"}, Description: "define load combinations such as L+X, L-X or L+0.5X+0.3Y as periods available in 'period, captions, coloring, :max, :min and :writeoutput. names of periods with results (L, X, Y...) are rejected. lists them without arguments"},
+	{Name: "envelope", Usage: []string{":envelope {-name=ENV} {period...}"}, Flags: []string{"-name=name: periods NAMEMAX and NAMEMIN are defined; they must not be periods with results"}, Description: "define max/min envelopes of the periods (all combinations without arguments)"},
+	{Name: "export", Usage: []string{":export csv|json {filename} {-period=L,X,Y} {-fields=name,...} {-node}"}, Flags: []string{"-period=names: periods exported, which must have results (default: current period)", "-fields=names: columns exported (NODE,X,Y,Z,PERIOD,DX..MZ for nodes, ELEM,SECT,ETYPE,NODE,X,Y,Z,PERIOD,N,QX,QY,MT,MX,MY for elems)", "-node: export nodes to csv even if elems are selected (the nodes of the selected elems if no node is selected)"}, Description: "write coordinates, sects, etypes and results of the selected (or all visible) nodes and elems. a row for each entity, elem end and period. csv has line elems if any, otherwise nodes; plates have no stresses and are not exported. reports the numbers written"},
+	{Name: "storyreport", Usage: []string{":storyreport {-period=X,Y,name:dir} {-csv=filename}"}, Flags: []string{"-period=names: periods and their directions. X and Y need no direction", "-csv=file: write the table in CSV"}, Description: "show story shear, average and max drift angle, stiffness ratio Rs and eccentricity Re of each story defined by Ai.Boundary"},
+	{Name: "sectioneditor", Usage: []string{":sectioneditor"}, Description: "open the window listing the sects. create (New) or clone (Clone) a sect numbered NUM, set NAME, PROP and COLOR (Apply) and pick a shape of the steel catalogue to show its A, I and Z and set it (Set Shape)"},
+	{Name: "ai", Usage: []string{":ai {-boundary=b0,b1,...} {-base=0.2} {-locate=1.0} {-tfact=0.02} {-gperiod=0.6} {-apply} {-panel}"}, Flags: []string{"-boundary=values: set Ai.Boundary", "-base=value: standard shear coefficient C0", "-locate=value: seismic zone factor Z", "-tfact=value: T = H * tfact", "-gperiod=value: period Tc of the ground", "-apply: store the coefficients in the model (saved with the .inp, undoable) and regenerate the horizontal loads of X and Y", "-panel: open the panel to edit them"}, Description: "show the level, seismic weight, Wi, alpha, Ai, Ci, design shear Qi and force Hi of each floor"},
+	{Name: "table", Usage: []string{":table {node|elem}"}, Description: "list displacements and reactions of nodes or stresses of elem ends for the current period. click a column to sort, type a condition such as N>100 or numbers to filter, click a row to select"},
+	{Name: "compare", Usage: []string{":compare {-page=1} {filename}"}, Flags: []string{"-page=n: show the n-th page of the changes (50 per page); without filename, of the current comparison"}, Description: "color elems added (green), deleted (red) and modified (yellow) compared with another input file and report changed sects, bonds, cmqs, confs and loads. turn off without filename"},
+	{Name: "read", Usage: []string{":read {type} filename", ":read [$all,$data,$results] {-add} {-search}"}, Flags: []string{"-add: add results to the current ones", "-search: search elems when adding results"}, Description: "read data, result, srcan, list, weight, kijun, buckling or zoubun file"},
+	{Name: "insert", Usage: []string{":insert filename angle(deg)"}, Description: "insert an input file at the selected node"},
+	{Name: "propsect", Usage: []string{":propsect filename"}, Description: "add props and sects of an input file"},
+	{Name: "writeoutput", Usage: []string{":writeoutput filename period"}, Description: "write stresses of the selected (or all) elems"},
+	{Name: "writereaction", Usage: []string{":writereaction filename direction {-confed}"}, Flags: []string{"-confed: select confined nodes"}, Description: "write reactions of the selected nodes"},
+	{Name: "writekijun", Usage: []string{":writekijun filename"}, Description: "write kijuns to .kjn file"},
+	{Name: "zoubundisp", Usage: []string{":zoubundisp period direction"}, Description: "report incremental displacement of the selected nodes"},
+	{Name: "zoubunreaction", Usage: []string{":zoubunreaction period direction"}, Description: "report incremental reaction of the selected nodes"},
+	{Name: "weightcopy", Usage: []string{":weightcopy {-si}"}, Flags: []string{"-si: copy as SI weight file"}, Description: "copy hogtxt.wgt to .wgt of the frame and read it"},
+	{Name: "svg", Usage: []string{":svg filename"}, Description: "print the frame to svg"},
+	{Name: "check", Usage: []string{":check"}, Description: "check unconnected nodes, zero-length, duplicated and unstable elems, missing sects and allows, non-planar plates and free ground nodes. the history shows the number of findings of each kind; the findings are listed in a window to select them, and their nodes and elems are selected (or piped). pipeable"},
+	{Name: "elemduplication", Usage: []string{":elemduplication {-ignoresect=code}"}, Flags: []string{"-ignoresect=code: sects not checked"}, Description: "select duplicated elems"},
+	{Name: "intersectall", Usage: []string{":intersectall"}, Description: "divide the selected elems at their intersections"},
+	{Name: "srcal", Usage: []string{":srcal {-fbold} {-noreload} {-tmp}"}, Flags: []string{"-fbold: use old Fb", "-noreload: don't reload .lst", "-tmp: output to tmp"}, Description: "calculate section rates"},
+	{Name: "rate", Usage: []string{":rate {-top=50} {-fbold}", ":rate elemcode... {-fbold}"}, Flags: []string{"-top=n: number of elems listed (all with 0)", "-fbold: use old Fb"}, Description: "recompute the section rates of the selected (or all) elems with their allowables and list the worst ones. each row shows the governing check (QX, QY or N+M), case (L, L+X, L-X, L+Y, L-Y) and end; click a row to select the elem and show N, QX, QY, MX, MY stresses, allowables and rates of every case. long/short and shear/bending checks follow 'srcanrate. the rate stored by :srcal is shown with each breakdown and rows marked ! STORED don't reproduce it. with elemcodes the checks are written to history"},
+	{Name: "nminteraction", Usage: []string{":nminteraction sectcode {-ndiv=100} {-output=nmi.txt} {-axis=strong|weak} {-noplot}", ":nminteraction sectcode -n=value {-alpha=1.0} {-ndiv=100} {-output=nmi.txt} {-noplot}"}, Flags: []string{"-ndiv=n: number of divisions", "-output=file: output file", "-axis=weak: use My instead of Mx", "-n=value: draw the Mx-My contour under N (compression +)", "-alpha=value: exponent of the contour (Mx/Max)^a+(My/May)^a=1", "-noplot: don't open the plot window"}, Description: "write long and short term N-M interaction curves of the section and plot them with the N-M of the selected elems of the section. sections whose Ma depends on N (RC and SRC columns) use it; the others N/Na+M/Ma=1 as the section rate. demands are L against the long term curve and L+X, L-X, L+Y, L-Y against the short term one as :srcal"},
+	{Name: "gohanlst", Usage: []string{":gohanlst factor sectcode..."}, Description: "write gohan.lst for the brace sections"},
+	{Name: "kaberyo", Usage: []string{":kaberyo {-half=propcode} {-fc=val} {-alpha=val} {-route=val}"}, Flags: []string{"-half=propcode: props counted half", "-fc=val: concrete strength", "-alpha=val: alpha", "-route=[1,2-1,2-2]: route"}, Description: "sum up wall amount of the selected (or piped) elems"},
+	{Name: "facts", Usage: []string{":facts {-skipany=code} {-skipall=code}"}, Flags: []string{"-skipany=code: skip floors having any of sects", "-skipall=code: skip floors having only sects"}, Description: "write .fes file"},
+	{Name: "amountprop", Usage: []string{":amountprop propcode"}, Description: "write amount of the props to amount.txt"},
+	{Name: "amountlst", Usage: []string{":amountlst sectcode {-all}"}, Flags: []string{"-all: all sects under 900"}, Description: "write amount of the sects to amountlst.txt"},
+	{Name: "node", Usage: []string{":node nnum", ":node [x,y,z] [>,<,=] coord", ":node {confed/pinned/fixed/free}", ":node pile num", ":node {+=,-=,&=} query"}, Description: "select nodes. a query combines attributes (num, x, y, z, height, floor, dx..tz, rx..mz, weight, pile) and words (confed, free, pinned, fixed, hidden, locked, selset name) with and/or/not and parentheses, e.g. \"z > 0 and not (free or pile 1..3)\". +=, -= and &= add to, remove from and intersect with the current selection. pipeable"},
+	{Name: "conf", Usage: []string{":conf [0,1]{6}"}, Description: "set confinement of the selected nodes"},
+	{Name: "pile", Usage: []string{":pile pilecode"}, Description: "set pile of the selected nodes"},
+	{Name: "xscale", Usage: []string{":xscale factor coord"}, Description: "scale the selected nodes in x direction"},
+	{Name: "yscale", Usage: []string{":yscale factor coord"}, Description: "scale the selected nodes in y direction"},
+	{Name: "zscale", Usage: []string{":zscale factor coord"}, Description: "scale the selected nodes in z direction"},
+	{Name: "pload", Usage: []string{":pload position value"}, Description: "set nodal load of the selected nodes"},
+	{Name: "elem", Usage: []string{":elem [elemcode,sect sectcode,osect sectcode,etype,curtain,isgohan,error]", ":elem {+=,-=,&=} query"}, Flags: []string{"-threshold=val: threshold of error"}, Description: "select elems. a query combines attributes (num, sect, osect, length, rate, n, qx, qy, mt, mx, my, disp, area, ix, iy, x, y, z, height, floor) and words (column, girder, brace, wall, slab, curtain, isgohan, hidden, locked, selset name, parallel axis, ortho axis, onplane plane, adjoin query) with and/or/not and parentheses, e.g. \"column and floor=2 and rate in 0.8..1.0\" or \"3 <= length < 6\". stresses are those of the current period. +=, -= and &= add to, remove from and intersect with the current selection. pipeable"},
+	{Name: "fence", Usage: []string{":fence axis coord {-plate}"}, Flags: []string{"-plate: include plate elems"}, Description: "select elems crossing the plane. pipeable"},
+	{Name: "filter", Usage: []string{":filter condition"}, Description: "filter the selected elems. pipeable"},
+	{Name: "bond", Usage: []string{":bond [pin,rigid,[01_t]{6}] [upper,lower,sect sectcode]"}, Description: "set bonds of the selected (or piped) elems"},
+	{Name: "section+", Usage: []string{":section+ value"}, Description: "increase sectcode of the selected elems"},
+	{Name: "cang", Usage: []string{":cang val"}, Description: "set code angle of the selected (or piped) elems"},
+	{Name: "axis2cang", Usage: []string{":axis2cang n1 n2 [strong,weak]"}, Description: "set code angle of the selected elems from two nodes"},
+	{Name: "invert", Usage: []string{":invert"}, Description: "invert the selected (or piped) elems"},
+	{Name: "resultant", Usage: []string{":resultant"}, Description: "show resultant force of the selected elems at their common node"},
+	{Name: "prestress", Usage: []string{":prestress value"}, Description: "set prestress of the selected (or piped) elems"},
+	{Name: "thermal", Usage: []string{":thermal tmp[℃] {-alpha=val}"}, Flags: []string{"-alpha=val: coefficient of thermal expansion"}, Description: "set thermal load of the selected (or piped) elems"},
+	{Name: "divide", Usage: []string{":divide mid", ":divide n div", ":divide elem (eps)", ":divide ons (eps)", ":divide axis [x, y, z] coord", ":divide length l"}, Description: "divide the selected elems"},
+	{Name: "section", Usage: []string{":section sectcode {-nodisp}", ":section sectcode <-", ":section [off,curtain]"}, Flags: []string{"-nodisp: don't show section data"}, Description: "show section data. <- sets a piped shape. pipeable"},
+	{Name: "thick", Usage: []string{":thick nfig val"}, Description: "set thick of the piped section"},
+	{Name: "add", Usage: []string{":add elem {-sect=code} {-etype=type}", ":add sect sectcode {-shape=name}"}, Flags: []string{"-shape=name: shape such as H-400x200x8x13, BOX-200x9 or P-216.3x5.8 instead of a piped one"}, Description: "add an elem from piped nodes or a sect from a piped shape"},
+	{Name: "copy", Usage: []string{":copy sect sectcode"}, Description: "copy the piped section"},
+	{Name: "currentvalue", Usage: []string{":currentvalue {-abs}"}, Flags: []string{"-abs: absolute value"}, Description: "show current value of the selected elems"},
+	{Name: "max", Usage: []string{":max {-abs}"}, Flags: []string{"-abs: absolute value"}, Description: "select the elem/node with the max current value"},
+	{Name: "min", Usage: []string{":min {-abs}"}, Flags: []string{"-abs: absolute value"}, Description: "select the elem/node with the min current value"},
+	{Name: "average", Usage: []string{":average {-abs}"}, Flags: []string{"-abs: absolute value"}, Description: "show average of current values"},
+	{Name: "sum", Usage: []string{":sum {-abs}"}, Flags: []string{"-abs: absolute value"}, Description: "show sum of current values"},
+	{Name: "erase", Usage: []string{":erase"}, Description: "delete piped nodes and elems"},
+	{Name: "count", Usage: []string{":count"}, Description: "count piped nodes and elems and pass them on (:let n = :elem column | :count sets the number)"},
+	{Name: "show", Usage: []string{":show"}, Description: "show piped nodes and elems"},
+	{Name: "hide", Usage: []string{":hide"}, Description: "hide piped nodes and elems"},
+	{Name: "range", Usage: []string{":range [x,y,z] min max"}, Description: "set show range"},
+	{Name: "height", Usage: []string{":height f1 f2"}, Description: "show between floor boundaries"},
+	{Name: "storey", Usage: []string{":storey n"}, Description: "show the n-th storey"},
+	{Name: "floor", Usage: []string{":floor n"}, Description: "show the n-th floor"},
+	{Name: "height+", Usage: []string{":height+"}, Description: "show the next floor"},
+	{Name: "height-", Usage: []string{":height-"}, Description: "show the previous floor"},
+	{Name: "view", Usage: []string{":view [top,front,back,right,left]"}, Description: "set view angle"},
+	{Name: "printrange", Usage: []string{":printrange [on,true,yes/off,false,no] [a3tate,a3yoko,a4tate,a4yoko]"}, Description: "toggle print range"},
+	{Name: "paper", Usage: []string{":paper [a3tate,a3yoko,a4tate,a4yoko]"}, Description: "set paper size"},
+	{Name: "color", Usage: []string{":color [n,sect,rate,white,mono,strong,map]"}, Description: "set color mode. map uses the colormap of :colormap"},
+	{Name: "colormap", Usage: []string{":colormap preset {-scalar=rate} {-range=min,max|auto}", ":colormap boundaries colors {-continuous} {-scalar=rate}", ":colormap off"}, Flags: []string{"-scalar=name: rate, n, qx, qy, mt, mx, my (stresses of the current period), disp (displacement of enods) or area", "-range=min,max: range of the preset (default 0,1 for rate; auto, the default for the others, follows the values of the visible elems at each redraw)", "-continuous: interpolate colors placed at boundaries instead of steps"}, Description: "color elems by a scalar with a preset (traffic: green<0.8, yellow<1.0, red; okabeito; viridis, cividis, bluered: continuous; okabeito, viridis and cividis are colorblind-safe) or custom thresholds such as :colormap 0.8,1.0 green,yellow,red. colors are names or #RRGGBB. the legend shows the map. lists presets without arguments"},
+	{Name: "mono", Usage: []string{":mono"}, Description: "set color mode to white"},
+	{Name: "arclm001", Usage: []string{":arclm001 {-period=name} {-all} {-solver=name} {-eps=value} {-noinit} filename"}, Flags: []string{"-period=name: period", "-all: solve L, X and Y with one factorisation of the matrix", "-solver=name: LLS/CRS", "-eps=value: tolerance", "-noinit: don't initialise"}, Description: "linear static analysis"},
+	{Name: "arclm201", Usage: []string{":arclm201 {-period=name} {-lap=nlap} {-safety=val} {-start=val} {-noinit} filename"}, Flags: []string{"-period=name: period", "-lap=nlap: number of laps", "-safety=val: safety factor", "-start=val: starting factor", "-noinit: don't initialise"}, Description: "geometric nonlinear analysis"},
+	{Name: "arclm301", Usage: []string{":arclm301 {-period=name} {-sects=val} {-eps=val} {-noinit} filename"}, Flags: []string{"-period=name: period", "-sects=code: soil spring sects", "-eps=val: tolerance", "-noinit: don't initialise"}, Description: "analysis with soil springs"},
+}
+
+var fig2helps = []*CommandHelp{
+	{Name: "gfact", Usage: []string{"'gfact val"}, Description: "set scale of axonometric view"},
+	{Name: "focus", Usage: []string{"'focus [center,node nnum,elem enum]", "'focus x y z"}, Description: "set focus of view"},
+	{Name: "fit", Usage: []string{"'fit"}, Description: "fit the frame to the canvas"},
+	{Name: "angle", Usage: []string{"'angle phi theta"}, Description: "set view angle"},
+	{Name: "dists", Usage: []string{"'dists r l"}, Description: "set view distances"},
+	{Name: "perspective", Usage: []string{"'perspective"}, Description: "perspective view"},
+	{Name: "axonometric", Usage: []string{"'axonometric"}, Description: "axonometric view"},
+	{Name: "unit", Usage: []string{"'unit force,length"}, Description: "set unit. 'unit? shows current unit"},
+	{Name: "dfact", Usage: []string{"'dfact val"}, Description: "set deformation factor"},
+	{Name: "rfact", Usage: []string{"'rfact val"}, Description: "set reaction factor"},
+	{Name: "qfact", Usage: []string{"'qfact val"}, Description: "set shear factor"},
+	{Name: "mfact", Usage: []string{"'mfact val"}, Description: "set moment factor"},
+	{Name: "gaxis", Usage: []string{"'gaxis {size}"}, Description: "show global axis"},
+	{Name: "eaxis", Usage: []string{"'eaxis {size}"}, Description: "show element axis"},
+	{Name: "noaxis", Usage: []string{"'noaxis"}, Description: "hide global and element axis"},
+	{Name: "elem", Usage: []string{"'elem etype..."}, Description: "show only the etypes"},
+	{Name: "elem+", Usage: []string{"'elem+ etype..."}, Description: "show the etypes"},
+	{Name: "elem-", Usage: []string{"'elem- etype..."}, Description: "hide the etypes"},
+	{Name: "section", Usage: []string{"'section sectcode..."}, Description: "show only the sections"},
+	{Name: "section+", Usage: []string{"'section+ sectcode..."}, Description: "show the sections"},
+	{Name: "section-", Usage: []string{"'section- sectcode..."}, Description: "hide the sections"},
+	{Name: "kijun", Usage: []string{"'kijun"}, Description: "show kijuns. 'kijunname {min} {max} shows the range along the kijun"},
+	{Name: "measure", Usage: []string{"'measure kijun x1 x2 offset dotsize rotate overwrite", "'measure nnum1 nnum2 direction offset dotsize rotate overwrite"}, Description: "add a measure"},
+	{Name: "elemcode", Usage: []string{"'elemcode"}, Description: "show elem codes"},
+	{Name: "sectcode", Usage: []string{"'sectcode"}, Description: "show sect codes"},
+	{Name: "width", Usage: []string{"'width"}, Description: "show elem widths"},
+	{Name: "height", Usage: []string{"'height"}, Description: "show elem heights"},
+	{Name: "srcancolor", Usage: []string{"'srcancolor"}, Description: "color elems by section rate"},
+	{Name: "srcanrate", Usage: []string{"'srcanrate [long/short] [q/m]"}, Description: "show section rates"},
+	{Name: "stress", Usage: []string{"'stress [etype/sectcode] [period] [stressname]"}, Description: "show stresses"},
+	{Name: "prestress", Usage: []string{"'prestress"}, Description: "show prestresses"},
+	{Name: "stiff", Usage: []string{"'stiff [x,y]"}, Description: "show lateral stiffness"},
+	{Name: "drift", Usage: []string{"'drift [x,y]"}, Description: "show drift angles"},
+	{Name: "deformation", Usage: []string{"'deformation {period}"}, Description: "show deformation"},
+	{Name: "disp", Usage: []string{"'disp period [x,y,z,tx,ty,tz]"}, Description: "show displacements"},
+	{Name: "eccentric", Usage: []string{"'eccentric {size}"}, Description: "show centre of mass and rigidity"},
+	{Name: "draw", Usage: []string{"'draw [etype,sectcode] {size}"}, Description: "draw section shapes"},
+	{Name: "alias", Usage: []string{"'alias sectcode {name}"}, Description: "set section alias"},
+	{Name: "anonymous", Usage: []string{"'anonymous sectcode..."}, Description: "hide section names"},
+	{Name: "nodecode", Usage: []string{"'nodecode"}, Description: "show node codes"},
+	{Name: "weight", Usage: []string{"'weight"}, Description: "show node weights"},
+	{Name: "conf", Usage: []string{"'conf {size}"}, Description: "show confinements"},
+	{Name: "pilecode", Usage: []string{"'pilecode"}, Description: "show pile codes"},
+	{Name: "fence", Usage: []string{"'fence [x,y,z] coord"}, Description: "show only elems crossing the plane"},
+	{Name: "period", Usage: []string{"'period name"}, Description: "set period"},
+	{Name: "period++", Usage: []string{"'period++"}, Description: "next lap of the period"},
+	{Name: "period--", Usage: []string{"'period--"}, Description: "previous lap of the period"},
+	{Name: "nocaption", Usage: []string{"'nocaption"}, Description: "hide all captions"},
+	{Name: "nolegend", Usage: []string{"'nolegend"}, Description: "hide legend"},
+	{Name: "noshearvalue", Usage: []string{"'noshearvalue"}, Description: "hide shear values"},
+	{Name: "nomomentvalue", Usage: []string{"'nomomentvalue"}, Description: "hide moment values"},
+	{Name: "sheararrow", Usage: []string{"'sheararrow"}, Description: "show shear arrows"},
+	{Name: "momentfigure", Usage: []string{"'momentfigure"}, Description: "show moment figures"},
+	{Name: "ncolor", Usage: []string{"'ncolor"}, Description: "color elems by axial force"},
+	{Name: "pagetitle", Usage: []string{"'pagetitle text"}, Description: "add page title"},
+	{Name: "title", Usage: []string{"'title text"}, Description: "add title"},
+	{Name: "text", Usage: []string{"'text text"}, Description: "add text"},
+	{Name: "position", Usage: []string{"'position [pagetitle,title,text,legend] x y"}, Description: "set position of texts"},
+}
+
+func init() {
+	for _, h := range exhelps {
+		ExHelp[h.Name] = h
+	}
+	ExHelp["story"] = ExHelp["storey"]
+	for _, ab := range exabbrev {
+		if h, ok := ExHelp[abbrev.MustCompile(ab).Longest()]; ok {
+			h.Abbrev = ab
+		}
+	}
+	for _, h := range fig2helps {
+		Fig2Help[h.Name] = h
+	}
+	for _, ab := range fig2abbrev {
+		if h, ok := Fig2Help[abbrev.MustCompile(ab).Longest()]; ok {
+			h.Abbrev = ab
+		}
+	}
+}
+
+func (stw *Window) Help(name string) error {
+	switch {
+	case name == "":
+		names := make([]string, len(exhelps))
+		for i, h := range exhelps {
+			names[i] = h.Name
+		}
+		sort.Strings(names)
+		stw.History(fmt.Sprintf("EX: %s", strings.Join(names, " ")))
+		names = make([]string, len(fig2helps))
+		for i, h := range fig2helps {
+			names[i] = h.Name
+		}
+		sort.Strings(names)
+		stw.History(fmt.Sprintf("FIG2: %s", strings.Join(names, " ")))
+	case strings.HasPrefix(name, "'"):
+		key, _ := fig2keywordcomplete(name)
+		if h, ok := Fig2Help[key]; ok {
+			stw.History(h.String())
+		} else {
+			return errors.New(fmt.Sprintf("no fig2 keyword: %s", key))
+		}
+	default:
+		cname, _, _ := exmodecomplete(name)
+		if h, ok := ExHelp[cname]; ok {
+			stw.History(h.String())
+		} else {
+			return errors.New(fmt.Sprintf("no exmode command: %s", cname))
+		}
+	}
+	return nil
+}
+
+func WriteHelpMarkdown(fn string) error {
+	var otp bytes.Buffer
+	for i, helps := range [][]*CommandHelp{exhelps, fig2helps} {
+		if i == 0 {
+			otp.WriteString("# Ex-mode commands\n\n")
+		} else {
+			otp.WriteString("# Fig2 keywords\n\n")
+		}
+		for _, h := range helps {
+			otp.WriteString(fmt.Sprintf("## %s\n\n", h.Name))
+			otp.WriteString(fmt.Sprintf("%s\n\n", h.Description))
+			if h.Abbrev != "" {
+				otp.WriteString(fmt.Sprintf("Abbreviation: `%s`\n\n", h.Abbrev))
+			}
+			otp.WriteString("```\n")
+			for _, u := range h.Usage {
+				otp.WriteString(fmt.Sprintf("%s\n", u))
+			}
+			otp.WriteString("```\n\n")
+			for _, f := range h.Flags {
+				otp.WriteString(fmt.Sprintf("- `%s`\n", f))
+			}
+			if len(h.Flags) > 0 {
+				otp.WriteString("\n")
+			}
+		}
+	}
+	w, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	otp.WriteTo(w)
+	return nil
+}
+
+func WriteHelpHTML(fn string) error {
+	var otp bytes.Buffer
+	otp.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>stx commands</title>\n</head>\n<body>\n")
+	for i, helps := range [][]*CommandHelp{exhelps, fig2helps} {
+		if i == 0 {
+			otp.WriteString("<h1>Ex-mode commands</h1>\n")
+		} else {
+			otp.WriteString("<h1>Fig2 keywords</h1>\n")
+		}
+		for _, h := range helps {
+			otp.WriteString(fmt.Sprintf("<h2 id=\"%s\">%s</h2>\n", html.EscapeString(h.Name), html.EscapeString(h.Name)))
+			otp.WriteString(fmt.Sprintf("<p>%s</p>\n", html.EscapeString(h.Description)))
+			if h.Abbrev != "" {
+				otp.WriteString(fmt.Sprintf("<p>Abbreviation: <code>%s</code></p>\n", html.EscapeString(h.Abbrev)))
+			}
+			otp.WriteString("<pre>\n")
+			for _, u := range h.Usage {
+				otp.WriteString(fmt.Sprintf("%s\n", html.EscapeString(u)))
+			}
+			otp.WriteString("</pre>\n")
+			if len(h.Flags) > 0 {
+				otp.WriteString("<ul>\n")
+				for _, f := range h.Flags {
+					otp.WriteString(fmt.Sprintf("<li><code>%s</code></li>\n", html.EscapeString(f)))
+				}
+				otp.WriteString("</ul>\n")
+			}
+		}
+	}
+	otp.WriteString("</body>\n</html>\n")
+	w, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	otp.WriteTo(w)
+	return nil
+}
@@ -0,0 +1,249 @@
+package stgxui
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/yofu/st/arclm"
+	"github.com/yofu/st/stlib"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	JOB_QUEUED = iota
+	JOB_RUNNING
+	JOB_DONE
+	JOB_FAILED
+	JOB_CANCELLED
+)
+
+var (
+	JOBSTATUS    = []string{"QUEUED", "RUNNING", "DONE", "FAILED", "CANCELLED"}
+	jobqueuesize = 64
+)
+
+// Job is an analysis run by JobManager.
+// Run is called in the worker goroutine and must return when the analysis ends.
+// Lap is called in the UI thread each time Arclm sends on Lapch, Done after Run returns without error.
+type Job struct {
+	Num       int
+	Name      string
+	Status    int
+	Err       error
+	Laps      int
+	Nlap      int
+	Submitted time.Time
+	Started   time.Time
+	Finished  time.Time
+	Frame     *st.Frame
+	Arclm     *arclm.Frame
+	Run       func() error
+	Lap       func(nlap int)
+	Done      func()
+	cancel    bool
+}
+
+func (job *Job) String() string {
+	status := JOBSTATUS[job.Status]
+	note := ""
+	switch {
+	case job.Status == JOB_RUNNING && job.cancel:
+		status = "CANCELLING"
+		note = " (runs to the end and writes its outputs; results won't be read)"
+	case job.Status == JOB_CANCELLED && !job.Started.IsZero():
+		note = " (ran to the end and wrote its outputs; results weren't read)"
+	}
+	var elapsed time.Duration
+	switch job.Status {
+	case JOB_RUNNING:
+		elapsed = time.Since(job.Started)
+	case JOB_DONE, JOB_FAILED, JOB_CANCELLED:
+		if !job.Started.IsZero() {
+			elapsed = job.Finished.Sub(job.Started)
+		}
+	}
+	rtn := fmt.Sprintf("%3d %-20s %-10s LAP: %3d / %3d %s%s", job.Num, job.Name, status, job.Nlap, job.Laps, elapsed-elapsed%time.Second, note)
+	if job.Err != nil {
+		rtn += fmt.Sprintf(" %s", job.Err.Error())
+	}
+	return rtn
+}
+
+type JobManager struct {
+	sync.Mutex
+	stw   *Window
+	jobs  []*Job
+	queue chan *Job
+}
+
+func NewJobManager(stw *Window) *JobManager {
+	jm := &JobManager{
+		stw:   stw,
+		jobs:  make([]*Job, 0),
+		queue: make(chan *Job, jobqueuesize),
+	}
+	go jm.work()
+	return jm
+}
+
+func (jm *JobManager) Submit(job *Job) error {
+	jm.Lock()
+	defer jm.Unlock()
+	job.Num = len(jm.jobs) + 1
+	job.Status = JOB_QUEUED
+	job.Submitted = time.Now()
+	select {
+	case jm.queue <- job:
+		jm.jobs = append(jm.jobs, job)
+		return nil
+	default:
+		return errors.New(fmt.Sprintf("Submit: job queue is full (%d)", jobqueuesize))
+	}
+}
+
+// Cancel removes a queued job from the queue.
+// A running analysis can't be interrupted: arclm has no stop hook, and leaving its Lapch handshake
+// unanswered would block the arclm.Frame for the next job. It runs to the end, writes its outputs
+// and holds the queue until then; only its results are not read. The returned string says which happened.
+func (jm *JobManager) Cancel(num int) (string, error) {
+	jm.Lock()
+	defer jm.Unlock()
+	if num < 1 || num > len(jm.jobs) {
+		return "", errors.New(fmt.Sprintf("Cancel: job %d doesn't exist", num))
+	}
+	job := jm.jobs[num-1]
+	switch job.Status {
+	case JOB_QUEUED:
+		job.Status = JOB_CANCELLED
+		job.Finished = time.Now()
+		return fmt.Sprintf("job %d %s: removed from the queue", num, job.Name), nil
+	case JOB_RUNNING:
+		job.cancel = true
+		return fmt.Sprintf("job %d %s: can't be interrupted; it runs to the end, writes its outputs and holds the queue until then, but its results won't be read", num, job.Name), nil
+	default:
+		return "", errors.New(fmt.Sprintf("Cancel: job %d is already %s", num, strings.ToLower(JOBSTATUS[job.Status])))
+	}
+}
+
+func (jm *JobManager) String() string {
+	jm.Lock()
+	defer jm.Unlock()
+	if len(jm.jobs) == 0 {
+		return "no jobs"
+	}
+	var otp bytes.Buffer
+	for _, job := range jm.jobs {
+		otp.WriteString(fmt.Sprintf("%s\n", job))
+	}
+	return strings.TrimSuffix(otp.String(), "\n")
+}
+
+func (jm *JobManager) cancelled(job *Job) bool {
+	jm.Lock()
+	defer jm.Unlock()
+	return job.cancel
+}
+
+// work runs queued jobs one by one.
+// A running analysis can't be interrupted, so cancelling it only discards its results and the queue moves on when it returns.
+// Stopping the handshake of Lapch would leave Arclm blocked on a frame reused by the next analyses.
+// A job without Arclm follows its analyses by itself in Run.
+func (jm *JobManager) work() {
+	stw := jm.stw
+	for job := range jm.queue {
+		jm.Lock()
+		if job.Status == JOB_CANCELLED {
+			jm.Unlock()
+			continue
+		}
+		job.Status = JOB_RUNNING
+		job.Started = time.Now()
+		jm.Unlock()
+		stw.driver.Call(func() {
+			stw.CurrentLap(fmt.Sprintf("%s: Calculating...", job.Name), 0, job.Laps)
+			stw.Redraw()
+		})
+		if job.Arclm == nil {
+			jm.finish(job, job.Run())
+			continue
+		}
+		af := job.Arclm
+		go func(j *Job) {
+			af.Endch <- j.Run()
+		}(job)
+		err := jm.follow(job, af, func(nlap int) {
+			jm.SetLap(job, nlap)
+			if job.Lap != nil {
+				job.Lap(nlap)
+			}
+		})
+		jm.finish(job, err)
+	}
+}
+
+// follow relays laps of af to the UI thread until af sends on Endch.
+func (jm *JobManager) follow(job *Job, af *arclm.Frame, lap func(nlap int)) error {
+	for {
+		select {
+		case nlap := <-af.Lapch:
+			if !jm.cancelled(job) {
+				jm.stw.driver.CallSync(func() {
+					lap(nlap)
+				})
+			}
+			af.Lapch <- 1
+		case err := <-af.Endch:
+			return err
+		}
+	}
+}
+
+// SetLap updates the progress of job. It must be called in the UI thread.
+func (jm *JobManager) SetLap(job *Job, nlap int) {
+	jm.Lock()
+	job.Nlap = nlap
+	jm.Unlock()
+	jm.stw.resultgen++
+	if jm.stw.Frame == job.Frame {
+		jm.stw.CurrentLap(fmt.Sprintf("%s: Calculating...", job.Name), nlap, job.Laps)
+		jm.stw.Redraw()
+	}
+}
+
+func (jm *JobManager) finish(job *Job, err error) {
+	stw := jm.stw
+	jm.Lock()
+	job.Finished = time.Now()
+	switch {
+	case job.cancel:
+		job.Status = JOB_CANCELLED
+	case err != nil:
+		job.Status = JOB_FAILED
+		job.Err = err
+	default:
+		job.Status = JOB_DONE
+		job.Nlap = job.Laps
+	}
+	status := job.Status
+	jm.Unlock()
+	stw.driver.Call(func() {
+		switch status {
+		case JOB_FAILED:
+			stw.CurrentLap(fmt.Sprintf("%s: Failed", job.Name), job.Nlap, job.Laps)
+			stw.ErrorMessage(errors.New(fmt.Sprintf("job %d %s: %s", job.Num, job.Name, err.Error())), ERROR)
+		case JOB_CANCELLED:
+			stw.CurrentLap(fmt.Sprintf("%s: Cancelled", job.Name), job.Nlap, job.Laps)
+			stw.ErrorMessage(errors.New(fmt.Sprintf("job %d %s: cancelled; its outputs were written but not read", job.Num, job.Name)), INFO)
+		case JOB_DONE:
+			if job.Done != nil {
+				job.Done()
+			}
+			stw.resultgen++
+			stw.CurrentLap("Completed", job.Laps, job.Laps)
+			stw.ErrorMessage(errors.New(fmt.Sprintf("job %d %s: completed in %s", job.Num, job.Name, job.Finished.Sub(job.Started))), INFO)
+		}
+		stw.Redraw()
+	})
+}
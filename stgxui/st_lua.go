@@ -0,0 +1,537 @@
+package stgxui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/yofu/st/stlib"
+	"github.com/yuin/gopher-lua"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Scripts of :script are Lua (gopher-lua, pure Go) with a global table "st":
+//
+//	st.nodes(), st.node(num), st.elems(), st.elem(num), st.sects(), st.sect(num)
+//	st.selected(), st.selectednodes(), st.select(items), st.query(query), st.querynodes(query)
+//	st.command(":ex command" or "'fig2 command"), st.redraw(), st.history(str)
+//	st.period(), st.setperiod(period), st.periods(), st.floors(), st.frame()
+//
+// Nodes have num, x, y, z, coord, conf, pile, disp(period, i), reaction(period, i) and weight(i).
+// Elems have num, etype, sect, enod, length, midpoint, n(period), stress(period, end, i) and rate().
+// Sects have num, name, area, ix and iy. Nodes, elems and sects compare equal by number.
+// Indices are 1-based as Lua tables: i is 1 to 6 (x, y, z, tx, ty, tz or N, QX, QY, MT, MX, MY),
+// end is 1 or 2 and weight(i) is 1 to 3 (2 by default).
+// print writes to the history.
+const (
+	luaNodeType = "st.node"
+	luaElemType = "st.elem"
+	luaSectType = "st.sect"
+)
+
+func (stw *Window) luaState() *lua.LState {
+	if stw.lua != nil {
+		return stw.lua
+	}
+	L := lua.NewState()
+	for _, t := range []struct {
+		name  string
+		index lua.LGFunction
+	}{
+		{luaNodeType, stw.luaNodeIndex},
+		{luaElemType, stw.luaElemIndex},
+		{luaSectType, stw.luaSectIndex},
+	} {
+		mt := L.NewTypeMetatable(t.name)
+		L.SetField(mt, "__index", L.NewFunction(t.index))
+		L.SetField(mt, "__eq", L.NewFunction(luaEq))
+		L.SetField(mt, "__tostring", L.NewFunction(luaToString))
+	}
+	mod := L.NewTable()
+	L.SetFuncs(mod, map[string]lua.LGFunction{
+		"nodes":         stw.luaNodes,
+		"node":          stw.luaNode,
+		"elems":         stw.luaElems,
+		"elem":          stw.luaElem,
+		"sects":         stw.luaSects,
+		"sect":          stw.luaSect,
+		"selected":      stw.luaSelected,
+		"selectednodes": stw.luaSelectedNodes,
+		"select":        stw.luaSelect,
+		"query":         stw.luaQuery,
+		"querynodes":    stw.luaQueryNodes,
+		"command":       stw.luaCommand,
+		"redraw":        stw.luaRedraw,
+		"history":       stw.luaHistory,
+		"period":        stw.luaPeriod,
+		"setperiod":     stw.luaSetPeriod,
+		"periods":       stw.luaPeriods,
+		"floors":        stw.luaFloors,
+		"frame":         stw.luaFrame,
+	})
+	L.SetGlobal("st", mod)
+	L.SetGlobal("print", L.NewFunction(stw.luaHistory))
+	stw.lua = L
+	return L
+}
+
+// LuaTimeout is the default time limit of a script. Scripts run in the UI thread, which can't take Esc while they run.
+var LuaTimeout = 60 * time.Second
+
+// RunLuaFile runs a Lua script with args as the global table arg (arg[0] is filename).
+// The script is stopped when it runs longer than timeout (no limit with timeout <= 0).
+// Functions and globals defined by a script remain for the next ones.
+func (stw *Window) RunLuaFile(filename string, args []string, timeout time.Duration) error {
+	L := stw.luaState()
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		L.SetContext(ctx)
+		defer L.RemoveContext()
+	}
+	t := L.NewTable()
+	t.RawSetInt(0, lua.LString(filename))
+	for i, a := range args {
+		t.RawSetInt(i+1, lua.LString(a))
+	}
+	L.SetGlobal("arg", t)
+	err := L.DoFile(filename)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return errors.New(fmt.Sprintf("%s: stopped after %s (-timeout)", filename, timeout))
+	}
+	return luaError(err)
+}
+
+func (stw *Window) checkFrame(L *lua.LState) *st.Frame {
+	if stw.Frame == nil {
+		L.RaiseError("no frame")
+	}
+	return stw.Frame
+}
+
+func luaNumbers(L *lua.LState, vals []float64) *lua.LTable {
+	t := L.NewTable()
+	for _, v := range vals {
+		t.Append(lua.LNumber(v))
+	}
+	return t
+}
+
+func luaUserData(L *lua.LState, typ string, v interface{}) lua.LValue {
+	ud := L.NewUserData()
+	ud.Value = v
+	L.SetMetatable(ud, L.GetTypeMetatable(typ))
+	return ud
+}
+
+func luaNodeValue(L *lua.LState, n *st.Node) lua.LValue {
+	if n == nil {
+		return lua.LNil
+	}
+	return luaUserData(L, luaNodeType, n)
+}
+
+func luaElemValue(L *lua.LState, el *st.Elem) lua.LValue {
+	if el == nil {
+		return lua.LNil
+	}
+	return luaUserData(L, luaElemType, el)
+}
+
+func luaSectValue(L *lua.LState, sec *st.Sect) lua.LValue {
+	if sec == nil {
+		return lua.LNil
+	}
+	return luaUserData(L, luaSectType, sec)
+}
+
+func luaNodeList(L *lua.LState, ns []*st.Node) *lua.LTable {
+	t := L.NewTable()
+	for _, n := range ns {
+		if n != nil {
+			t.Append(luaNodeValue(L, n))
+		}
+	}
+	return t
+}
+
+func luaElemList(L *lua.LState, els []*st.Elem) *lua.LTable {
+	t := L.NewTable()
+	for _, el := range els {
+		if el != nil {
+			t.Append(luaElemValue(L, el))
+		}
+	}
+	return t
+}
+
+func luaNum(v interface{}) (string, int) {
+	switch v := v.(type) {
+	case *st.Node:
+		return "NODE", v.Num
+	case *st.Elem:
+		return "ELEM", v.Num
+	case *st.Sect:
+		return "SECT", v.Num
+	}
+	return "", 0
+}
+
+func luaEq(L *lua.LState) int {
+	k1, n1 := luaNum(L.CheckUserData(1).Value)
+	k2, n2 := luaNum(L.CheckUserData(2).Value)
+	L.Push(lua.LBool(k1 == k2 && n1 == n2))
+	return 1
+}
+
+func luaToString(L *lua.LState) int {
+	k, n := luaNum(L.CheckUserData(1).Value)
+	L.Push(lua.LString(fmt.Sprintf("%s %d", k, n)))
+	return 1
+}
+
+func luaCheckNode(L *lua.LState, n int) *st.Node {
+	if v, ok := L.CheckUserData(n).Value.(*st.Node); ok {
+		return v
+	}
+	L.ArgError(n, "node expected")
+	return nil
+}
+
+func luaCheckElem(L *lua.LState, n int) *st.Elem {
+	if v, ok := L.CheckUserData(n).Value.(*st.Elem); ok {
+		return v
+	}
+	L.ArgError(n, "elem expected")
+	return nil
+}
+
+func luaCheckSect(L *lua.LState, n int) *st.Sect {
+	if v, ok := L.CheckUserData(n).Value.(*st.Sect); ok {
+		return v
+	}
+	L.ArgError(n, "sect expected")
+	return nil
+}
+
+func (stw *Window) luaPeriodArg(L *lua.LState, n int) string {
+	return strings.ToUpper(L.OptString(n, stw.Frame.Show.Period))
+}
+
+// luaIndex returns the 1-based argument n (1 to max) as a 0-based index.
+func luaIndex(L *lua.LState, n int, max int) int {
+	i := L.CheckInt(n)
+	if i < 1 || i > max {
+		L.ArgError(n, fmt.Sprintf("1 to %d expected", max))
+	}
+	return i - 1
+}
+
+func (stw *Window) luaNodeIndex(L *lua.LState) int {
+	n := luaCheckNode(L, 1)
+	switch L.CheckString(2) {
+	default:
+		L.Push(lua.LNil)
+	case "num":
+		L.Push(lua.LNumber(n.Num))
+	case "x":
+		L.Push(lua.LNumber(n.Coord[0]))
+	case "y":
+		L.Push(lua.LNumber(n.Coord[1]))
+	case "z":
+		L.Push(lua.LNumber(n.Coord[2]))
+	case "coord":
+		L.Push(luaNumbers(L, n.Coord[:3]))
+	case "conf":
+		t := L.NewTable()
+		for i := 0; i < 6; i++ {
+			t.Append(lua.LBool(n.Conf[i]))
+		}
+		L.Push(t)
+	case "pile":
+		if n.Pile == nil {
+			L.Push(lua.LNil)
+		} else {
+			L.Push(lua.LNumber(n.Pile.Num))
+		}
+	case "disp":
+		L.Push(L.NewFunction(func(L *lua.LState) int {
+			L.Push(lua.LNumber(luaCheckNode(L, 1).ReturnDisp(stw.luaPeriodArg(L, 2), luaIndex(L, 3, 6))))
+			return 1
+		}))
+	case "reaction":
+		L.Push(L.NewFunction(func(L *lua.LState) int {
+			L.Push(lua.LNumber(luaCheckNode(L, 1).ReturnReaction(stw.luaPeriodArg(L, 2), luaIndex(L, 3, 6))))
+			return 1
+		}))
+	case "weight":
+		L.Push(L.NewFunction(func(L *lua.LState) int {
+			i := 1
+			if L.GetTop() >= 2 {
+				i = luaIndex(L, 2, 3)
+			}
+			L.Push(lua.LNumber(luaCheckNode(L, 1).Weight[i]))
+			return 1
+		}))
+	}
+	return 1
+}
+
+func (stw *Window) luaElemIndex(L *lua.LState) int {
+	el := luaCheckElem(L, 1)
+	switch L.CheckString(2) {
+	default:
+		L.Push(lua.LNil)
+	case "num":
+		L.Push(lua.LNumber(el.Num))
+	case "etype":
+		L.Push(lua.LString(st.ETYPES[el.Etype]))
+	case "sect":
+		L.Push(luaSectValue(L, el.Sect))
+	case "enod":
+		L.Push(luaNodeList(L, el.Enod[:el.Enods]))
+	case "length":
+		if el.IsLineElem() {
+			L.Push(lua.LNumber(el.Length()))
+		} else {
+			L.Push(lua.LNil)
+		}
+	case "midpoint":
+		L.Push(luaNumbers(L, el.MidPoint()))
+	case "n":
+		L.Push(L.NewFunction(func(L *lua.LState) int {
+			L.Push(lua.LNumber(luaCheckElem(L, 1).N(stw.luaPeriodArg(L, 2), 0)))
+			return 1
+		}))
+	case "stress":
+		L.Push(L.NewFunction(func(L *lua.LState) int {
+			e := luaCheckElem(L, 1)
+			per := stw.luaPeriodArg(L, 2)
+			if !e.IsLineElem() {
+				L.Push(lua.LNil)
+				return 1
+			}
+			if _, ok := e.Stress[per]; !ok {
+				L.Push(lua.LNil)
+				return 1
+			}
+			L.Push(lua.LNumber(e.ReturnStress(per, luaIndex(L, 3, 2), luaIndex(L, 4, 6))))
+			return 1
+		}))
+	case "rate":
+		L.Push(L.NewFunction(func(L *lua.LState) int {
+			val, err := luaCheckElem(L, 1).RateMax(stw.Frame.Show)
+			if err != nil {
+				L.Push(lua.LNil)
+				L.Push(lua.LString(err.Error()))
+				return 2
+			}
+			L.Push(lua.LNumber(val))
+			return 1
+		}))
+	}
+	return 1
+}
+
+func (stw *Window) luaSectIndex(L *lua.LState) int {
+	sec := luaCheckSect(L, 1)
+	prop := func(f func(int) (float64, error)) {
+		val, err := f(0)
+		if err != nil {
+			L.Push(lua.LNil)
+		} else {
+			L.Push(lua.LNumber(val))
+		}
+	}
+	switch L.CheckString(2) {
+	default:
+		L.Push(lua.LNil)
+	case "num":
+		L.Push(lua.LNumber(sec.Num))
+	case "name":
+		L.Push(lua.LString(sec.Name))
+	case "area":
+		prop(sec.Area)
+	case "ix":
+		prop(sec.Ix)
+	case "iy":
+		prop(sec.Iy)
+	}
+	return 1
+}
+
+func (stw *Window) luaNodes(L *lua.LState) int {
+	L.Push(luaNodeList(L, sortednodes(stw.checkFrame(L))))
+	return 1
+}
+
+func (stw *Window) luaNode(L *lua.LState) int {
+	L.Push(luaNodeValue(L, stw.checkFrame(L).Nodes[L.CheckInt(1)]))
+	return 1
+}
+
+func (stw *Window) luaElems(L *lua.LState) int {
+	L.Push(luaElemList(L, sortedelems(stw.checkFrame(L))))
+	return 1
+}
+
+func (stw *Window) luaElem(L *lua.LState) int {
+	L.Push(luaElemValue(L, stw.checkFrame(L).Elems[L.CheckInt(1)]))
+	return 1
+}
+
+func (stw *Window) luaSects(L *lua.LState) int {
+	frame := stw.checkFrame(L)
+	snums := make([]int, 0, len(frame.Sects))
+	for snum := range frame.Sects {
+		snums = append(snums, snum)
+	}
+	sort.Ints(snums)
+	t := L.NewTable()
+	for _, snum := range snums {
+		t.Append(luaSectValue(L, frame.Sects[snum]))
+	}
+	L.Push(t)
+	return 1
+}
+
+func (stw *Window) luaSect(L *lua.LState) int {
+	L.Push(luaSectValue(L, stw.checkFrame(L).Sects[L.CheckInt(1)]))
+	return 1
+}
+
+func (stw *Window) luaSelected(L *lua.LState) int {
+	L.Push(luaElemList(L, stw.SelectElem))
+	return 1
+}
+
+func (stw *Window) luaSelectedNodes(L *lua.LState) int {
+	L.Push(luaNodeList(L, stw.SelectNode))
+	return 1
+}
+
+// luaSelect selects the nodes and elems of a table, replacing the selection.
+func (stw *Window) luaSelect(L *lua.LState) int {
+	t := L.CheckTable(1)
+	stw.Deselect()
+	t.ForEach(func(_, v lua.LValue) {
+		ud, ok := v.(*lua.LUserData)
+		if !ok {
+			return
+		}
+		switch v := ud.Value.(type) {
+		case *st.Node:
+			stw.SelectNode = append(stw.SelectNode, v)
+		case *st.Elem:
+			stw.SelectElem = append(stw.SelectElem, v)
+		}
+	})
+	return 0
+}
+
+func (stw *Window) luaQuery(L *lua.LState) int {
+	stw.checkFrame(L)
+	els, err := stw.QueryElem(L.CheckString(1), stw.SelectElem)
+	if err != nil {
+		L.RaiseError("%s", err.Error())
+	}
+	L.Push(luaElemList(L, els))
+	return 1
+}
+
+func (stw *Window) luaQueryNodes(L *lua.LState) int {
+	stw.checkFrame(L)
+	ns, err := stw.QueryNode(L.CheckString(1), stw.SelectNode)
+	if err != nil {
+		L.RaiseError("%s", err.Error())
+	}
+	L.Push(luaNodeList(L, ns))
+	return 1
+}
+
+// luaCommand runs an ex command and returns its message, or nil and the error.
+// Other commands are run as typed on the command line.
+func (stw *Window) luaCommand(L *lua.LState) int {
+	com := stw.ExpandVariables(L.CheckString(1))
+	if !strings.HasPrefix(com, ":") {
+		stw.execAliasCommand(com)
+		return 0
+	}
+	out, err := stw.CommandResult(com)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(lua.LString(out.Message))
+	return 1
+}
+
+func (stw *Window) luaRedraw(L *lua.LState) int {
+	stw.Redraw()
+	return 0
+}
+
+func (stw *Window) luaHistory(L *lua.LState) int {
+	strs := make([]string, L.GetTop())
+	for i := range strs {
+		strs[i] = L.ToStringMeta(L.Get(i + 1)).String()
+	}
+	stw.History(strings.Join(strs, "\t"))
+	return 0
+}
+
+func (stw *Window) luaPeriod(L *lua.LState) int {
+	L.Push(lua.LString(stw.checkFrame(L).Show.Period))
+	return 1
+}
+
+func (stw *Window) luaSetPeriod(L *lua.LState) int {
+	stw.checkFrame(L)
+	stw.SetPeriod(strings.ToUpper(L.CheckString(1)))
+	return 0
+}
+
+func (stw *Window) luaPeriods(L *lua.LState) int {
+	t := L.NewTable()
+	for _, per := range stw.PeriodCandidates("") {
+		t.Append(lua.LString(per))
+	}
+	L.Push(t)
+	return 1
+}
+
+// luaFloors returns the Ai boundaries. Floor i (FLOOR of :elem) is between floors[i+1] and floors[i+2].
+func (stw *Window) luaFloors(L *lua.LState) int {
+	L.Push(luaNumbers(L, stw.checkFrame(L).Ai.Boundary))
+	return 1
+}
+
+func (stw *Window) luaFrame(L *lua.LState) int {
+	frame := stw.checkFrame(L)
+	t := L.NewTable()
+	t.RawSetString("name", lua.LString(frame.Name))
+	t.RawSetString("path", lua.LString(frame.Path))
+	t.RawSetString("nnode", lua.LNumber(len(frame.Nodes)))
+	t.RawSetString("nelem", lua.LNumber(len(frame.Elems)))
+	t.RawSetString("nsect", lua.LNumber(len(frame.Sects)))
+	xmin, xmax, ymin, ymax, zmin, zmax := frame.Bbox(true)
+	t.RawSetString("bbox", luaNumbers(L, []float64{xmin, xmax, ymin, ymax, zmin, zmax}))
+	L.Push(t)
+	return 1
+}
+
+// luaError drops the stack traceback from the errors of gopher-lua.
+func luaError(err error) error {
+	if lerr, ok := err.(*lua.ApiError); ok {
+		msg := lerr.Object.String()
+		if i := strings.Index(msg, "\nstack traceback:"); i >= 0 {
+			msg = msg[:i]
+		}
+		return errors.New(msg)
+	}
+	return err
+}
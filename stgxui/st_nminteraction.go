@@ -0,0 +1,346 @@
+package stgxui
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/google/gxui"
+	gxmath "github.com/google/gxui/math"
+	"github.com/yofu/st/stlib"
+	"math"
+	"os"
+	"sort"
+)
+
+const (
+	NMPLOT_SIZE   = 600
+	NMPLOT_MARGIN = 60
+)
+
+var (
+	NMCurvePens = map[string]gxui.Pen{
+		"L": gxui.CreatePen(1.5, gxui.Green),
+		"S": gxui.CreatePen(1.5, gxui.Yellow),
+	}
+	NMAxisPen     = gxui.CreatePen(0.5, gxui.Gray50)
+	NMPointColors = []gxui.Color{gxui.White, gxui.Red, gxui.Blue, gxui.Green, gxui.Yellow, gxui.Gray70}
+)
+
+// NMCurve is an interaction curve of a section: M is the allowable moment under the axial force N.
+// N is positive in compression as Elem.N.
+// A contour of NMContour holds Mx in N and My in M.
+type NMCurve struct {
+	Period string
+	Strong bool
+	N      []float64
+	M      []float64
+}
+
+func nmpen(period string) gxui.Pen {
+	if pen, ok := NMCurvePens[period]; ok {
+		return pen
+	}
+	return NMAxisPen
+}
+
+// maUnderN returns Ma of al given by st for axial force n.
+func maUnderN(al st.SectionRate, period string, strong bool, n float64) float64 {
+	cond := st.NewCondition()
+	cond.Period = period
+	cond.Strong = strong
+	cond.Positive = true
+	cond.Compression = n >= 0.0
+	cond.N = n
+	return al.Ma(cond)
+}
+
+// dependsOnN reports whether Ma of al given by st changes with N, as for RC and SRC columns.
+func dependsOnN(al st.SectionRate, period string, strong bool) bool {
+	nc, nt := axialCapacity(al, period)
+	ma := maUnderN(al, period, strong, 0.0)
+	for _, n := range []float64{0.5 * nc, -0.5 * nt} {
+		if n != 0.0 && math.Abs(maUnderN(al, period, strong, n)-ma) > 1e-6*math.Max(math.Abs(ma), 1.0) {
+			return true
+		}
+	}
+	return false
+}
+
+// momentCapacity returns the allowable moment of al under axial force n.
+// Sections whose Ma given by st depends on N (RC and SRC columns) use it as it is.
+// For the others (steel, wood, RC girders and walls) the section rate of st adds N/Na and M/Ma,
+// so Ma is reduced linearly by N/Na, and kept when there is no Na as for girders checked without N.
+func momentCapacity(al st.SectionRate, period string, strong bool, n float64) float64 {
+	if dependsOnN(al, period, strong) {
+		return maUnderN(al, period, strong, n)
+	}
+	ma := maUnderN(al, period, strong, 0.0)
+	cond := st.NewCondition()
+	cond.Period = period
+	cond.Compression = n >= 0.0
+	na := al.Na(cond)
+	if na == 0.0 {
+		return ma
+	}
+	return math.Max(ma*(1.0-math.Abs(n)/na), 0.0)
+}
+
+// axialCapacity returns the allowable compression and tension of al.
+func axialCapacity(al st.SectionRate, period string) (float64, float64) {
+	cond := st.NewCondition()
+	cond.Period = period
+	if rc, ok := al.(*st.RCColumn); ok {
+		return rc.Nmax(cond), -rc.Nmin(cond)
+	}
+	cond.Compression = true
+	nc := al.Na(cond)
+	cond.Compression = false
+	nt := al.Na(cond)
+	return nc, nt
+}
+
+// NMInteraction computes the interaction curve of al for period "L" (long term) or "S" (short term) with ndiv divisions.
+func NMInteraction(al st.SectionRate, period string, strong bool, ndiv int) (*NMCurve, error) {
+	nc, nt := axialCapacity(al, period)
+	if nc == 0.0 && nt == 0.0 && momentCapacity(al, period, strong, 0.0) == 0.0 {
+		return nil, errors.New("NMInteraction: no allowable")
+	}
+	curve := &NMCurve{
+		Period: period,
+		Strong: strong,
+		N:      make([]float64, ndiv+1),
+		M:      make([]float64, ndiv+1),
+	}
+	for i := 0; i <= ndiv; i++ {
+		n := nc - float64(i)*(nc+nt)/float64(ndiv)
+		curve.N[i] = n
+		curve.M[i] = momentCapacity(al, period, strong, n)
+	}
+	return curve, nil
+}
+
+// NMContour computes the Mx-My contour of al under axial force n with ndiv points.
+// (Mx/Max)^alpha + (My/May)^alpha = 1, alpha = 1 corresponds to the section rate.
+func NMContour(al st.SectionRate, period string, n float64, alpha float64, ndiv int) (*NMCurve, error) {
+	mxa := momentCapacity(al, period, true, n)
+	mya := momentCapacity(al, period, false, n)
+	if mxa == 0.0 || mya == 0.0 {
+		return nil, errors.New(fmt.Sprintf("NMContour: no allowable moment under N=%.3f", n))
+	}
+	curve := &NMCurve{
+		Period: period,
+		N:      make([]float64, ndiv+1),
+		M:      make([]float64, ndiv+1),
+	}
+	for i := 0; i <= ndiv; i++ {
+		theta := 2.0 * math.Pi * float64(i) / float64(ndiv)
+		c, s := math.Cos(theta), math.Sin(theta)
+		curve.N[i] = mxa * math.Copysign(math.Pow(math.Abs(c), 2.0/alpha), c)
+		curve.M[i] = mya * math.Copysign(math.Pow(math.Abs(s), 2.0/alpha), s)
+	}
+	return curve, nil
+}
+
+// NMDemand is the stress of an elem end for a case of RATECASES (L, L+X, L-X, L+Y, L-Y).
+// Allow is the period of the curve it is checked against ("L" or "S").
+// N is positive in compression and common to both ends.
+type NMDemand struct {
+	Elem   *st.Elem
+	Period string
+	Allow  string
+	End    int
+	N      float64
+	Mx     float64
+	My     float64
+}
+
+// NMDemands returns the demands of els combined as :srcal does: L for the long term curve,
+// L+X, L-X, L+Y and L-Y for the short term one.
+func NMDemands(els []*st.Elem) []*NMDemand {
+	rtn := make([]*NMDemand, 0)
+	for _, el := range els {
+		if el == nil || !el.IsLineElem() {
+			continue
+		}
+		if _, ok := el.Stress["L"]; !ok {
+			continue
+		}
+		for _, rc := range RATECASES {
+			if rc.Add != "" {
+				if _, ok := el.Stress[rc.Add]; !ok {
+					continue
+				}
+			}
+			stress := func(end, i int) float64 {
+				val := el.ReturnStress("L", end, i)
+				if rc.Add != "" {
+					val += rc.Factor * el.ReturnStress(rc.Add, end, i)
+				}
+				return val
+			}
+			n := el.N("L", 0)
+			if rc.Add != "" {
+				n += rc.Factor * el.N(rc.Add, 0)
+			}
+			for end := 0; end < 2; end++ {
+				rtn = append(rtn, &NMDemand{
+					Elem:   el,
+					Period: rc.Name,
+					Allow:  rc.Period,
+					End:    end,
+					N:      n,
+					Mx:     stress(end, 4),
+					My:     stress(end, 5),
+				})
+			}
+		}
+	}
+	return rtn
+}
+
+func WriteNMCurves(fn string, curves []*NMCurve) error {
+	var otp bytes.Buffer
+	for i, c := range curves {
+		if i > 0 {
+			otp.WriteString("\n\n")
+		}
+		otp.WriteString(fmt.Sprintf("# %s\n", c.Period))
+		for j := range c.N {
+			otp.WriteString(fmt.Sprintf("%.5f %.5f\n", c.N[j], c.M[j]))
+		}
+	}
+	w, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	_, err = otp.WriteTo(w)
+	return err
+}
+
+// NMPlot draws interaction curves and demand points.
+// In N-M mode the horizontal axis is M and the vertical one is N. In contour mode they are Mx and My.
+type NMPlot struct {
+	Title   string
+	Contour bool
+	Strong  bool
+	Curves  []*NMCurve
+	Demands []*NMDemand
+}
+
+func (p *NMPlot) point(d *NMDemand) (float64, float64) {
+	switch {
+	case p.Contour:
+		return d.Mx, d.My
+	case p.Strong:
+		return d.Mx, d.N
+	default:
+		return d.My, d.N
+	}
+}
+
+func (p *NMPlot) bounds() (float64, float64, float64, float64) {
+	xmin, xmax, ymin, ymax := 0.0, 0.0, 0.0, 0.0
+	update := func(x, y float64) {
+		xmin = math.Min(xmin, x)
+		xmax = math.Max(xmax, x)
+		ymin = math.Min(ymin, y)
+		ymax = math.Max(ymax, y)
+	}
+	for _, c := range p.Curves {
+		for i := range c.N {
+			if p.Contour {
+				update(c.N[i], c.M[i])
+			} else {
+				update(c.M[i], c.N[i])
+				update(-c.M[i], c.N[i])
+			}
+		}
+	}
+	for _, d := range p.Demands {
+		update(p.point(d))
+	}
+	if xmax == xmin {
+		xmax += 1.0
+	}
+	if ymax == ymin {
+		ymax += 1.0
+	}
+	return xmin, xmax, ymin, ymax
+}
+
+// Draw draws the plot on a canvas of NMPLOT_SIZE.
+func (p *NMPlot) Draw(stw *Window) gxui.Canvas {
+	size := NMPLOT_SIZE
+	canvas := stw.driver.CreateCanvas(gxmath.Size{W: size, H: size})
+	font := stw.theme.DefaultFont()
+	xmin, xmax, ymin, ymax := p.bounds()
+	scale := math.Min(float64(size-2*NMPLOT_MARGIN)/(xmax-xmin), float64(size-2*NMPLOT_MARGIN)/(ymax-ymin))
+	px := func(x float64) int {
+		return NMPLOT_MARGIN + int((x-xmin)*scale)
+	}
+	py := func(y float64) int {
+		return size - NMPLOT_MARGIN - int((y-ymin)*scale)
+	}
+	Line(canvas, NMAxisPen, px(xmin), py(0.0), px(xmax), py(0.0))
+	Line(canvas, NMAxisPen, px(0.0), py(ymin), px(0.0), py(ymax))
+	xlabel, ylabel := "M", "N (compression +)"
+	if p.Contour {
+		xlabel, ylabel = "Mx", "My"
+	} else if p.Strong {
+		xlabel = "Mx"
+	} else {
+		xlabel = "My"
+	}
+	Text(canvas, font, gxui.White, px(xmax)-20, py(0.0)-5, xlabel)
+	Text(canvas, font, gxui.White, px(0.0)+5, py(ymax)+15, ylabel)
+	Text(canvas, font, gxui.White, 10, 20, p.Title)
+	for _, c := range p.Curves {
+		pen := nmpen(c.Period)
+		if p.Contour {
+			vs := make([][]int, len(c.N))
+			for i := range c.N {
+				vs[i] = []int{px(c.N[i]), py(c.M[i])}
+			}
+			PolyLine(canvas, pen, vs)
+			continue
+		}
+		for _, sign := range []float64{1.0, -1.0} {
+			vs := make([][]int, len(c.N))
+			for i := range c.N {
+				vs[i] = []int{px(sign * c.M[i]), py(c.N[i])}
+			}
+			PolyLine(canvas, pen, vs)
+		}
+	}
+	periods := make([]string, 0)
+	colors := make(map[string]gxui.Color)
+	for _, d := range p.Demands {
+		if _, ok := colors[d.Period]; !ok {
+			colors[d.Period] = NMPointColors[len(periods)%len(NMPointColors)]
+			periods = append(periods, d.Period)
+		}
+		x, y := p.point(d)
+		FilledCircle(canvas, gxui.CreatePen(1.0, colors[d.Period]), px(x), py(y), 3)
+	}
+	sort.Strings(periods)
+	for i, per := range periods {
+		Text(canvas, font, colors[per], size-100, 20+15*i, fmt.Sprintf("* %s", per))
+	}
+	for i, c := range p.Curves {
+		Text(canvas, font, nmpen(c.Period).Color, size-100, 20+15*(len(periods)+i), fmt.Sprintf("- %s", c.Period))
+	}
+	canvas.Complete()
+	return canvas
+}
+
+// ShowNMPlot opens a window of the plot.
+func (stw *Window) ShowNMPlot(p *NMPlot) {
+	img := stw.theme.CreateImage()
+	img.SetCanvas(p.Draw(stw))
+	w := stw.theme.CreateWindow(NMPLOT_SIZE, NMPLOT_SIZE, p.Title)
+	w.AddChild(img)
+	w.OnClose(func() {
+		stw.dlg.SetFocus(stw.cline)
+	})
+}
@@ -0,0 +1,107 @@
+package stgxui
+
+import (
+	"errors"
+	"fmt"
+	"github.com/yofu/st/stlib"
+	"strings"
+)
+
+// PipeValue is passed from a stage of an ex-mode pipeline ":elem sect 101 | :invert" to the next one.
+// A stage sets the fields it produces. Nodes and elems may be piped together (:check, :erase, :count).
+type PipeValue struct {
+	Nodes []*st.Node
+	Elems []*st.Elem
+	Sects []*st.Sect
+	Shape st.Shape
+}
+
+// Type returns the kinds of the value such as "NODES+ELEMS", or "NOTHING".
+func (pv *PipeValue) Type() string {
+	if pv == nil {
+		return "NOTHING"
+	}
+	kinds := make([]string, 0)
+	if len(pv.Nodes) > 0 {
+		kinds = append(kinds, "NODES")
+	}
+	if len(pv.Elems) > 0 {
+		kinds = append(kinds, "ELEMS")
+	}
+	if len(pv.Sects) > 0 {
+		kinds = append(kinds, "SECTS")
+	}
+	if pv.Shape != nil {
+		kinds = append(kinds, "SHAPE")
+	}
+	if len(kinds) == 0 {
+		return "NOTHING"
+	}
+	return strings.Join(kinds, "+")
+}
+
+func (pv *PipeValue) String() string {
+	if pv == nil {
+		return "NOTHING"
+	}
+	return fmt.Sprintf("%s (%d NODES, %d ELEMS, %d SECTS)", pv.Type(), len(pv.Nodes), len(pv.Elems), len(pv.Sects))
+}
+
+func pipeTypeError(command, expected string, pv *PipeValue) error {
+	return errors.New(fmt.Sprintf("%s: expected %s from the pipe, got %s", command, expected, pv.Type()))
+}
+
+// NodeSet returns the piped nodes.
+func (pv *PipeValue) NodeSet(command string) ([]*st.Node, error) {
+	if pv == nil {
+		return nil, errors.New(fmt.Sprintf("%s no selected node", command))
+	}
+	if len(pv.Nodes) == 0 {
+		return nil, pipeTypeError(command, "NODES", pv)
+	}
+	return pv.Nodes, nil
+}
+
+// ElemSet returns the piped elems.
+func (pv *PipeValue) ElemSet(command string) ([]*st.Elem, error) {
+	if pv == nil {
+		return nil, errors.New(fmt.Sprintf("%s no selected elem", command))
+	}
+	if len(pv.Elems) == 0 {
+		return nil, pipeTypeError(command, "ELEMS", pv)
+	}
+	return pv.Elems, nil
+}
+
+// PipedSect returns the first piped sect.
+func (pv *PipeValue) PipedSect(command string) (*st.Sect, error) {
+	if pv == nil {
+		return nil, errors.New(fmt.Sprintf("%s no piped sect", command))
+	}
+	if len(pv.Sects) == 0 {
+		return nil, pipeTypeError(command, "SECT", pv)
+	}
+	return pv.Sects[0], nil
+}
+
+// PipedShape returns the piped shape.
+func (pv *PipeValue) PipedShape(command string) (st.Shape, error) {
+	if pv == nil {
+		return nil, errors.New(fmt.Sprintf("%s no piped shape", command))
+	}
+	if pv.Shape == nil {
+		return nil, pipeTypeError(command, "SHAPE", pv)
+	}
+	return pv.Shape, nil
+}
+
+// Entities returns the piped nodes and elems. Nothing piped is not an error.
+func (pv *PipeValue) Entities(command string) ([]*st.Node, []*st.Elem, error) {
+	if pv == nil {
+		return nil, nil, nil
+	}
+	if len(pv.Nodes) == 0 && len(pv.Elems) == 0 {
+		return nil, nil, pipeTypeError(command, "NODES or ELEMS", pv)
+	}
+	return pv.Nodes, pv.Elems, nil
+}
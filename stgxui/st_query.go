@@ -0,0 +1,787 @@
+package stgxui
+
+import (
+	"errors"
+	"fmt"
+	"github.com/yofu/abbrev"
+	"github.com/yofu/st/stlib"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Selection queries of :node and :elem.
+//
+//	query      := [+= | -= | &=] expr
+//	expr       := term {OR term}
+//	term       := factor {AND factor}
+//	factor     := NOT factor | ( expr ) | comparison | predicate
+//	comparison := attr op value | attr [=] value{[,] value} | attr [IN | =] min..max | min op attr op max
+//
+// "+=" adds the matching items to the current selection, "-=" removes them and "&=" keeps only them.
+// Words are case-insensitive.
+const (
+	queryNum = iota
+	queryWord
+	queryOp
+	queryEnd
+)
+
+var (
+	re_querynum   = regexp.MustCompile("^[-+]?([0-9]+([.][0-9]+)?|[.][0-9]+)([eE][-+]?[0-9]+)?")
+	re_queryword  = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*")
+	re_queryop    = regexp.MustCompile("^(<=|>=|==|!=|<|>|=|[.][.]|[(]|[)]|,)")
+	re_querysetop = regexp.MustCompile("^ *([-+&]=) *")
+	re_queryword2 = regexp.MustCompile("(?i)(^|[^A-Z])(AND|OR|NOT|IN)([^A-Z]|$)")
+)
+
+type queryToken struct {
+	kind int
+	str  string
+	val  float64
+}
+
+func (qt queryToken) String() string {
+	if qt.kind == queryEnd {
+		return "end of query"
+	}
+	return qt.str
+}
+
+// queryFunc is a compiled query evaluated on a *st.Node or a *st.Elem.
+type queryFunc func(interface{}) bool
+
+// queryAttr returns a numeric attribute. Items whose attribute can't be computed don't match.
+type queryAttr func(interface{}) (float64, error)
+
+func lexQuery(str string) ([]queryToken, error) {
+	rtn := make([]queryToken, 0)
+	pos := 0
+	for {
+		for pos < len(str) && (str[pos] == ' ' || str[pos] == '\t') {
+			pos++
+		}
+		if pos >= len(str) {
+			break
+		}
+		rest := str[pos:]
+		switch {
+		case re_querynum.MatchString(rest):
+			s := re_querynum.FindString(rest)
+			val, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, err
+			}
+			rtn = append(rtn, queryToken{queryNum, s, val})
+			pos += len(s)
+		case re_queryword.MatchString(rest):
+			s := re_queryword.FindString(rest)
+			rtn = append(rtn, queryToken{queryWord, strings.ToUpper(s), 0.0})
+			pos += len(s)
+		case re_queryop.MatchString(rest):
+			s := re_queryop.FindString(rest)
+			rtn = append(rtn, queryToken{queryOp, s, 0.0})
+			pos += len(s)
+		default:
+			return nil, errors.New(fmt.Sprintf("Query: unexpected character %c at %d", str[pos], pos))
+		}
+	}
+	return append(rtn, queryToken{queryEnd, "", 0.0}), nil
+}
+
+// IsQuery reports whether condition of :node or :elem needs the query language
+// rather than the older formats, which match only its beginning.
+func IsQuery(condition string) bool {
+	if re_querysetop.MatchString(condition) {
+		return true
+	}
+	if re_queryword2.MatchString(condition) {
+		return true
+	}
+	if strings.ContainsAny(condition, "<>") || strings.Contains(condition, "!=") || strings.Contains(condition, "..") {
+		return true
+	}
+	return strings.ContainsAny(strings.Replace(strings.ToUpper(condition), "RANGE(", "", -1), "()")
+}
+
+// splitQuery separates the set operator from the expression.
+func splitQuery(str string) (string, string) {
+	fs := re_querysetop.FindStringSubmatch(str)
+	if fs == nil {
+		return "", str
+	}
+	return fs[1], str[len(fs[0]):]
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	attrs  map[string]queryAttr
+	pred   func(*queryParser, string) (queryFunc, error)
+}
+
+func (qp *queryParser) peek() queryToken {
+	return qp.tokens[qp.pos]
+}
+
+func (qp *queryParser) next() queryToken {
+	t := qp.tokens[qp.pos]
+	if t.kind != queryEnd {
+		qp.pos++
+	}
+	return t
+}
+
+func (qp *queryParser) accept(kind int, str string) bool {
+	t := qp.peek()
+	if t.kind == kind && t.str == str {
+		qp.pos++
+		return true
+	}
+	return false
+}
+
+func (qp *queryParser) unexpected() error {
+	return errors.New(fmt.Sprintf("Query: unexpected %s", qp.peek()))
+}
+
+func (qp *queryParser) number() (float64, error) {
+	t := qp.peek()
+	if t.kind != queryNum {
+		return 0.0, errors.New(fmt.Sprintf("Query: number expected, got %s", t))
+	}
+	qp.pos++
+	return t.val, nil
+}
+
+// word returns the argument of a predicate such as the axis of PARALLEL.
+func (qp *queryParser) word() (string, error) {
+	t := qp.peek()
+	if t.kind != queryWord {
+		return "", errors.New(fmt.Sprintf("Query: word expected, got %s", t))
+	}
+	qp.pos++
+	return t.str, nil
+}
+
+func (qp *queryParser) parse() (queryFunc, error) {
+	f, err := qp.expr()
+	if err != nil {
+		return nil, err
+	}
+	if qp.peek().kind != queryEnd {
+		return nil, qp.unexpected()
+	}
+	return f, nil
+}
+
+func queryOr(f1, f2 queryFunc) queryFunc {
+	return func(v interface{}) bool {
+		return f1(v) || f2(v)
+	}
+}
+
+func queryAnd(f1, f2 queryFunc) queryFunc {
+	return func(v interface{}) bool {
+		return f1(v) && f2(v)
+	}
+}
+
+func queryNot(f queryFunc) queryFunc {
+	return func(v interface{}) bool {
+		return !f(v)
+	}
+}
+
+func (qp *queryParser) expr() (queryFunc, error) {
+	f, err := qp.term()
+	if err != nil {
+		return nil, err
+	}
+	for qp.accept(queryWord, "OR") {
+		f2, err := qp.term()
+		if err != nil {
+			return nil, err
+		}
+		f = queryOr(f, f2)
+	}
+	return f, nil
+}
+
+func (qp *queryParser) term() (queryFunc, error) {
+	f, err := qp.factor()
+	if err != nil {
+		return nil, err
+	}
+	for qp.accept(queryWord, "AND") {
+		f2, err := qp.factor()
+		if err != nil {
+			return nil, err
+		}
+		f = queryAnd(f, f2)
+	}
+	return f, nil
+}
+
+func (qp *queryParser) factor() (queryFunc, error) {
+	t := qp.peek()
+	switch {
+	case t.kind == queryWord && t.str == "NOT":
+		qp.pos++
+		f, err := qp.factor()
+		if err != nil {
+			return nil, err
+		}
+		return queryNot(f), nil
+	case t.kind == queryOp && t.str == "(":
+		qp.pos++
+		f, err := qp.expr()
+		if err != nil {
+			return nil, err
+		}
+		if !qp.accept(queryOp, ")") {
+			return nil, qp.unexpected()
+		}
+		return f, nil
+	case t.kind == queryNum:
+		return qp.between()
+	case t.kind == queryWord:
+		qp.pos++
+		if attr, ok := qp.attrs[t.str]; ok {
+			return qp.comparison(t.str, attr)
+		}
+		return qp.pred(qp, t.str)
+	}
+	return nil, qp.unexpected()
+}
+
+func isComparator(op string) bool {
+	switch op {
+	case "<", "<=", ">", ">=", "=", "==", "!=":
+		return true
+	}
+	return false
+}
+
+func compareFunc(attr queryAttr, op string, vals []float64) queryFunc {
+	return func(v interface{}) bool {
+		x, err := attr(v)
+		if err != nil {
+			return false
+		}
+		switch op {
+		case "<":
+			return x < vals[0]
+		case "<=":
+			return x <= vals[0]
+		case ">":
+			return x > vals[0]
+		case ">=":
+			return x >= vals[0]
+		case "=", "==":
+			for _, val := range vals {
+				if math.Abs(x-val) <= EPS {
+					return true
+				}
+			}
+			return false
+		case "!=":
+			for _, val := range vals {
+				if math.Abs(x-val) <= EPS {
+					return false
+				}
+			}
+			return true
+		}
+		return false
+	}
+}
+
+// comparison parses "length > 3", "sect 501,502", "sect = 501 502", "rate in 0.8..1.0" or "height=3..6".
+func (qp *queryParser) comparison(name string, attr queryAttr) (queryFunc, error) {
+	op := "="
+	t := qp.peek()
+	switch {
+	case t.kind == queryOp && isComparator(t.str):
+		op = t.str
+		qp.pos++
+	case t.kind == queryWord && t.str == "IN":
+		qp.pos++
+	}
+	min, err := qp.number()
+	if err != nil {
+		return nil, err
+	}
+	if qp.accept(queryOp, "..") {
+		if op != "=" && op != "==" {
+			return nil, errors.New(fmt.Sprintf("Query: %s %s range", name, op))
+		}
+		max, err := qp.number()
+		if err != nil {
+			return nil, err
+		}
+		return queryAnd(compareFunc(attr, ">=", []float64{min}), compareFunc(attr, "<=", []float64{max})), nil
+	}
+	vals := []float64{min}
+	for {
+		if qp.accept(queryOp, ",") {
+			val, err := qp.number()
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, val)
+		} else if qp.peek().kind == queryNum {
+			vals = append(vals, qp.next().val)
+		} else {
+			break
+		}
+	}
+	if len(vals) > 1 && op != "=" && op != "==" && op != "!=" {
+		return nil, errors.New(fmt.Sprintf("Query: %s %s list", name, op))
+	}
+	return compareFunc(attr, op, vals), nil
+}
+
+// between parses "3 <= length < 6".
+func (qp *queryParser) between() (queryFunc, error) {
+	reverse := map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<="}
+	min, _ := qp.number()
+	op1 := qp.next()
+	if _, ok := reverse[op1.str]; !ok || op1.kind != queryOp {
+		return nil, errors.New(fmt.Sprintf("Query: comparator expected, got %s", op1))
+	}
+	name, err := qp.word()
+	if err != nil {
+		return nil, err
+	}
+	attr, ok := qp.attrs[name]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Query: unknown attribute %s", name))
+	}
+	f := compareFunc(attr, reverse[op1.str], []float64{min})
+	op2 := qp.peek()
+	if _, ok := reverse[op2.str]; !ok || op2.kind != queryOp {
+		return f, nil
+	}
+	qp.pos++
+	max, err := qp.number()
+	if err != nil {
+		return nil, err
+	}
+	return queryAnd(f, compareFunc(attr, op2.str, []float64{max})), nil
+}
+
+func queryAxis(word string) (int, error) {
+	for i, val := range []string{"X", "Y", "Z"} {
+		if word == val {
+			return i, nil
+		}
+	}
+	return 0, errors.New(fmt.Sprintf("Query: unknown axis %s", word))
+}
+
+// queryPlane returns the axis normal to a plane given by two distinct axes such as XY or ZX.
+func queryPlane(word string) (int, error) {
+	if len(word) != 2 || word[0] == word[1] {
+		return -1, errors.New(fmt.Sprintf("Query: unknown plane %s", word))
+	}
+	axis := -1
+	for i, val := range []string{"X", "Y", "Z"} {
+		if !strings.Contains(word, val) {
+			if axis >= 0 {
+				return -1, errors.New(fmt.Sprintf("Query: unknown plane %s", word))
+			}
+			axis = i
+		}
+	}
+	return axis, nil
+}
+
+// nodeElems returns the elems connected to each node, built once for an ADJOIN query instead of SearchElem for every elem.
+func (stw *Window) nodeElems() map[*st.Node][]*st.Elem {
+	rtn := make(map[*st.Node][]*st.Elem)
+	for _, el := range stw.Frame.Elems {
+		for _, en := range el.Enod[:el.Enods] {
+			rtn[en] = append(rtn[en], el)
+		}
+	}
+	return rtn
+}
+
+func queryEtype(word string) (int, bool) {
+	for _, e := range []struct {
+		re    *regexp.Regexp
+		etype int
+	}{
+		{re_column, st.COLUMN},
+		{re_girder, st.GIRDER},
+		{re_brace, st.BRACE},
+		{re_wall, st.WALL},
+		{re_slab, st.SLAB},
+	} {
+		if e.re.FindString(word) == word {
+			return e.etype, true
+		}
+	}
+	return 0, false
+}
+
+// elemQueryAttrs returns the attributes of elems.
+// Stresses are those of the current period as ElemScalar. FLOOR is the floor of the lowest enod.
+func (stw *Window) elemQueryAttrs() map[string]queryAttr {
+	show := stw.Frame.Show
+	elem := func(f func(*st.Elem) (float64, error)) queryAttr {
+		return func(v interface{}) (float64, error) {
+			return f(v.(*st.Elem))
+		}
+	}
+	scalar := func(name string) queryAttr {
+		return elem(func(el *st.Elem) (float64, error) {
+			return ElemScalar(el, name, show)
+		})
+	}
+	mid := func(ind int) queryAttr {
+		return elem(func(el *st.Elem) (float64, error) {
+			return el.MidPoint()[ind], nil
+		})
+	}
+	rtn := map[string]queryAttr{
+		"NUM": elem(func(el *st.Elem) (float64, error) {
+			return float64(el.Num), nil
+		}),
+		"SECT": elem(func(el *st.Elem) (float64, error) {
+			return float64(el.Sect.Num), nil
+		}),
+		"OSECT": elem(func(el *st.Elem) (float64, error) {
+			if el.Etype != st.WBRACE && el.Etype != st.SBRACE {
+				return 0.0, errors.New("OSECT: not a brace of a wall or a slab")
+			}
+			return float64(el.OriginalSection().Num), nil
+		}),
+		"LENGTH": elem(func(el *st.Elem) (float64, error) {
+			if !el.IsLineElem() {
+				return 0.0, errors.New("LENGTH: not a line elem")
+			}
+			return el.Length(), nil
+		}),
+		"IX": elem(func(el *st.Elem) (float64, error) {
+			return el.Sect.Ix(0)
+		}),
+		"IY": elem(func(el *st.Elem) (float64, error) {
+			return el.Sect.Iy(0)
+		}),
+		"FLOOR": elem(func(el *st.Elem) (float64, error) {
+			z := math.Inf(1)
+			for _, n := range el.Enod[:el.Enods] {
+				z = math.Min(z, n.Coord[2])
+			}
+			l := level(stw.Frame.Ai.Boundary, z)
+			if l < 0 {
+				return 0.0, errors.New("FLOOR: out of the boundaries")
+			}
+			return float64(l), nil
+		}),
+		"X":      mid(0),
+		"Y":      mid(1),
+		"Z":      mid(2),
+		"HEIGHT": mid(2),
+	}
+	for _, name := range COLORSCALARS {
+		rtn[name] = scalar(name)
+	}
+	return rtn
+}
+
+// elemQueryPredicate parses the predicates of elems: etype names (ETYPE COLUMN or COLUMN), CURTAIN, ISGOHAN,
+// HIDDEN, LOCKED, SELSET name, PARALLEL axis, ORTHO axis, ONPLANE plane and ADJOIN factor.
+func (stw *Window) elemQueryPredicate(qp *queryParser, word string) (queryFunc, error) {
+	elem := func(f func(*st.Elem) bool) queryFunc {
+		return func(v interface{}) bool {
+			return f(v.(*st.Elem))
+		}
+	}
+	if word == "ETYPE" || word == "ET" {
+		w, err := qp.word()
+		if err != nil {
+			return nil, err
+		}
+		word = w
+		if _, ok := queryEtype(word); !ok {
+			return nil, errors.New(fmt.Sprintf("Query: unknown etype %s", word))
+		}
+	}
+	if etype, ok := queryEtype(word); ok {
+		return elem(func(el *st.Elem) bool {
+			return el.Etype == etype
+		}), nil
+	}
+	switch word {
+	case "CURTAIN":
+		return elem(func(el *st.Elem) bool {
+			return el.Sect.Num <= 900 && !el.Sect.HasArea(0) && !el.Sect.HasBrace()
+		}), nil
+	case "ISGOHAN":
+		return elem(func(el *st.Elem) bool {
+			return el.Sect.IsGohan(EPS)
+		}), nil
+	case "HIDDEN":
+		return elem(func(el *st.Elem) bool {
+			return el.IsHidden(stw.Frame.Show)
+		}), nil
+	case "LOCKED":
+		return elem(func(el *st.Elem) bool {
+			return el.Lock
+		}), nil
+	case "PARALLEL", "ORTHO", "ORTHOGONAL":
+		w, err := qp.word()
+		if err != nil {
+			return nil, err
+		}
+		ind, err := queryAxis(w)
+		if err != nil {
+			return nil, err
+		}
+		axis := [][]float64{st.XAXIS, st.YAXIS, st.ZAXIS}[ind]
+		if word == "PARALLEL" {
+			return elem(func(el *st.Elem) bool {
+				return el.IsParallel(axis, 1e-4)
+			}), nil
+		}
+		return elem(func(el *st.Elem) bool {
+			return el.IsOrthogonal(axis, 1e-4)
+		}), nil
+	case "ONPLANE", "ON":
+		w, err := qp.word()
+		if err != nil {
+			return nil, err
+		}
+		axis, err := queryPlane(w)
+		if err != nil {
+			return nil, err
+		}
+		return elem(func(el *st.Elem) bool {
+			return onPlane(el, axis)
+		}), nil
+	case "SELSET":
+		return stw.selsetQuery(qp, true)
+	case "ADJOIN", "ADJ":
+		f, err := qp.factor()
+		if err != nil {
+			return nil, err
+		}
+		var nodeelems map[*st.Node][]*st.Elem
+		matched := make(map[*st.Elem]bool)
+		return elem(func(el *st.Elem) bool {
+			if nodeelems == nil {
+				nodeelems = stw.nodeElems()
+			}
+			for _, en := range el.Enod[:el.Enods] {
+				for _, sel := range nodeelems[en] {
+					if sel == el {
+						continue
+					}
+					m, ok := matched[sel]
+					if !ok {
+						m = f(sel)
+						matched[sel] = m
+					}
+					if m {
+						return true
+					}
+				}
+			}
+			return false
+		}), nil
+	}
+	return nil, errors.New(fmt.Sprintf("Query: unknown word %s", word))
+}
+
+// nodeQueryAttrs returns the attributes of nodes. Displacements and reactions are those of the current period.
+func (stw *Window) nodeQueryAttrs() map[string]queryAttr {
+	show := stw.Frame.Show
+	node := func(f func(*st.Node) (float64, error)) queryAttr {
+		return func(v interface{}) (float64, error) {
+			return f(v.(*st.Node))
+		}
+	}
+	rtn := map[string]queryAttr{
+		"NUM": node(func(n *st.Node) (float64, error) {
+			return float64(n.Num), nil
+		}),
+		"WEIGHT": node(func(n *st.Node) (float64, error) {
+			return n.Weight[1], nil
+		}),
+		"PILE": node(func(n *st.Node) (float64, error) {
+			if n.Pile == nil {
+				return 0.0, errors.New("PILE: no pile")
+			}
+			return float64(n.Pile.Num), nil
+		}),
+		"FLOOR": node(func(n *st.Node) (float64, error) {
+			l := level(stw.Frame.Ai.Boundary, n.Coord[2])
+			if l < 0 {
+				return 0.0, errors.New("FLOOR: out of the boundaries")
+			}
+			return float64(l), nil
+		}),
+	}
+	for i, name := range []string{"X", "Y", "Z"} {
+		ind := i
+		rtn[name] = node(func(n *st.Node) (float64, error) {
+			return n.Coord[ind], nil
+		})
+	}
+	rtn["HEIGHT"] = rtn["Z"]
+	for i, name := range []string{"DX", "DY", "DZ", "TX", "TY", "TZ"} {
+		ind := i
+		rtn[name] = node(func(n *st.Node) (float64, error) {
+			return n.ReturnDisp(show.Period, ind), nil
+		})
+	}
+	for i, name := range []string{"RX", "RY", "RZ", "MX", "MY", "MZ"} {
+		ind := i
+		rtn[name] = node(func(n *st.Node) (float64, error) {
+			return n.ReturnReaction(show.Period, ind), nil
+		})
+	}
+	return rtn
+}
+
+// nodeQueryPredicate parses the predicates of nodes: CONFED, FREE, PINNED, FIXED, HIDDEN, LOCKED and SELSET name.
+func (stw *Window) nodeQueryPredicate(qp *queryParser, word string) (queryFunc, error) {
+	node := func(f func(*st.Node) bool) queryFunc {
+		return func(v interface{}) bool {
+			return f(v.(*st.Node))
+		}
+	}
+	switch {
+	case abbrev.For("CONF/ED", word):
+		return node(func(n *st.Node) bool {
+			for i := 0; i < 6; i++ {
+				if n.Conf[i] {
+					return true
+				}
+			}
+			return false
+		}), nil
+	case word == "FREE":
+		return node(func(n *st.Node) bool {
+			for i := 0; i < 6; i++ {
+				if n.Conf[i] {
+					return false
+				}
+			}
+			return true
+		}), nil
+	case abbrev.For("PIN/NED", word):
+		return node(func(n *st.Node) bool {
+			return n.IsPinned()
+		}), nil
+	case abbrev.For("FIX/ED", word):
+		return node(func(n *st.Node) bool {
+			return n.IsFixed()
+		}), nil
+	case word == "HIDDEN":
+		return node(func(n *st.Node) bool {
+			return n.IsHidden(stw.Frame.Show)
+		}), nil
+	case word == "LOCKED":
+		return node(func(n *st.Node) bool {
+			return n.Lock
+		}), nil
+	case word == "SELSET":
+		return stw.selsetQuery(qp, false)
+	}
+	return nil, errors.New(fmt.Sprintf("Query: unknown word %s", word))
+}
+
+func compileQuery(str string, attrs map[string]queryAttr, pred func(*queryParser, string) (queryFunc, error)) (string, queryFunc, error) {
+	op, expr := splitQuery(str)
+	tokens, err := lexQuery(expr)
+	if err != nil {
+		return "", nil, err
+	}
+	qp := &queryParser{
+		tokens: tokens,
+		attrs:  attrs,
+		pred:   pred,
+	}
+	f, err := qp.parse()
+	if err != nil {
+		return "", nil, err
+	}
+	return op, f, nil
+}
+
+// QueryElem returns the elems selected by query str, starting from current for "+=", "-=" and "&=".
+func (stw *Window) QueryElem(str string, current []*st.Elem) ([]*st.Elem, error) {
+	op, f, err := compileQuery(str, stw.elemQueryAttrs(), stw.elemQueryPredicate)
+	if err != nil {
+		return nil, err
+	}
+	rtn := make([]*st.Elem, 0)
+	selected := make(map[*st.Elem]bool)
+	for _, el := range current {
+		if el == nil || op == "" {
+			continue
+		}
+		switch op {
+		case "+=":
+			rtn = append(rtn, el)
+		case "-=":
+			if !f(el) {
+				rtn = append(rtn, el)
+			}
+		case "&=":
+			if f(el) {
+				rtn = append(rtn, el)
+			}
+		}
+		selected[el] = true
+	}
+	if op == "" || op == "+=" {
+		for _, el := range sortedelems(stw.Frame) {
+			if !selected[el] && f(el) {
+				rtn = append(rtn, el)
+			}
+		}
+	}
+	return rtn, nil
+}
+
+// QueryNode returns the nodes selected by query str, starting from current for "+=", "-=" and "&=".
+func (stw *Window) QueryNode(str string, current []*st.Node) ([]*st.Node, error) {
+	op, f, err := compileQuery(str, stw.nodeQueryAttrs(), stw.nodeQueryPredicate)
+	if err != nil {
+		return nil, err
+	}
+	rtn := make([]*st.Node, 0)
+	selected := make(map[*st.Node]bool)
+	for _, n := range current {
+		if n == nil || op == "" {
+			continue
+		}
+		switch op {
+		case "+=":
+			rtn = append(rtn, n)
+		case "-=":
+			if !f(n) {
+				rtn = append(rtn, n)
+			}
+		case "&=":
+			if f(n) {
+				rtn = append(rtn, n)
+			}
+		}
+		selected[n] = true
+	}
+	if op == "" || op == "+=" {
+		for _, n := range sortednodes(stw.Frame) {
+			if !selected[n] && f(n) {
+				rtn = append(rtn, n)
+			}
+		}
+	}
+	return rtn, nil
+}
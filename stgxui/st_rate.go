@@ -0,0 +1,309 @@
+package stgxui
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/google/gxui"
+	"github.com/yofu/st/stlib"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Bits of Show.SrcanRate in the order of st.SRCANS.
+const (
+	RATE_LONG = 1 << iota
+	RATE_SHORT
+	RATE_SHEAR
+	RATE_BENDING
+)
+
+// RATECASES are the cases of the section rate as SectionRateCalculation(otp, "L", "X", "X", "Y", "Y", -1.0, cond).
+var RATECASES = []struct {
+	Name   string
+	Period string
+	Add    string
+	Factor float64
+}{
+	{"L", "L", "", 0.0},
+	{"L+X", "S", "X", 1.0},
+	{"L-X", "S", "X", -1.0},
+	{"L+Y", "S", "Y", 1.0},
+	{"L-Y", "S", "Y", -1.0},
+}
+
+// RateCheck is a check of an elem end: Stress / Allow = Rate.
+// Check is "N", "QX", "QY", "MX", "MY" or "N+M" (N/Na+Mx/Max+My/May, or Mx/Max+My/May when Ma depends on N).
+type RateCheck struct {
+	Check  string
+	Case   string
+	End    int
+	Stress float64
+	Allow  float64
+	Rate   float64
+}
+
+func (rc *RateCheck) String() string {
+	if rc.Check == "N+M" {
+		return fmt.Sprintf("%-4s %-4s END %d %32s %8.3f", rc.Check, rc.Case, rc.End, "", rc.Rate)
+	}
+	return fmt.Sprintf("%-4s %-4s END %d %10.3f / %10.3f = %8.3f", rc.Check, rc.Case, rc.End, rc.Stress, rc.Allow, rc.Rate)
+}
+
+// RateBreakdown holds the checks of an elem. Max is the governing one.
+// Stored is the rate of st (RateMax, as :srcal and ECOLOR_RATE) which the checks should explain.
+type RateBreakdown struct {
+	Elem      *st.Elem
+	Checks    []*RateCheck
+	Max       *RateCheck
+	Stored    float64
+	StoredErr error
+}
+
+// rateTolerance is the difference between the recomputed and the stored rate reported as a mismatch.
+var rateTolerance = 1e-3
+
+// Mismatch reports whether the recomputed rate differs from the stored one.
+func (rb *RateBreakdown) Mismatch() bool {
+	return rb.StoredErr == nil && math.Abs(rb.Max.Rate-rb.Stored) > rateTolerance
+}
+
+func ratio(stress, allow float64) float64 {
+	if allow == 0.0 {
+		if stress == 0.0 {
+			return 0.0
+		}
+		return math.Inf(1)
+	}
+	return math.Abs(stress) / allow
+}
+
+// NewRateBreakdown recomputes the section rate of el with its allowable for the checks enabled by flags (Show.SrcanRate).
+// N is positive in compression as Elem.N. Short term cases add the stresses of X or Y to those of L.
+// When Ma of st already depends on N (RC and SRC columns) N/Na isn't added again to N+M.
+// The rate stored by st is kept in Stored to show any mismatch.
+func NewRateBreakdown(frame *st.Frame, el *st.Elem, flags uint, cond *st.Condition) (*RateBreakdown, error) {
+	if !el.IsLineElem() {
+		return nil, errors.New(fmt.Sprintf("NewRateBreakdown: ELEM %d is not a line elem", el.Num))
+	}
+	al, ok := frame.Allows[el.Sect.Num]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("NewRateBreakdown: SECT %d has no allowable", el.Sect.Num))
+	}
+	if flags&(RATE_LONG|RATE_SHORT) == 0 {
+		flags |= RATE_LONG | RATE_SHORT
+	}
+	if flags&(RATE_SHEAR|RATE_BENDING) == 0 {
+		flags |= RATE_SHEAR | RATE_BENDING
+	}
+	rb := &RateBreakdown{
+		Elem:   el,
+		Checks: make([]*RateCheck, 0),
+	}
+	rb.Stored, rb.StoredErr = el.RateMax(frame.Show)
+	withN := map[string]bool{
+		"L": !dependsOnN(al, "L", true),
+		"S": !dependsOnN(al, "S", true),
+	}
+	for _, rc := range RATECASES {
+		if rc.Period == "L" && flags&RATE_LONG == 0 {
+			continue
+		}
+		if rc.Period == "S" && flags&RATE_SHORT == 0 {
+			continue
+		}
+		if _, ok := el.Stress["L"]; !ok {
+			continue
+		}
+		if rc.Add != "" {
+			if _, ok := el.Stress[rc.Add]; !ok {
+				continue
+			}
+		}
+		c := *cond
+		c.Period = rc.Period
+		for end := 0; end < 2; end++ {
+			stress := make([]float64, 6)
+			for i := 0; i < 6; i++ {
+				stress[i] = el.ReturnStress("L", end, i)
+				if rc.Add != "" {
+					stress[i] += rc.Factor * el.ReturnStress(rc.Add, end, i)
+				}
+			}
+			stress[0] = el.N("L", 0)
+			if rc.Add != "" {
+				stress[0] += rc.Factor * el.N(rc.Add, 0)
+			}
+			add := func(check string, s, a float64) *RateCheck {
+				r := &RateCheck{check, rc.Name, end, s, a, ratio(s, a)}
+				rb.Checks = append(rb.Checks, r)
+				return r
+			}
+			if flags&RATE_SHEAR != 0 {
+				c.Strong = true
+				add("QX", stress[1], al.Qa(&c))
+				c.Strong = false
+				add("QY", stress[2], al.Qa(&c))
+			}
+			if flags&RATE_BENDING != 0 {
+				c.Compression = stress[0] >= 0.0
+				c.N = stress[0]
+				rn := add("N", stress[0], al.Na(&c))
+				c.Strong = true
+				c.Positive = stress[4] >= 0.0
+				rx := add("MX", stress[4], al.Ma(&c))
+				c.Strong = false
+				c.Positive = stress[5] >= 0.0
+				ry := add("MY", stress[5], al.Ma(&c))
+				nm := rx.Rate + ry.Rate
+				if withN[rc.Period] {
+					nm += rn.Rate
+				}
+				rb.Checks = append(rb.Checks, &RateCheck{"N+M", rc.Name, end, 0.0, 0.0, nm})
+			}
+		}
+	}
+	if len(rb.Checks) == 0 {
+		return nil, errors.New(fmt.Sprintf("NewRateBreakdown: ELEM %d has no stress", el.Num))
+	}
+	for _, r := range rb.Checks {
+		if r.Check == "N" || r.Check == "MX" || r.Check == "MY" {
+			continue
+		}
+		if rb.Max == nil || r.Rate > rb.Max.Rate {
+			rb.Max = r
+		}
+	}
+	if rb.Max == nil {
+		rb.Max = rb.Checks[0]
+	}
+	return rb, nil
+}
+
+func (rb *RateBreakdown) Rate() float64 {
+	return rb.Max.Rate
+}
+
+// String returns a line for the list of RateBreakdowns.
+func (rb *RateBreakdown) String() string {
+	rtn := fmt.Sprintf("ELEM %5d SECT %4d %-7s %8.3f %-4s %-4s END %d", rb.Elem.Num, rb.Elem.Sect.Num, st.ETYPES[rb.Elem.Etype], rb.Max.Rate, rb.Max.Check, rb.Max.Case, rb.Max.End)
+	if rb.Mismatch() {
+		rtn += fmt.Sprintf(" ! STORED %.3f", rb.Stored)
+	}
+	return rtn
+}
+
+// Report returns all the checks of the elem.
+func (rb *RateBreakdown) Report() string {
+	var otp bytes.Buffer
+	otp.WriteString(fmt.Sprintf("ELEM %d SECT %d %s\n", rb.Elem.Num, rb.Elem.Sect.Num, st.ETYPES[rb.Elem.Etype]))
+	for _, r := range rb.Checks {
+		if r == rb.Max {
+			otp.WriteString(fmt.Sprintf("%s *\n", r))
+		} else {
+			otp.WriteString(fmt.Sprintf("%s\n", r))
+		}
+	}
+	otp.WriteString(fmt.Sprintf("MAX: %.3f (%s %s END %d)", rb.Max.Rate, rb.Max.Check, rb.Max.Case, rb.Max.End))
+	switch {
+	case rb.StoredErr != nil:
+		otp.WriteString(fmt.Sprintf("\nSTORED: %s", rb.StoredErr.Error()))
+	case rb.Mismatch():
+		otp.WriteString(fmt.Sprintf("\nSTORED: %.3f ! MISMATCH: the breakdown doesn't reproduce the rate of st (:srcal)", rb.Stored))
+	default:
+		otp.WriteString(fmt.Sprintf("\nSTORED: %.3f", rb.Stored))
+	}
+	return otp.String()
+}
+
+type rateBreakdowns struct {
+	rbs  []*RateBreakdown
+	less func(a, b *RateBreakdown) bool
+}
+
+func (r rateBreakdowns) Len() int           { return len(r.rbs) }
+func (r rateBreakdowns) Swap(i, j int)      { r.rbs[i], r.rbs[j] = r.rbs[j], r.rbs[i] }
+func (r rateBreakdowns) Less(i, j int) bool { return r.less(r.rbs[i], r.rbs[j]) }
+
+// WorstRates returns the breakdowns of the n elems with the highest rates (all with n <= 0).
+func WorstRates(frame *st.Frame, els []*st.Elem, n int, flags uint, cond *st.Condition) []*RateBreakdown {
+	rtn := make([]*RateBreakdown, 0)
+	for _, el := range els {
+		rb, err := NewRateBreakdown(frame, el, flags, cond)
+		if err != nil {
+			continue
+		}
+		rtn = append(rtn, rb)
+	}
+	sort.Stable(rateBreakdowns{rtn, func(a, b *RateBreakdown) bool {
+		return a.Rate() > b.Rate()
+	}})
+	if n > 0 && len(rtn) > n {
+		rtn = rtn[:n]
+	}
+	return rtn
+}
+
+// ShowRates opens a window listing rbs. Clicking a row selects the elem and shows its checks.
+func (stw *Window) ShowRates(rbs []*RateBreakdown) {
+	theme := stw.theme
+	frame := stw.Frame
+	adapter := gxui.CreateDefaultAdapter()
+	adapter.SetItemSizeAsLargest(theme)
+	adapter.SetItems(rbs)
+	list := theme.CreateList()
+	list.SetAdapter(adapter)
+	report := theme.CreateTextBox()
+	report.SetMultiline(true)
+	report.SetDesiredWidth(600)
+	list.OnSelectionChanged(func(item gxui.AdapterItem) {
+		rb, ok := item.(*RateBreakdown)
+		if !ok {
+			return
+		}
+		report.SetText(rb.Report())
+		if stw.Frame != frame {
+			return
+		}
+		stw.Deselect()
+		stw.SelectElem = []*st.Elem{rb.Elem}
+		coord := rb.Elem.MidPoint()
+		view := stw.Frame.View.Copy()
+		view.Focus = []float64{coord[0], coord[1], coord[2]}
+		view.Center[0] = float64(stw.CanvasSize[0]) * 0.5
+		view.Center[1] = float64(stw.CanvasSize[1]) * 0.5
+		stw.Animate(view)
+	})
+	buttons := theme.CreateLinearLayout()
+	buttons.SetDirection(gxui.LeftToRight)
+	for _, s := range []struct {
+		name string
+		less func(a, b *RateBreakdown) bool
+	}{
+		{"RATE", func(a, b *RateBreakdown) bool { return a.Rate() > b.Rate() }},
+		{"ELEM", func(a, b *RateBreakdown) bool { return a.Elem.Num < b.Elem.Num }},
+		{"SECT", func(a, b *RateBreakdown) bool { return a.Elem.Sect.Num < b.Elem.Sect.Num }},
+		{"CHECK", func(a, b *RateBreakdown) bool { return strings.Compare(a.Max.Check, b.Max.Check) < 0 }},
+		{"CASE", func(a, b *RateBreakdown) bool { return strings.Compare(a.Max.Case, b.Max.Case) < 0 }},
+	} {
+		less := s.less
+		button := theme.CreateButton()
+		button.SetText(s.name)
+		button.OnClick(func(gxui.MouseEvent) {
+			sort.Stable(rateBreakdowns{rbs, less})
+			adapter.SetItems(rbs)
+		})
+		buttons.AddChild(button)
+	}
+	layout := theme.CreateLinearLayout()
+	layout.SetDirection(gxui.TopToBottom)
+	layout.AddChild(buttons)
+	layout.AddChild(list)
+	layout.AddChild(report)
+	w := theme.CreateWindow(700, 700, fmt.Sprintf("RATE: %d elems", len(rbs)))
+	w.AddChild(layout)
+	w.OnClose(func() {
+		stw.dlg.SetFocus(stw.cline)
+	})
+}
@@ -0,0 +1,394 @@
+package stgxui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/yofu/st/stlib"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Scripts (.strc and :source) run a line at a time like the command line, with blocks:
+//
+//	:for name in floors|sects|periods|1..5|word...
+//	:endfor
+//	:if condition
+//	:else
+//	:endif
+//
+// $name or ${name} is replaced with a variable set by :let or :for.
+const (
+	SCRIPT_COMMAND = iota
+	SCRIPT_FOR
+	SCRIPT_IF
+)
+
+const maxScriptDepth = 16
+
+var (
+	re_variable     = regexp.MustCompile("[$]{([a-zA-Z_][a-zA-Z0-9_]*)}|[$]([a-zA-Z_][a-zA-Z0-9_]*)")
+	re_variablename = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+	re_scriptfor    = regexp.MustCompile("^ *:for +([a-zA-Z_][a-zA-Z0-9_]*) +in +(.+)$")
+	re_scriptif     = regexp.MustCompile("^ *:if +(.+)$")
+	re_scriptrange  = regexp.MustCompile("^(-?[0-9]+)[.][.](-?[0-9]+)$")
+	re_condition    = regexp.MustCompile("^(.*?) *(==|!=|<=|>=|<|>) *(.*)$")
+)
+
+type scriptNode struct {
+	kind   int
+	text   string
+	line   int
+	body   []*scriptNode
+	orelse []*scriptNode
+}
+
+func scriptKeyword(txt string) string {
+	fs := strings.Fields(txt)
+	if len(fs) == 0 {
+		return ""
+	}
+	return strings.ToLower(fs[0])
+}
+
+// parseScript builds the blocks of lines. Empty lines and lines beginning with "#" are skipped.
+func parseScript(lines []string) ([]*scriptNode, error) {
+	type frame struct {
+		node   *scriptNode
+		inelse bool
+	}
+	root := &scriptNode{}
+	stack := []*frame{&frame{node: root}}
+	add := func(n *scriptNode) {
+		f := stack[len(stack)-1]
+		if f.inelse {
+			f.node.orelse = append(f.node.orelse, n)
+		} else {
+			f.node.body = append(f.node.body, n)
+		}
+	}
+	for i, txt := range lines {
+		txt = strings.TrimSpace(txt)
+		if txt == "" || strings.HasPrefix(txt, "#") {
+			continue
+		}
+		switch scriptKeyword(txt) {
+		default:
+			add(&scriptNode{kind: SCRIPT_COMMAND, text: txt, line: i + 1})
+		case ":for":
+			if !re_scriptfor.MatchString(txt) {
+				return nil, errors.New(fmt.Sprintf("line %d: :for name in items", i+1))
+			}
+			n := &scriptNode{kind: SCRIPT_FOR, text: txt, line: i + 1}
+			add(n)
+			stack = append(stack, &frame{node: n})
+		case ":if":
+			if !re_scriptif.MatchString(txt) {
+				return nil, errors.New(fmt.Sprintf("line %d: :if condition", i+1))
+			}
+			n := &scriptNode{kind: SCRIPT_IF, text: txt, line: i + 1}
+			add(n)
+			stack = append(stack, &frame{node: n})
+		case ":else":
+			f := stack[len(stack)-1]
+			if f.node.kind != SCRIPT_IF || f.inelse {
+				return nil, errors.New(fmt.Sprintf("line %d: :else without :if", i+1))
+			}
+			f.inelse = true
+		case ":endif", ":endfor":
+			f := stack[len(stack)-1]
+			kind := SCRIPT_IF
+			if scriptKeyword(txt) == ":endfor" {
+				kind = SCRIPT_FOR
+			}
+			if len(stack) == 1 || f.node.kind != kind {
+				return nil, errors.New(fmt.Sprintf("line %d: unexpected %s", i+1, txt))
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(stack) > 1 {
+		n := stack[len(stack)-1].node
+		return nil, errors.New(fmt.Sprintf("line %d: %s is not closed", n.line, n.text))
+	}
+	return root.body, nil
+}
+
+// ExpandVariables replaces $name and ${name} with the variables. Undefined names are left for CompleteFileName ($ENV).
+func (stw *Window) ExpandVariables(str string) string {
+	if len(stw.variables) == 0 || !strings.Contains(str, "$") {
+		return str
+	}
+	return re_variable.ReplaceAllStringFunc(str, func(s string) string {
+		fs := re_variable.FindStringSubmatch(s)
+		name := fs[1]
+		if name == "" {
+			name = fs[2]
+		}
+		if val, ok := stw.variables[name]; ok {
+			return val
+		}
+		return s
+	})
+}
+
+// SetVariable sets value to name. value beginning with ":" is run as an ex command and its Count is set,
+// or its message if it has no count. When the command pipes nodes or elems, name_nodes and name_elems
+// are set to their numbers too.
+func (stw *Window) SetVariable(name, value string) error {
+	if !re_variablename.MatchString(name) {
+		return errors.New(fmt.Sprintf("SetVariable: invalid name %s", name))
+	}
+	if strings.HasPrefix(value, ":") {
+		out, err := stw.CommandResult(value)
+		if err != nil {
+			return err
+		}
+		if val, ok := out.Count(); ok {
+			value = strconv.FormatFloat(val, 'f', -1, 64)
+		} else {
+			value = out.Message
+		}
+		if out.Value != nil {
+			stw.variables[fmt.Sprintf("%s_nodes", name)] = fmt.Sprintf("%d", len(out.Value.Nodes))
+			stw.variables[fmt.Sprintf("%s_elems", name)] = fmt.Sprintf("%d", len(out.Value.Elems))
+		}
+	}
+	stw.variables[name] = value
+	return nil
+}
+
+func (stw *Window) VariablesString() string {
+	names := make([]string, 0, len(stw.variables))
+	for name := range stw.variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = fmt.Sprintf("%s = %s", name, stw.variables[name])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CommandOutput is the result of an ex command for :let and :if: the message of its last stage and the value it piped.
+type CommandOutput struct {
+	Message string
+	Value   *PipeValue
+}
+
+// Count returns the message if it is a number, or else the number of nodes, elems and sects piped
+// (:elem column | :count gives the number of the columns). It is false when the command gives neither.
+func (co *CommandOutput) Count() (float64, bool) {
+	if val, err := strconv.ParseFloat(strings.TrimSpace(co.Message), 64); err == nil {
+		return val, true
+	}
+	if co.Value != nil {
+		return float64(len(co.Value.Nodes) + len(co.Value.Elems) + len(co.Value.Sects)), true
+	}
+	return 0.0, false
+}
+
+// CommandResult runs an ex command (pipes allowed). Every stage is run as piped so that the value of the last one is kept.
+func (stw *Window) CommandResult(command string) (*CommandOutput, error) {
+	out := new(CommandOutput)
+	excms := strings.Split(command, "|")
+	defer func() {
+		stw.pipe = nil
+	}()
+	for _, com := range excms {
+		err := stw.excommand(com, true)
+		if err != nil {
+			if u, ok := err.(st.Messager); ok {
+				out.Message = u.Message()
+			} else {
+				return nil, err
+			}
+		}
+	}
+	out.Value = stw.pipe
+	return out, nil
+}
+
+// scriptItems returns the items of :for: floors (indices of Ai boundaries as FLOOR of :elem), sects, periods,
+// an integer range a..b or the words themselves.
+func (stw *Window) scriptItems(words []string) ([]string, error) {
+	if len(words) == 1 {
+		switch strings.ToLower(words[0]) {
+		case "floors":
+			if stw.Frame == nil {
+				return nil, errors.New("floors: no frame")
+			}
+			rtn := make([]string, 0)
+			for i := 0; i < len(stw.Frame.Ai.Boundary)-1; i++ {
+				rtn = append(rtn, fmt.Sprintf("%d", i))
+			}
+			return rtn, nil
+		case "sects":
+			if stw.Frame == nil {
+				return nil, errors.New("sects: no frame")
+			}
+			snums := make([]int, 0, len(stw.Frame.Sects))
+			for snum := range stw.Frame.Sects {
+				snums = append(snums, snum)
+			}
+			sort.Ints(snums)
+			rtn := make([]string, len(snums))
+			for i, snum := range snums {
+				rtn[i] = fmt.Sprintf("%d", snum)
+			}
+			return rtn, nil
+		case "periods":
+			return stw.PeriodCandidates(""), nil
+		}
+		if fs := re_scriptrange.FindStringSubmatch(words[0]); fs != nil {
+			start, _ := strconv.ParseInt(fs[1], 10, 64)
+			end, _ := strconv.ParseInt(fs[2], 10, 64)
+			rtn := make([]string, 0)
+			for i := start; i <= end; i++ {
+				rtn = append(rtn, fmt.Sprintf("%d", i))
+			}
+			return rtn, nil
+		}
+	}
+	return words, nil
+}
+
+func truthy(str string) bool {
+	switch strings.ToLower(strings.TrimSpace(str)) {
+	case "", "0", "false", "off", "no":
+		return false
+	}
+	return true
+}
+
+// EvalCondition evaluates the condition of :if:
+// ":command" (true if it succeeds with a nonzero Count, e.g. ":elem column and rate > 1" or ":check" finding something;
+// a command without a count is true if it succeeds), "exists path", "not condition", "a op b" with op ==, !=, <, <=, > or >=
+// (numerically if both are numbers) or a single value (false if empty, 0, false, off or no).
+func (stw *Window) EvalCondition(cond string) (bool, error) {
+	cond = strings.TrimSpace(cond)
+	fs := strings.Fields(cond)
+	switch {
+	case strings.HasPrefix(cond, ":"):
+		out, err := stw.CommandResult(cond)
+		if err != nil {
+			return false, nil
+		}
+		if val, ok := out.Count(); ok {
+			return val != 0.0, nil
+		}
+		return true, nil
+	case len(fs) > 1 && strings.EqualFold(fs[0], "not"):
+		val, err := stw.EvalCondition(strings.TrimSpace(cond[len(fs[0]):]))
+		return !val, err
+	case len(fs) == 2 && strings.EqualFold(fs[0], "exists"):
+		return st.FileExists(stw.CompleteFileName(fs[1])), nil
+	case re_condition.MatchString(cond):
+		cs := re_condition.FindStringSubmatch(cond)
+		a, b := strings.TrimSpace(cs[1]), strings.TrimSpace(cs[3])
+		v1, err1 := strconv.ParseFloat(a, 64)
+		v2, err2 := strconv.ParseFloat(b, 64)
+		if err1 == nil && err2 == nil {
+			switch cs[2] {
+			case "==":
+				return v1 == v2, nil
+			case "!=":
+				return v1 != v2, nil
+			case "<":
+				return v1 < v2, nil
+			case "<=":
+				return v1 <= v2, nil
+			case ">":
+				return v1 > v2, nil
+			case ">=":
+				return v1 >= v2, nil
+			}
+		}
+		switch cs[2] {
+		case "==":
+			return a == b, nil
+		case "!=":
+			return a != b, nil
+		}
+		return false, errors.New(fmt.Sprintf("EvalCondition: %s is not numeric", cond))
+	}
+	return truthy(cond), nil
+}
+
+func (stw *Window) execScript(nodes []*scriptNode) error {
+	for _, n := range nodes {
+		switch n.kind {
+		case SCRIPT_COMMAND:
+			stw.execAliasCommand(n.text)
+		case SCRIPT_FOR:
+			fs := re_scriptfor.FindStringSubmatch(n.text)
+			items, err := stw.scriptItems(strings.Fields(stw.ExpandVariables(fs[2])))
+			if err != nil {
+				return errors.New(fmt.Sprintf("line %d: %s", n.line, err.Error()))
+			}
+			for _, item := range items {
+				stw.variables[fs[1]] = item
+				err := stw.execScript(n.body)
+				if err != nil {
+					return err
+				}
+			}
+		case SCRIPT_IF:
+			fs := re_scriptif.FindStringSubmatch(n.text)
+			val, err := stw.EvalCondition(stw.ExpandVariables(fs[1]))
+			if err != nil {
+				return errors.New(fmt.Sprintf("line %d: %s", n.line, err.Error()))
+			}
+			if val {
+				err = stw.execScript(n.body)
+			} else {
+				err = stw.execScript(n.orelse)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RunScript runs lines of a script. Errors of commands are reported as on the command line and don't stop it.
+func (stw *Window) RunScript(lines []string) error {
+	if stw.scriptdepth >= maxScriptDepth {
+		return errors.New("RunScript: too deeply nested")
+	}
+	nodes, err := parseScript(lines)
+	if err != nil {
+		return err
+	}
+	stw.scriptdepth++
+	defer func() {
+		stw.scriptdepth--
+	}()
+	return stw.execScript(nodes)
+}
+
+func (stw *Window) SourceFile(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	lines := make([]string, 0)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	err = stw.RunScript(lines)
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s: %s", filename, err.Error()))
+	}
+	return nil
+}
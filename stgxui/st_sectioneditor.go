@@ -0,0 +1,294 @@
+package stgxui
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/gxui"
+	"github.com/yofu/st/stlib"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type sectItem struct {
+	sect *st.Sect
+}
+
+func (si *sectItem) String() string {
+	return fmt.Sprintf("%5d %s", si.sect.Num, si.sect.Name)
+}
+
+// SectionEditor lists the sects of the frame in a window.
+// Sects can be created or cloned, given a shape of SteelCatalogue, a prop and a color.
+type SectionEditor struct {
+	stw      *Window
+	frame    *st.Frame
+	current  *st.Sect
+	shape    st.Shape
+	adapter  *gxui.DefaultAdapter
+	list     gxui.List
+	catalog  *gxui.DefaultAdapter
+	boxes    map[string]gxui.TextBox
+	property gxui.TextBox
+	window   gxui.Window
+}
+
+func (se *SectionEditor) items() []*sectItem {
+	nums := make([]int, 0, len(se.frame.Sects))
+	for snum := range se.frame.Sects {
+		nums = append(nums, snum)
+	}
+	sort.Ints(nums)
+	rtn := make([]*sectItem, len(nums))
+	for i, snum := range nums {
+		rtn[i] = &sectItem{se.frame.Sects[snum]}
+	}
+	return rtn
+}
+
+// Update refreshes the list of sects and the fields of the current sect.
+func (se *SectionEditor) Update() {
+	items := se.items()
+	se.adapter.SetItems(items)
+	if se.current == nil {
+		return
+	}
+	for _, item := range items {
+		if item.sect == se.current {
+			se.list.Select(item)
+			se.list.ScrollToItem(item)
+			break
+		}
+	}
+	se.boxes["NUM"].SetText(fmt.Sprintf("%d", se.current.Num))
+	se.boxes["NAME"].SetText(se.current.Name)
+	if len(se.current.Figs) > 0 && se.current.Figs[0].Prop != nil {
+		se.boxes["PROP"].SetText(fmt.Sprintf("%d", se.current.Figs[0].Prop.Num))
+	} else {
+		se.boxes["PROP"].SetText("")
+	}
+	se.boxes["COLOR"].SetText(fmt.Sprintf("#%06X", se.current.Color))
+	se.property.SetText(se.current.InpString())
+}
+
+// sync follows the frame of the window, which Undo and Redo replace with a snapshot.
+func (se *SectionEditor) sync() error {
+	if se.stw.Frame == se.frame {
+		return nil
+	}
+	if se.stw.Frame == nil || se.stw.Frame.Path != se.frame.Path {
+		return errors.New("SectionEditor: another frame is opened")
+	}
+	se.frame = se.stw.Frame
+	if se.current != nil {
+		se.current = se.frame.Sects[se.current.Num]
+	}
+	se.Update()
+	return nil
+}
+
+func (se *SectionEditor) num() (int, error) {
+	tmp, err := strconv.ParseInt(strings.TrimSpace(se.boxes["NUM"].Text()), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int(tmp), nil
+}
+
+// New adds an empty sect numbered NUM.
+func (se *SectionEditor) New() error {
+	if err := se.sync(); err != nil {
+		return err
+	}
+	snum, err := se.num()
+	if err != nil {
+		return err
+	}
+	if _, ok := se.frame.Sects[snum]; ok {
+		return errors.New(fmt.Sprintf("New: SECT %d already exists", snum))
+	}
+	se.current = se.frame.AddSect(snum)
+	se.frame.Show.Sect[snum] = true
+	se.stw.Snapshot()
+	se.Update()
+	return nil
+}
+
+// Clone copies the current sect as NUM.
+func (se *SectionEditor) Clone() error {
+	if err := se.sync(); err != nil {
+		return err
+	}
+	if se.current == nil {
+		return errors.New("Clone: no sect selected")
+	}
+	snum, err := se.num()
+	if err != nil {
+		return err
+	}
+	if _, ok := se.frame.Sects[snum]; ok {
+		return errors.New(fmt.Sprintf("Clone: SECT %d already exists", snum))
+	}
+	as := se.current.Snapshot(se.frame)
+	as.Num = snum
+	se.frame.Sects[snum] = as
+	se.frame.Show.Sect[snum] = true
+	se.current = as
+	se.stw.Snapshot()
+	se.Update()
+	return nil
+}
+
+// Apply sets NAME, PROP and COLOR to the current sect.
+func (se *SectionEditor) Apply() error {
+	if err := se.sync(); err != nil {
+		return err
+	}
+	if se.current == nil {
+		return errors.New("Apply: no sect selected")
+	}
+	if p := strings.TrimSpace(se.boxes["PROP"].Text()); p != "" {
+		tmp, err := strconv.ParseInt(p, 10, 64)
+		if err != nil {
+			return err
+		}
+		prop, ok := se.frame.Props[int(tmp)]
+		if !ok {
+			return errors.New(fmt.Sprintf("Apply: PROP %d doesn't exist", tmp))
+		}
+		for _, f := range se.current.Figs {
+			f.Prop = prop
+		}
+	}
+	if c := strings.TrimSpace(se.boxes["COLOR"].Text()); c != "" {
+		tmp, err := strconv.ParseInt(strings.Replace(c, "#", "0x", 1), 0, 64)
+		if err != nil {
+			return err
+		}
+		se.current.Color = int(tmp)
+	}
+	se.current.Name = se.boxes["NAME"].Text()
+	se.stw.Snapshot()
+	se.Update()
+	se.stw.Redraw()
+	return nil
+}
+
+// SetShape sets the picked shape to the current sect like ":section n <-".
+func (se *SectionEditor) SetShape() error {
+	if err := se.sync(); err != nil {
+		return err
+	}
+	if se.current == nil {
+		return errors.New("SetShape: no sect selected")
+	}
+	if se.shape == nil {
+		return errors.New("SetShape: no shape picked")
+	}
+	SetSectShape(se.frame, se.current, se.shape)
+	se.stw.Snapshot()
+	se.Update()
+	return nil
+}
+
+func (se *SectionEditor) pick(cs *CatalogueShape) {
+	sh, err := cs.Shape()
+	if err != nil {
+		se.stw.ErrorMessage(err, ERROR)
+		return
+	}
+	se.shape = sh
+	se.property.SetText(ShapeDataString(sh))
+	se.stw.ShapeData(sh)
+	se.stw.Redraw()
+}
+
+// ShowSectionEditor opens the section editor of the current frame.
+func (stw *Window) ShowSectionEditor() {
+	theme := stw.theme
+	se := &SectionEditor{
+		stw:   stw,
+		frame: stw.Frame,
+		boxes: make(map[string]gxui.TextBox),
+	}
+	se.adapter = gxui.CreateDefaultAdapter()
+	se.adapter.SetItemSizeAsLargest(theme)
+	se.list = theme.CreateList()
+	se.list.SetAdapter(se.adapter)
+	se.list.OnSelectionChanged(func(item gxui.AdapterItem) {
+		if si, ok := item.(*sectItem); ok && si.sect != se.current {
+			se.current = si.sect
+			se.Update()
+			stw.SectionData(si.sect)
+			stw.Redraw()
+		}
+	})
+	fields := theme.CreateLinearLayout()
+	fields.SetDirection(gxui.TopToBottom)
+	for _, key := range []string{"NUM", "NAME", "PROP", "COLOR"} {
+		row := theme.CreateLinearLayout()
+		row.SetDirection(gxui.LeftToRight)
+		label := theme.CreateLabel()
+		label.SetText(fmt.Sprintf("%-6s", key))
+		box := theme.CreateTextBox()
+		box.SetDesiredWidth(250)
+		row.AddChild(label)
+		row.AddChild(box)
+		fields.AddChild(row)
+		se.boxes[key] = box
+	}
+	buttons := theme.CreateLinearLayout()
+	buttons.SetDirection(gxui.LeftToRight)
+	for _, b := range []struct {
+		text string
+		f    func() error
+	}{
+		{"New", se.New},
+		{"Clone", se.Clone},
+		{"Apply", se.Apply},
+		{"Set Shape", se.SetShape},
+	} {
+		f := b.f
+		button := theme.CreateButton()
+		button.SetText(b.text)
+		button.OnClick(func(gxui.MouseEvent) {
+			err := f()
+			if err != nil {
+				stw.ErrorMessage(err, ERROR)
+			}
+		})
+		buttons.AddChild(button)
+	}
+	fields.AddChild(buttons)
+	se.catalog = gxui.CreateDefaultAdapter()
+	se.catalog.SetItemSizeAsLargest(theme)
+	se.catalog.SetItems(SteelCatalogue)
+	catalog := theme.CreateList()
+	catalog.SetAdapter(se.catalog)
+	catalog.OnSelectionChanged(func(item gxui.AdapterItem) {
+		if cs, ok := item.(*CatalogueShape); ok {
+			se.pick(cs)
+		}
+	})
+	search := theme.CreateTextBox()
+	search.SetDesiredWidth(250)
+	search.OnTextChanged(func([]gxui.TextBoxEdit) {
+		se.catalog.SetItems(SearchCatalogue("", search.Text()))
+	})
+	fields.AddChild(search)
+	fields.AddChild(catalog)
+	se.property = theme.CreateTextBox()
+	se.property.SetMultiline(true)
+	se.property.SetDesiredWidth(400)
+	layout := theme.CreateLinearLayout()
+	layout.SetDirection(gxui.LeftToRight)
+	layout.AddChild(se.list)
+	layout.AddChild(fields)
+	layout.AddChild(se.property)
+	se.window = theme.CreateWindow(1000, 600, fmt.Sprintf("SECTION: %s", se.frame.Name))
+	se.window.AddChild(layout)
+	se.window.OnClose(func() {
+		stw.dlg.SetFocus(stw.cline)
+	})
+	se.Update()
+}
@@ -0,0 +1,194 @@
+package stgxui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/yofu/st/stlib"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const SelSetExt = ".selset"
+
+var re_selsetname = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+
+// SelSet is a named selection of node and elem numbers.
+type SelSet struct {
+	Nodes []int
+	Elems []int
+}
+
+func (stw *Window) SelSetFileName() string {
+	if stw.Frame == nil {
+		return ""
+	}
+	return st.Ce(stw.Frame.Path, SelSetExt)
+}
+
+// ReadSelSets returns the selection sets saved alongside the frame. It returns an empty map if there is no file.
+func (stw *Window) ReadSelSets() (map[string]*SelSet, error) {
+	if stw.Frame == nil {
+		return nil, errors.New("ReadSelSets: no frame")
+	}
+	rtn := make(map[string]*SelSet)
+	fn := stw.SelSetFileName()
+	if !st.FileExists(fn) {
+		return rtn, nil
+	}
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &rtn)
+	if err != nil {
+		return nil, err
+	}
+	return rtn, nil
+}
+
+func (stw *Window) WriteSelSets(sets map[string]*SelSet) error {
+	data, err := json.MarshalIndent(sets, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(stw.SelSetFileName(), data, 0644)
+}
+
+// SelSetNames returns the names of the saved selection sets.
+func (stw *Window) SelSetNames() ([]string, error) {
+	sets, err := stw.ReadSelSets()
+	if err != nil {
+		return nil, err
+	}
+	rtn := make([]string, 0, len(sets))
+	for name := range sets {
+		rtn = append(rtn, name)
+	}
+	sort.Strings(rtn)
+	return rtn, nil
+}
+
+// SelSet returns the selection set called name. Names are case-insensitive because queries are.
+func (stw *Window) SelSet(name string) (*SelSet, error) {
+	sets, err := stw.ReadSelSets()
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := sets[name]; ok {
+		return s, nil
+	}
+	for key, s := range sets {
+		if strings.EqualFold(key, name) {
+			return s, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("selset %s doesn't exist", name))
+}
+
+// SaveSelSet stores the selected nodes and elems as name.
+func (stw *Window) SaveSelSet(name string, overwrite bool) error {
+	if !re_selsetname.MatchString(name) {
+		return errors.New(fmt.Sprintf("SaveSelSet: invalid selset name %s", name))
+	}
+	sets, err := stw.ReadSelSets()
+	if err != nil {
+		return err
+	}
+	for key := range sets {
+		if strings.EqualFold(key, name) {
+			if !overwrite {
+				return errors.New(fmt.Sprintf("selset %s already exists", key))
+			}
+			delete(sets, key)
+		}
+	}
+	s := &SelSet{
+		Nodes: make([]int, 0),
+		Elems: make([]int, 0),
+	}
+	for _, n := range stw.SelectNode {
+		if n != nil {
+			s.Nodes = append(s.Nodes, n.Num)
+		}
+	}
+	for _, el := range stw.SelectElem {
+		if el != nil {
+			s.Elems = append(s.Elems, el.Num)
+		}
+	}
+	sort.Ints(s.Nodes)
+	sort.Ints(s.Elems)
+	sets[name] = s
+	return stw.WriteSelSets(sets)
+}
+
+func (stw *Window) DeleteSelSet(name string) error {
+	sets, err := stw.ReadSelSets()
+	if err != nil {
+		return err
+	}
+	found := false
+	for key := range sets {
+		if strings.EqualFold(key, name) {
+			delete(sets, key)
+			found = true
+		}
+	}
+	if !found {
+		return errors.New(fmt.Sprintf("selset %s doesn't exist", name))
+	}
+	return stw.WriteSelSets(sets)
+}
+
+// LoadSelSet selects the nodes and elems of name which still exist in the frame.
+func (stw *Window) LoadSelSet(name string) error {
+	s, err := stw.SelSet(name)
+	if err != nil {
+		return err
+	}
+	stw.Deselect()
+	for _, nnum := range s.Nodes {
+		if n, ok := stw.Frame.Nodes[nnum]; ok {
+			stw.SelectNode = append(stw.SelectNode, n)
+		}
+	}
+	for _, enum := range s.Elems {
+		if el, ok := stw.Frame.Elems[enum]; ok {
+			stw.SelectElem = append(stw.SelectElem, el)
+		}
+	}
+	return nil
+}
+
+// selsetQuery parses "SELSET NAME" or "SELSET=NAME" of a query. The set is read once when the query is compiled.
+func (stw *Window) selsetQuery(qp *queryParser, elem bool) (queryFunc, error) {
+	if !qp.accept(queryOp, "=") {
+		qp.accept(queryOp, "==")
+	}
+	name, err := qp.word()
+	if err != nil {
+		return nil, err
+	}
+	s, err := stw.SelSet(name)
+	if err != nil {
+		return nil, err
+	}
+	nums := make(map[int]bool)
+	if elem {
+		for _, enum := range s.Elems {
+			nums[enum] = true
+		}
+		return func(v interface{}) bool {
+			return nums[v.(*st.Elem).Num]
+		}, nil
+	}
+	for _, nnum := range s.Nodes {
+		nums[nnum] = true
+	}
+	return func(v interface{}) bool {
+		return nums[v.(*st.Node).Num]
+	}, nil
+}
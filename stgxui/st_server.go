@@ -0,0 +1,534 @@
+package stgxui
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/yofu/st/stlib"
+	"mime"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server is the local control API of :serve for external tools.
+//
+//	POST /rpc        JSON-RPC 2.0: {"jsonrpc": "2.0", "method": "command", "params": {"command": ":count"}, "id": 1}
+//	POST /command    {"command": ":count"}: an ex (":"), fig2 ("'") or alias command
+//	GET  /selection  selected node and elem numbers
+//	GET  /nodes      ?num=1,2,3&period=L (all nodes without num)
+//	GET  /elems      ?num=101,102&period=L
+//	GET  /frame      name, path, periods and counts
+//	POST /redraw
+//
+// Methods of /rpc are command, selection, select, query, nodes, elems, frame and redraw.
+// Every request is run with Call, which is driver.CallSync on the UI thread.
+// Without a driver (a headless Window) it is run directly, one request at a time.
+//
+// Listening on loopback doesn't keep out web pages in the user's browser, so every request must have
+// the token shown by :serve in the X-St-Token header, a loopback Host and no Origin header,
+// and POST bodies must be application/json, which a page can't send without a preflight.
+type Server struct {
+	sync.Mutex
+	stw      *Window
+	Addr     string
+	Token    string
+	Call     func(func())
+	listener net.Listener
+}
+
+const ServerTokenHeader = "X-St-Token"
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      interface{}     `json:"id"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   *rpcError   `json:"error,omitempty"`
+	ID      interface{} `json:"id"`
+}
+
+const (
+	RPC_PARSEERROR     = -32700
+	RPC_INVALIDREQUEST = -32600
+	RPC_METHODNOTFOUND = -32601
+	RPC_INVALIDPARAMS  = -32602
+	RPC_COMMANDERROR   = -32000
+)
+
+var serverMethods = []string{"command", "redraw", "selection", "select", "query", "nodes", "elems", "frame"}
+
+type rpcMethodError struct {
+	code int
+	msg  string
+}
+
+func (e *rpcMethodError) Error() string {
+	return e.msg
+}
+
+type ServerParams struct {
+	Command string `json:"command"`
+	Query   string `json:"query"`
+	Nodes   []int  `json:"nodes"`
+	Elems   []int  `json:"elems"`
+	Nums    []int  `json:"nums"`
+	Period  string `json:"period"`
+	Select  bool   `json:"select"`
+	Node    bool   `json:"node"`
+}
+
+type nodeJSON struct {
+	Num      int       `json:"num"`
+	Coord    []float64 `json:"coord"`
+	Conf     []bool    `json:"conf"`
+	Pile     int       `json:"pile,omitempty"`
+	Weight   []float64 `json:"weight"`
+	Disp     []float64 `json:"disp,omitempty"`
+	Reaction []float64 `json:"reaction,omitempty"`
+}
+
+type elemJSON struct {
+	Num    int         `json:"num"`
+	Etype  string      `json:"etype"`
+	Sect   int         `json:"sect"`
+	Enod   []int       `json:"enod"`
+	Length float64     `json:"length,omitempty"`
+	Stress [][]float64 `json:"stress,omitempty"`
+	Rate   float64     `json:"rate,omitempty"`
+}
+
+func NewServer(stw *Window) (*Server, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	srv := &Server{stw: stw, Token: hex.EncodeToString(b)}
+	if stw.driver != nil {
+		srv.Call = stw.driver.CallSync
+	} else {
+		srv.Call = func(f func()) {
+			f()
+		}
+	}
+	return srv, nil
+}
+
+// Start listens on addr, which must be a loopback address such as 127.0.0.1:8000.
+func (srv *Server) Start(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if !isLoopback(host) {
+		return errors.New(fmt.Sprintf("Serve: %s is not a loopback address", host))
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv.listener = l
+	srv.Addr = l.Addr().String()
+	go http.Serve(l, srv.Handler())
+	return nil
+}
+
+func (srv *Server) Stop() error {
+	if srv.listener == nil {
+		return nil
+	}
+	err := srv.listener.Close()
+	srv.listener = nil
+	return err
+}
+
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Handler returns the handler of the API with the checks of the token, Origin, Host and Content-Type.
+func (srv *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rpc", srv.serveRPC)
+	mux.HandleFunc("/command", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "POST expected", http.StatusMethodNotAllowed)
+			return
+		}
+		p := new(ServerParams)
+		err := json.NewDecoder(r.Body).Decode(p)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p.Command = strings.TrimSpace(p.Command)
+		srv.serveMethod(w, "command", p)
+	})
+	for _, name := range []string{"selection", "nodes", "elems", "frame", "redraw"} {
+		method := name
+		mux.HandleFunc("/"+method, func(w http.ResponseWriter, r *http.Request) {
+			p := &ServerParams{Period: r.FormValue("period")}
+			if num := r.FormValue("num"); num != "" {
+				for _, s := range strings.Split(num, ",") {
+					val, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+					if err != nil {
+						http.Error(w, fmt.Sprintf("num: %s", err.Error()), http.StatusBadRequest)
+						return
+					}
+					p.Nums = append(p.Nums, int(val))
+				}
+			}
+			srv.serveMethod(w, method, p)
+		})
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := srv.check(r)
+		if err != nil {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (srv *Server) check(r *http.Request) error {
+	if r.Header.Get("Origin") != "" {
+		return errors.New("requests from browsers are not allowed")
+	}
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	if !isLoopback(host) {
+		return errors.New(fmt.Sprintf("host %s is not a loopback address", r.Host))
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(ServerTokenHeader)), []byte(srv.Token)) != 1 {
+		return errors.New(fmt.Sprintf("%s header doesn't match the token of :serve", ServerTokenHeader))
+	}
+	if r.Method != "GET" {
+		mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mt != "application/json" {
+			return errors.New("Content-Type must be application/json")
+		}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (srv *Server) serveMethod(w http.ResponseWriter, method string, p *ServerParams) {
+	result, err := srv.Do(method, p)
+	if err != nil {
+		status := http.StatusBadRequest
+		if e, ok := err.(*rpcMethodError); ok && e.code == RPC_METHODNOTFOUND {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (srv *Server) serveRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST expected", http.StatusMethodNotAllowed)
+		return
+	}
+	var req rpcRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeJSON(w, http.StatusOK, &rpcResponse{JSONRPC: "2.0", Error: &rpcError{RPC_PARSEERROR, err.Error()}})
+		return
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		writeJSON(w, http.StatusOK, &rpcResponse{JSONRPC: "2.0", Error: &rpcError{RPC_INVALIDREQUEST, "jsonrpc 2.0 request with method expected"}, ID: req.ID})
+		return
+	}
+	p := new(ServerParams)
+	if len(req.Params) > 0 {
+		err = json.Unmarshal(req.Params, p)
+		if err != nil {
+			writeJSON(w, http.StatusOK, &rpcResponse{JSONRPC: "2.0", Error: &rpcError{RPC_INVALIDPARAMS, err.Error()}, ID: req.ID})
+			return
+		}
+	}
+	result, err := srv.Do(req.Method, p)
+	if err != nil {
+		code := RPC_COMMANDERROR
+		if e, ok := err.(*rpcMethodError); ok {
+			code = e.code
+		}
+		writeJSON(w, http.StatusOK, &rpcResponse{JSONRPC: "2.0", Error: &rpcError{code, err.Error()}, ID: req.ID})
+		return
+	}
+	writeJSON(w, http.StatusOK, &rpcResponse{JSONRPC: "2.0", Result: result, ID: req.ID})
+}
+
+// Do runs method on the UI thread and returns its result to be encoded as JSON.
+func (srv *Server) Do(method string, p *ServerParams) (interface{}, error) {
+	srv.Lock()
+	defer srv.Unlock()
+	var result interface{}
+	var err error
+	srv.Call(func() {
+		result, err = srv.do(strings.ToLower(method), p)
+	})
+	return result, err
+}
+
+func (srv *Server) do(method string, p *ServerParams) (interface{}, error) {
+	stw := srv.stw
+	found := false
+	for _, m := range serverMethods {
+		if m == method {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, &rpcMethodError{RPC_METHODNOTFOUND, fmt.Sprintf("method %s doesn't exist", method)}
+	}
+	switch method {
+	case "command":
+		return srv.command(p.Command)
+	case "redraw":
+		if stw.Frame != nil {
+			stw.Redraw()
+		}
+		return map[string]bool{"ok": true}, nil
+	}
+	if stw.Frame == nil {
+		return nil, errors.New(fmt.Sprintf("%s: no frame", method))
+	}
+	switch method {
+	case "selection":
+		return serverSelection(stw.SelectNode, stw.SelectElem), nil
+	case "select":
+		stw.Deselect()
+		for _, nnum := range p.Nodes {
+			if n, ok := stw.Frame.Nodes[nnum]; ok {
+				stw.SelectNode = append(stw.SelectNode, n)
+			}
+		}
+		for _, enum := range p.Elems {
+			if el, ok := stw.Frame.Elems[enum]; ok {
+				stw.SelectElem = append(stw.SelectElem, el)
+			}
+		}
+		return serverSelection(stw.SelectNode, stw.SelectElem), nil
+	case "query":
+		if p.Query == "" {
+			return nil, &rpcMethodError{RPC_INVALIDPARAMS, "query: query expected"}
+		}
+		if p.Node {
+			ns, err := stw.QueryNode(p.Query, stw.SelectNode)
+			if err != nil {
+				return nil, err
+			}
+			if p.Select {
+				stw.SelectNode = ns
+			}
+			return serverSelection(ns, nil), nil
+		}
+		els, err := stw.QueryElem(p.Query, stw.SelectElem)
+		if err != nil {
+			return nil, err
+		}
+		if p.Select {
+			stw.SelectElem = els
+		}
+		return serverSelection(nil, els), nil
+	case "nodes":
+		per := srv.period(p.Period)
+		rtn := make([]*nodeJSON, 0)
+		if len(p.Nums) == 0 {
+			for _, n := range sortednodes(stw.Frame) {
+				rtn = append(rtn, serverNode(n, per))
+			}
+			return rtn, nil
+		}
+		for _, nnum := range p.Nums {
+			n, ok := stw.Frame.Nodes[nnum]
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("nodes: NODE %d doesn't exist", nnum))
+			}
+			rtn = append(rtn, serverNode(n, per))
+		}
+		return rtn, nil
+	case "elems":
+		per := srv.period(p.Period)
+		rtn := make([]*elemJSON, 0)
+		if len(p.Nums) == 0 {
+			for _, el := range sortedelems(stw.Frame) {
+				rtn = append(rtn, srv.elem(el, per))
+			}
+			return rtn, nil
+		}
+		for _, enum := range p.Nums {
+			el, ok := stw.Frame.Elems[enum]
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("elems: ELEM %d doesn't exist", enum))
+			}
+			rtn = append(rtn, srv.elem(el, per))
+		}
+		return rtn, nil
+	case "frame":
+		snums := make([]int, 0, len(stw.Frame.Sects))
+		for snum := range stw.Frame.Sects {
+			snums = append(snums, snum)
+		}
+		sort.Ints(snums)
+		return map[string]interface{}{
+			"name":    stw.Frame.Name,
+			"path":    stw.Frame.Path,
+			"period":  stw.Frame.Show.Period,
+			"periods": stw.PeriodCandidates(""),
+			"nnode":   len(stw.Frame.Nodes),
+			"nelem":   len(stw.Frame.Elems),
+			"sects":   snums,
+		}, nil
+	}
+	return nil, nil
+}
+
+// command runs an ex (pipes allowed) or fig2 command and returns its message. Other commands are run as aliases.
+func (srv *Server) command(com string) (interface{}, error) {
+	stw := srv.stw
+	com = stw.ExpandVariables(com)
+	var msg string
+	switch {
+	case com == "":
+		return nil, &rpcMethodError{RPC_INVALIDPARAMS, "command: command expected"}
+	case strings.HasPrefix(com, ":"):
+		out, err := stw.CommandResult(com)
+		if err != nil {
+			return nil, err
+		}
+		msg = out.Message
+	case strings.HasPrefix(com, "'"):
+		err := stw.fig2mode(com)
+		if err != nil {
+			if u, ok := err.(st.Messager); ok {
+				msg = u.Message()
+			} else {
+				return nil, err
+			}
+		}
+	default:
+		if stw.Frame == nil {
+			return nil, errors.New("command: no frame")
+		}
+		stw.execAliasCommand(com)
+	}
+	return map[string]string{"message": msg}, nil
+}
+
+func (srv *Server) period(per string) string {
+	if per == "" {
+		return srv.stw.Frame.Show.Period
+	}
+	return strings.ToUpper(per)
+}
+
+func serverSelection(ns []*st.Node, els []*st.Elem) map[string][]int {
+	nnums := make([]int, 0, len(ns))
+	for _, n := range ns {
+		if n != nil {
+			nnums = append(nnums, n.Num)
+		}
+	}
+	enums := make([]int, 0, len(els))
+	for _, el := range els {
+		if el != nil {
+			enums = append(enums, el.Num)
+		}
+	}
+	return map[string][]int{"nodes": nnums, "elems": enums}
+}
+
+func serverNode(n *st.Node, per string) *nodeJSON {
+	rtn := &nodeJSON{
+		Num:    n.Num,
+		Coord:  []float64{n.Coord[0], n.Coord[1], n.Coord[2]},
+		Conf:   make([]bool, 6),
+		Weight: []float64{n.Weight[0], n.Weight[1], n.Weight[2]},
+	}
+	for i := 0; i < 6; i++ {
+		rtn.Conf[i] = n.Conf[i]
+	}
+	if n.Pile != nil {
+		rtn.Pile = n.Pile.Num
+	}
+	if _, ok := n.Disp[per]; ok {
+		rtn.Disp = make([]float64, 6)
+		for i := 0; i < 6; i++ {
+			rtn.Disp[i] = n.ReturnDisp(per, i)
+		}
+	}
+	if _, ok := n.Reaction[per]; ok {
+		rtn.Reaction = make([]float64, 6)
+		for i := 0; i < 6; i++ {
+			rtn.Reaction[i] = n.ReturnReaction(per, i)
+		}
+	}
+	return rtn
+}
+
+// elem returns the stress of both ends for line elems, and the rate when per is the current period.
+func (srv *Server) elem(el *st.Elem, per string) *elemJSON {
+	rtn := &elemJSON{
+		Num:   el.Num,
+		Etype: st.ETYPES[el.Etype],
+		Enod:  make([]int, el.Enods),
+	}
+	if el.Sect != nil {
+		rtn.Sect = el.Sect.Num
+	}
+	for i := 0; i < el.Enods; i++ {
+		rtn.Enod[i] = el.Enod[i].Num
+	}
+	if !el.IsLineElem() {
+		return rtn
+	}
+	rtn.Length = el.Length()
+	if _, ok := el.Stress[per]; ok {
+		rtn.Stress = make([][]float64, 2)
+		for i := 0; i < 2; i++ {
+			rtn.Stress[i] = make([]float64, 6)
+			for j := 0; j < 6; j++ {
+				rtn.Stress[i][j] = el.ReturnStress(per, i, j)
+			}
+		}
+	}
+	if per == srv.stw.Frame.Show.Period {
+		if val, err := el.RateMax(srv.stw.Frame.Show); err == nil {
+			rtn.Rate = val
+		}
+	}
+	return rtn
+}
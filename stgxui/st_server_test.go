@@ -0,0 +1,139 @@
+package stgxui
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/yofu/st/stlib"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// headlessWindow returns a Window without a driver and widgets with nodes 1, 2 and 3 on the X axis.
+func headlessWindow(t *testing.T) *Window {
+	frame := st.NewFrame()
+	for i := 0; i < 3; i++ {
+		frame.CoordNode(float64(i), 0.0, 0.0, EPS)
+	}
+	if len(frame.Nodes) != 3 {
+		t.Fatalf("nodes: %d", len(frame.Nodes))
+	}
+	return &Window{
+		Frame:     frame,
+		variables: make(map[string]string),
+	}
+}
+
+func startTestServer(t *testing.T) (*Server, *httptest.Server) {
+	srv, err := NewServer(headlessWindow(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, httptest.NewServer(srv.Handler())
+}
+
+func post(t *testing.T, srv *Server, url string, body interface{}) (int, []byte) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ServerTokenHeader, srv.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	buf.ReadFrom(resp.Body)
+	return resp.StatusCode, buf.Bytes()
+}
+
+func rpc(t *testing.T, srv *Server, url, method string, params interface{}) *rpcResponse {
+	status, body := post(t, srv, url+"/rpc", map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params, "id": 1})
+	if status != http.StatusOK {
+		t.Fatalf("%s: status %d: %s", method, status, body)
+	}
+	resp := new(rpcResponse)
+	err := json.Unmarshal(body, resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != nil {
+		t.Fatalf("%s: %s", method, resp.Error.Message)
+	}
+	return resp
+}
+
+func TestServerHeadless(t *testing.T) {
+	srv, ts := startTestServer(t)
+	defer ts.Close()
+
+	resp := rpc(t, srv, ts.URL, "command", map[string]string{"command": ":node 1,2 | :count"})
+	if msg := resp.Result.(map[string]interface{})["message"]; msg != "NODES: 2, ELEMS: 0" {
+		t.Errorf("command: %v", msg)
+	}
+
+	resp = rpc(t, srv, ts.URL, "select", map[string][]int{"nodes": []int{3}})
+	if nodes := resp.Result.(map[string]interface{})["nodes"].([]interface{}); len(nodes) != 1 || nodes[0].(float64) != 3 {
+		t.Errorf("select: %v", nodes)
+	}
+
+	resp = rpc(t, srv, ts.URL, "nodes", map[string][]int{"nums": []int{2}})
+	nodes := resp.Result.([]interface{})
+	if len(nodes) != 1 {
+		t.Fatalf("nodes: %v", nodes)
+	}
+	if coord := nodes[0].(map[string]interface{})["coord"].([]interface{}); coord[0].(float64) != 1.0 {
+		t.Errorf("nodes: coord %v", coord)
+	}
+
+	status, body := post(t, srv, ts.URL+"/command", map[string]string{"command": ":node 1 | :count"})
+	if status != http.StatusOK || !strings.Contains(string(body), "NODES: 1, ELEMS: 0") {
+		t.Errorf("/command: status %d: %s", status, body)
+	}
+}
+
+func TestServerRefuses(t *testing.T) {
+	srv, ts := startTestServer(t)
+	defer ts.Close()
+	body := `{"command": ":node 1"}`
+	for _, c := range []struct {
+		name   string
+		header map[string]string
+	}{
+		{"no token", map[string]string{"Content-Type": "application/json"}},
+		{"wrong token", map[string]string{"Content-Type": "application/json", ServerTokenHeader: "x"}},
+		{"origin", map[string]string{"Content-Type": "application/json", ServerTokenHeader: srv.Token, "Origin": "http://example.com"}},
+		{"text/plain", map[string]string{"Content-Type": "text/plain", ServerTokenHeader: srv.Token}},
+		{"host", map[string]string{"Content-Type": "application/json", ServerTokenHeader: srv.Token, "Host": "example.com"}},
+	} {
+		req, err := http.NewRequest("POST", ts.URL+"/command", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range c.header {
+			if k == "Host" {
+				req.Host = v
+				continue
+			}
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s: status %d", c.name, resp.StatusCode)
+		}
+	}
+	if len(srv.stw.SelectNode) != 0 {
+		t.Errorf("refused requests selected %d nodes", len(srv.stw.SelectNode))
+	}
+}
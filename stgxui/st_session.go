@@ -0,0 +1,297 @@
+package stgxui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/yofu/st/stlib"
+	"io/ioutil"
+	"sort"
+)
+
+const SessionExt = ".session"
+
+// Session is saved when the window is closed and when another file is opened,
+// and is restored with the tagged frames it refers to by OpenLastSession.
+type Session struct {
+	View       *SessionView
+	Show       *SessionShow
+	Tags       []string
+	SelectNode []int
+	SelectElem []int
+	ColorMap   *ColorMap
+}
+
+type SessionView struct {
+	Gfact       float64
+	Perspective bool
+	Focus       []float64
+	Angle       []float64
+	Dists       []float64
+	Center      []float64
+}
+
+type SessionShow struct {
+	ColorMode   uint
+	Period      string
+	NodeCaption uint
+	ElemCaption uint
+	SrcanRate   uint
+	Stress      map[int]uint
+	Deformation bool
+	Conf        bool
+	Bond        bool
+	Kijun       bool
+	GlobalAxis  bool
+	ElementAxis bool
+	Dfact       float64
+	Rfact       float64
+	Qfact       float64
+	Mfact       float64
+	Unit        []float64
+	UnitName    []string
+	Xrange      []float64
+	Yrange      []float64
+	Zrange      []float64
+	HiddenSect  []int
+	HiddenEtype []int
+}
+
+func (stw *Window) SessionFileName() string {
+	if stw.Frame == nil {
+		return ""
+	}
+	return st.Ce(stw.Frame.Path, SessionExt)
+}
+
+func (stw *Window) SaveSession() error {
+	if stw.Frame == nil {
+		return errors.New("SaveSession: no frame")
+	}
+	v := stw.Frame.View
+	s := stw.Frame.Show
+	ses := &Session{
+		View: &SessionView{
+			Gfact:       v.Gfact,
+			Perspective: v.Perspective,
+			Focus:       []float64{v.Focus[0], v.Focus[1], v.Focus[2]},
+			Angle:       []float64{v.Angle[0], v.Angle[1]},
+			Dists:       []float64{v.Dists[0], v.Dists[1]},
+			Center:      []float64{v.Center[0], v.Center[1]},
+		},
+		Show: &SessionShow{
+			ColorMode:   s.ColorMode,
+			Period:      s.Period,
+			NodeCaption: s.NodeCaption,
+			ElemCaption: s.ElemCaption,
+			SrcanRate:   s.SrcanRate,
+			Stress:      make(map[int]uint),
+			Deformation: s.Deformation,
+			Conf:        s.Conf,
+			Bond:        s.Bond,
+			Kijun:       s.Kijun,
+			GlobalAxis:  s.GlobalAxis,
+			ElementAxis: s.ElementAxis,
+			Dfact:       s.Dfact,
+			Rfact:       s.Rfact,
+			Qfact:       s.Qfact,
+			Mfact:       s.Mfact,
+			Unit:        []float64{s.Unit[0], s.Unit[1]},
+			UnitName:    []string{s.UnitName[0], s.UnitName[1]},
+			Xrange:      []float64{s.Xrange[0], s.Xrange[1]},
+			Yrange:      []float64{s.Yrange[0], s.Yrange[1]},
+			Zrange:      []float64{s.Zrange[0], s.Zrange[1]},
+			HiddenSect:  make([]int, 0),
+			HiddenEtype: make([]int, 0),
+		},
+		Tags:       stw.TagNames(),
+		SelectNode: make([]int, 0),
+		SelectElem: make([]int, 0),
+		ColorMap:   stw.colormap,
+	}
+	for k, val := range s.Stress {
+		if val != 0 {
+			ses.Show.Stress[k] = val
+		}
+	}
+	for snum, show := range s.Sect {
+		if !show {
+			ses.Show.HiddenSect = append(ses.Show.HiddenSect, snum)
+		}
+	}
+	sort.Ints(ses.Show.HiddenSect)
+	for etype, show := range s.Etype {
+		if etype != 0 && !show {
+			ses.Show.HiddenEtype = append(ses.Show.HiddenEtype, etype)
+		}
+	}
+	for _, n := range stw.SelectNode {
+		if n != nil {
+			ses.SelectNode = append(ses.SelectNode, n.Num)
+		}
+	}
+	for _, el := range stw.SelectElem {
+		if el != nil {
+			ses.SelectElem = append(ses.SelectElem, el.Num)
+		}
+	}
+	data, err := json.MarshalIndent(ses, "", "  ")
+	if err != nil {
+		return err
+	}
+	fn := stw.SessionFileName()
+	err = ioutil.WriteFile(fn, data, 0644)
+	if err != nil {
+		return err
+	}
+	stw.ErrorMessage(errors.New(fmt.Sprintf("SESSION: %s", fn)), INFO)
+	return nil
+}
+
+func (stw *Window) LoadSession() error {
+	if stw.Frame == nil {
+		return errors.New("LoadSession: no frame")
+	}
+	fn := stw.SessionFileName()
+	if !st.FileExists(fn) {
+		return errors.New(fmt.Sprintf("LoadSession: %s doesn't exist", fn))
+	}
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	ses := new(Session)
+	err = json.Unmarshal(data, ses)
+	if err != nil {
+		return err
+	}
+	if sv := ses.View; sv != nil {
+		v := stw.Frame.View
+		v.Gfact = sv.Gfact
+		v.Perspective = sv.Perspective
+		for i := 0; i < 3 && i < len(sv.Focus); i++ {
+			v.Focus[i] = sv.Focus[i]
+		}
+		for i := 0; i < 2; i++ {
+			if i < len(sv.Angle) {
+				v.Angle[i] = sv.Angle[i]
+			}
+			if i < len(sv.Dists) {
+				v.Dists[i] = sv.Dists[i]
+			}
+			if i < len(sv.Center) {
+				v.Center[i] = sv.Center[i]
+			}
+		}
+	}
+	if ss := ses.Show; ss != nil {
+		s := stw.Frame.Show
+		stw.colormap = nil
+		if ses.ColorMap != nil {
+			if err := ses.ColorMap.check(); err == nil {
+				stw.colormap = ses.ColorMap
+			} else {
+				stw.ErrorMessage(err, ERROR)
+			}
+		}
+		if ss.ColorMode == ECOLOR_MAP && stw.colormap == nil {
+			ss.ColorMode = st.ECOLOR_SECT
+		}
+		stw.SetColorMode(ss.ColorMode)
+		stw.SetPeriod(ss.Period)
+		s.NodeCaption = ss.NodeCaption
+		s.ElemCaption = ss.ElemCaption
+		s.SrcanRate = ss.SrcanRate
+		for k, val := range ss.Stress {
+			s.Stress[k] = val
+		}
+		s.Deformation = ss.Deformation
+		s.Conf = ss.Conf
+		s.Bond = ss.Bond
+		s.Kijun = ss.Kijun
+		s.GlobalAxis = ss.GlobalAxis
+		s.ElementAxis = ss.ElementAxis
+		s.Dfact = ss.Dfact
+		s.Rfact = ss.Rfact
+		s.Qfact = ss.Qfact
+		s.Mfact = ss.Mfact
+		for i := 0; i < 2; i++ {
+			if i < len(ss.Unit) {
+				s.Unit[i] = ss.Unit[i]
+			}
+			if i < len(ss.UnitName) {
+				s.UnitName[i] = ss.UnitName[i]
+			}
+		}
+		for _, snum := range ss.HiddenSect {
+			if _, ok := stw.Frame.Sects[snum]; ok {
+				stw.HideSection(snum)
+			}
+		}
+		for _, etype := range ss.HiddenEtype {
+			stw.HideEtype(etype)
+		}
+		ranges := [][]float64{ss.Xrange, ss.Yrange, ss.Zrange}
+		srange := [][]float64{s.Xrange, s.Yrange, s.Zrange}
+		for i := 0; i < 3; i++ {
+			if len(ranges[i]) < 2 {
+				ranges[i] = []float64{srange[i][0], srange[i][1]}
+				continue
+			}
+			srange[i][0] = ranges[i][0]
+			srange[i][1] = ranges[i][1]
+		}
+		tmpnodes := make([]*st.Node, 0)
+		for _, n := range stw.Frame.Nodes {
+			for i := 0; i < 3; i++ {
+				if n.Coord[i] < ranges[i][0] || ranges[i][1] < n.Coord[i] {
+					n.Hide()
+					tmpnodes = append(tmpnodes, n)
+					break
+				}
+			}
+		}
+		for _, el := range stw.Frame.NodeToElemAny(tmpnodes...) {
+			el.Hide()
+		}
+	}
+	stw.Deselect()
+	for _, nnum := range ses.SelectNode {
+		if n, ok := stw.Frame.Nodes[nnum]; ok {
+			stw.SelectNode = append(stw.SelectNode, n)
+		}
+	}
+	for _, enum := range ses.SelectElem {
+		if el, ok := stw.Frame.Elems[enum]; ok {
+			stw.SelectElem = append(stw.SelectElem, el)
+		}
+	}
+	if len(ses.Tags) > 0 {
+		tags := make([]string, 0)
+		for _, name := range ses.Tags {
+			_, err := stw.TaggedFrame(name)
+			if err != nil {
+				stw.ErrorMessage(err, ERROR)
+				continue
+			}
+			tags = append(tags, name)
+		}
+		stw.History(fmt.Sprintf("TAGS: %v", tags))
+	}
+	stw.History(fmt.Sprintf("SESSION: %s", fn))
+	return nil
+}
+
+func (stw *Window) OpenLastSession() error {
+	fn := stw.recentfiles[0]
+	if fn == "" || !st.FileExists(fn) {
+		return errors.New("OpenLastSession: no recent file")
+	}
+	err := stw.OpenFile(fn, true)
+	if err != nil {
+		return err
+	}
+	err = stw.LoadSession()
+	stw.Redraw()
+	return err
+}
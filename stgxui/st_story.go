@@ -0,0 +1,271 @@
+package stgxui
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/yofu/st/stlib"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// StoryRow is a line of the story report of a period loaded in Dir ("X" or "Y").
+// Drifts are angles, Shear is the sum of lateral stiffness times relative displacement of the members.
+type StoryRow struct {
+	Period   string
+	Dir      string
+	Story    int
+	Height   float64
+	Shear    float64
+	DriftAvg float64
+	DriftMax float64
+	Rs       float64
+	Re       float64
+}
+
+type storymember struct {
+	elem   *st.Elem
+	bottom *st.Node
+	top    *st.Node
+}
+
+// level returns the index of the floor of z in Ai.Boundary, or -1.
+func level(boundary []float64, z float64) int {
+	for i := 0; i < len(boundary)-1; i++ {
+		if boundary[i] <= z && z < boundary[i+1] {
+			return i
+		}
+	}
+	return -1
+}
+
+// storymembers returns the columns and braces between floor k-1 and floor k.
+func storymembers(frame *st.Frame) map[int][]*storymember {
+	rtn := make(map[int][]*storymember)
+	for _, el := range sortedelems(frame) {
+		switch el.Etype {
+		default:
+			continue
+		case st.COLUMN, st.BRACE:
+		}
+		b, t := el.Enod[0], el.Enod[1]
+		if b.Coord[2] > t.Coord[2] {
+			b, t = t, b
+		}
+		lb := level(frame.Ai.Boundary, b.Coord[2])
+		lt := level(frame.Ai.Boundary, t.Coord[2])
+		if lb < 0 || lt != lb+1 {
+			continue
+		}
+		rtn[lt] = append(rtn[lt], &storymember{el, b, t})
+	}
+	return rtn
+}
+
+// ParseStoryPeriods parses "X,Y,XN:X" into pairs of period and direction.
+// Periods other than X and Y need their direction.
+func ParseStoryPeriods(str string) ([][]string, error) {
+	rtn := make([][]string, 0)
+	for _, p := range strings.Split(strings.ToUpper(str), ",") {
+		lis := strings.Split(p, ":")
+		switch {
+		case len(lis) == 2 && (lis[1] == "X" || lis[1] == "Y"):
+			rtn = append(rtn, lis)
+		case len(lis) == 1 && (lis[0] == "X" || lis[0] == "Y"):
+			rtn = append(rtn, []string{lis[0], lis[0]})
+		default:
+			return nil, errors.New(fmt.Sprintf("ParseStoryPeriods: direction of %s is unknown (use period:X or period:Y)", p))
+		}
+	}
+	return rtn, nil
+}
+
+// StoryReport computes story shear, drift angles, stiffness ratio Rs and eccentricity Re for each period.
+// Re needs both an X and a Y period. The center of gravity is weighted by axial forces of period L.
+func StoryReport(frame *st.Frame, periods [][]string) ([]*StoryRow, error) {
+	if len(frame.Ai.Boundary) < 3 {
+		return nil, errors.New("StoryReport: Ai.Boundary is not defined")
+	}
+	members := storymembers(frame)
+	rows := make([]*StoryRow, 0)
+	stiff := make(map[string]map[int][]float64)
+	for _, pd := range periods {
+		per, dir := pd[0], pd[1]
+		ind := 0
+		if dir == "Y" {
+			ind = 1
+		}
+		prows := make([]*StoryRow, 0)
+		for k := 1; k < len(frame.Ai.Boundary)-1; k++ {
+			ms, ok := members[k]
+			if !ok {
+				continue
+			}
+			row := &StoryRow{Period: per, Dir: dir, Story: k}
+			ks := make([]float64, len(ms))
+			nh := 0
+			for i, m := range ms {
+				h := m.top.Coord[2] - m.bottom.Coord[2]
+				d := m.top.ReturnDisp(per, ind) - m.bottom.ReturnDisp(per, ind)
+				theta := math.Abs(d) / h
+				row.DriftAvg += theta
+				if theta > row.DriftMax {
+					row.DriftMax = theta
+				}
+				if m.elem.Etype == st.COLUMN {
+					row.Height += h
+					nh++
+				}
+				ks[i] = m.elem.LateralStiffness(per, false)
+				if ks[i] != 1e16 {
+					row.Shear += ks[i] * d
+				}
+			}
+			row.DriftAvg /= float64(len(ms))
+			if nh > 0 {
+				row.Height /= float64(nh)
+			}
+			if _, ok := stiff[dir]; !ok {
+				stiff[dir] = make(map[int][]float64)
+			}
+			if _, ok := stiff[dir][k]; !ok {
+				stiff[dir][k] = ks
+			}
+			prows = append(prows, row)
+		}
+		var rsum float64
+		for _, row := range prows {
+			if row.DriftAvg > 0.0 {
+				rsum += 1.0 / row.DriftAvg
+			}
+		}
+		if rsum > 0.0 {
+			rmean := rsum / float64(len(prows))
+			for _, row := range prows {
+				if row.DriftAvg > 0.0 {
+					row.Rs = 1.0 / row.DriftAvg / rmean
+				}
+			}
+		}
+		rows = append(rows, prows...)
+	}
+	if kx, ok := stiff["X"]; ok {
+		if ky, ok := stiff["Y"]; ok {
+			re := make(map[int][]float64)
+			for k, ms := range members {
+				if _, ok := kx[k]; !ok {
+					continue
+				}
+				if _, ok := ky[k]; !ok {
+					continue
+				}
+				re[k] = eccentricity(ms, kx[k], ky[k])
+			}
+			for _, row := range rows {
+				if r, ok := re[row.Story]; ok {
+					if row.Dir == "X" {
+						row.Re = r[0]
+					} else {
+						row.Re = r[1]
+					}
+				}
+			}
+		}
+	}
+	return rows, nil
+}
+
+// eccentricity returns Re in X and Y direction of a story.
+func eccentricity(ms []*storymember, kx, ky []float64) []float64 {
+	var w, gx, gy float64
+	for _, m := range ms {
+		if m.elem.Etype != st.COLUMN {
+			continue
+		}
+		n := math.Abs(m.elem.N("L", 0))
+		w += n
+		gx += n * m.bottom.Coord[0]
+		gy += n * m.bottom.Coord[1]
+	}
+	if w == 0.0 {
+		for _, m := range ms {
+			w += 1.0
+			gx += m.bottom.Coord[0]
+			gy += m.bottom.Coord[1]
+		}
+	}
+	gx /= w
+	gy /= w
+	var skx, sky, rx, ry float64
+	for i, m := range ms {
+		if kx[i] != 1e16 {
+			skx += kx[i]
+			ry += kx[i] * m.bottom.Coord[1]
+		}
+		if ky[i] != 1e16 {
+			sky += ky[i]
+			rx += ky[i] * m.bottom.Coord[0]
+		}
+	}
+	if skx == 0.0 || sky == 0.0 {
+		return []float64{0.0, 0.0}
+	}
+	rx /= sky
+	ry /= skx
+	var kr float64
+	for i, m := range ms {
+		if kx[i] != 1e16 {
+			kr += kx[i] * (m.bottom.Coord[1] - ry) * (m.bottom.Coord[1] - ry)
+		}
+		if ky[i] != 1e16 {
+			kr += ky[i] * (m.bottom.Coord[0] - rx) * (m.bottom.Coord[0] - rx)
+		}
+	}
+	rex := math.Sqrt(kr / skx)
+	rey := math.Sqrt(kr / sky)
+	rtn := make([]float64, 2)
+	if rex > 0.0 {
+		rtn[0] = math.Abs(gy-ry) / rex
+	}
+	if rey > 0.0 {
+		rtn[1] = math.Abs(gx-rx) / rey
+	}
+	return rtn
+}
+
+func inverse(val float64) float64 {
+	if val == 0.0 {
+		return 0.0
+	}
+	return 1.0 / val
+}
+
+func StoryReportString(rows []*StoryRow) string {
+	var otp bytes.Buffer
+	otp.WriteString("PERIOD DIR STORY   HEIGHT      SHEAR  DRIFT(AVG)  DRIFT(MAX)     Rs     Re\n")
+	for _, r := range rows {
+		otp.WriteString(fmt.Sprintf("%-6s %3s %5d %8.3f %10.3f  1/%8.1f  1/%8.1f  %5.3f  %5.3f\n", r.Period, r.Dir, r.Story, r.Height, r.Shear, inverse(r.DriftAvg), inverse(r.DriftMax), r.Rs, r.Re))
+	}
+	return strings.TrimSuffix(otp.String(), "\n")
+}
+
+func WriteStoryReport(fn string, rows []*StoryRow) error {
+	w, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"PERIOD", "DIR", "STORY", "HEIGHT", "SHEAR", "DRIFTAVG", "DRIFTMAX", "RS", "RE"})
+	f := func(val float64) string {
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	}
+	for _, r := range rows {
+		cw.Write([]string{r.Period, r.Dir, strconv.Itoa(r.Story), f(r.Height), f(r.Shear), f(r.DriftAvg), f(r.DriftMax), f(r.Rs), f(r.Re)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
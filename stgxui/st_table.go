@@ -0,0 +1,351 @@
+package stgxui
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/google/gxui"
+	"github.com/yofu/st/stlib"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	TABLE_NODE = iota
+	TABLE_ELEM
+)
+
+var (
+	NODECOLUMNS    = []string{"NODE", "X", "Y", "Z", "DX", "DY", "DZ", "TX", "TY", "TZ", "RX", "RY", "RZ", "MX", "MY", "MZ"}
+	ELEMCOLUMNS    = []string{"ELEM", "SECT", "ETYPE", "NODE", "N", "QX", "QY", "MT", "MX", "MY"}
+	re_tablefilter = regexp.MustCompile("^ *([A-Za-z]+) *(<=|>=|==|!=|<|>|=) *([-+0-9.eE]+) *$")
+)
+
+// ResultRow is a row of ResultTable. Values correspond to the columns of the table.
+type ResultRow struct {
+	Node   *st.Node
+	Elem   *st.Elem
+	Values []float64
+	text   string
+}
+
+func (r *ResultRow) String() string {
+	return r.text
+}
+
+// ResultTable lists the results of the current period in a window.
+type ResultTable struct {
+	stw     *Window
+	Kind    int
+	Period  string
+	Columns []string
+	rows    []*ResultRow
+	shown   []*ResultRow
+	sortcol int
+	desc    bool
+	filter  string
+	frame   *st.Frame
+	gen     int
+	window  gxui.Window
+	adapter *gxui.DefaultAdapter
+	list    gxui.List
+	label   gxui.Label
+	syncing bool
+}
+
+func (r *ResultRow) format(kind int) string {
+	var otp bytes.Buffer
+	for i, v := range r.Values {
+		switch {
+		case kind == TABLE_NODE && i == 0, kind == TABLE_ELEM && i <= 3:
+			otp.WriteString(fmt.Sprintf(" %6d", int(v)))
+		case kind == TABLE_NODE && i <= 3:
+			otp.WriteString(fmt.Sprintf(" %8.3f", v))
+		default:
+			otp.WriteString(fmt.Sprintf(" %10.4f", v))
+		}
+	}
+	return otp.String()
+}
+
+func tableheader(kind int, columns []string) string {
+	var otp bytes.Buffer
+	for i, c := range columns {
+		switch {
+		case kind == TABLE_NODE && i == 0, kind == TABLE_ELEM && i <= 3:
+			otp.WriteString(fmt.Sprintf(" %6s", c))
+		case kind == TABLE_NODE && i <= 3:
+			otp.WriteString(fmt.Sprintf(" %8s", c))
+		default:
+			otp.WriteString(fmt.Sprintf(" %10s", c))
+		}
+	}
+	return otp.String()
+}
+
+func NewResultTable(stw *Window, kind int) *ResultTable {
+	rt := &ResultTable{
+		stw:     stw,
+		Kind:    kind,
+		sortcol: 0,
+	}
+	switch kind {
+	case TABLE_NODE:
+		rt.Columns = NODECOLUMNS
+	case TABLE_ELEM:
+		rt.Columns = ELEMCOLUMNS
+	}
+	return rt
+}
+
+// Build collects the rows of the current frame and period.
+func (rt *ResultTable) Build() {
+	frame := rt.stw.Frame
+	rt.frame = frame
+	rt.gen = rt.stw.resultgen
+	rt.Period = frame.Show.Period
+	rt.rows = make([]*ResultRow, 0)
+	switch rt.Kind {
+	case TABLE_NODE:
+		for _, n := range sortednodes(frame) {
+			vals := []float64{float64(n.Num), n.Coord[0], n.Coord[1], n.Coord[2]}
+			for i := 0; i < 6; i++ {
+				vals = append(vals, n.ReturnDisp(rt.Period, i))
+			}
+			for i := 0; i < 6; i++ {
+				vals = append(vals, n.ReturnReaction(rt.Period, i))
+			}
+			r := &ResultRow{Node: n, Values: vals}
+			r.text = r.format(rt.Kind)
+			rt.rows = append(rt.rows, r)
+		}
+	case TABLE_ELEM:
+		for _, el := range sortedelems(frame) {
+			if !el.IsLineElem() {
+				continue
+			}
+			for end := 0; end < 2; end++ {
+				vals := []float64{float64(el.Num), float64(el.Sect.Num), float64(el.Etype), float64(el.Enod[end].Num)}
+				for i := 0; i < 6; i++ {
+					vals = append(vals, el.ReturnStress(rt.Period, end, i))
+				}
+				r := &ResultRow{Elem: el, Values: vals}
+				r.text = r.format(rt.Kind)
+				rt.rows = append(rt.rows, r)
+			}
+		}
+	}
+	rt.Update()
+}
+
+func (rt *ResultTable) column(name string) int {
+	for i, c := range rt.Columns {
+		if strings.EqualFold(c, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetFilter sets a condition such as "N>100" or "SECT==501". Others are matched against row numbers.
+func (rt *ResultTable) SetFilter(filter string) {
+	rt.filter = strings.TrimSpace(filter)
+	rt.Update()
+}
+
+func (rt *ResultTable) match(r *ResultRow) bool {
+	if rt.filter == "" {
+		return true
+	}
+	if fs := re_tablefilter.FindStringSubmatch(rt.filter); fs != nil {
+		col := rt.column(fs[1])
+		val, err := strconv.ParseFloat(fs[3], 64)
+		if col < 0 || err != nil {
+			return true
+		}
+		v := r.Values[col]
+		switch fs[2] {
+		case "<":
+			return v < val
+		case "<=":
+			return v <= val
+		case ">":
+			return v > val
+		case ">=":
+			return v >= val
+		case "=", "==":
+			return math.Abs(v-val) <= EPS
+		case "!=":
+			return math.Abs(v-val) > EPS
+		}
+	}
+	for _, num := range SplitNums(rt.filter) {
+		if int(r.Values[0]) == num {
+			return true
+		}
+	}
+	return false
+}
+
+func (rt *ResultTable) SortBy(col int) {
+	if col == rt.sortcol {
+		rt.desc = !rt.desc
+	} else {
+		rt.sortcol = col
+		rt.desc = false
+	}
+	rt.Update()
+}
+
+// Update applies the filter and the order to the rows and refreshes the list.
+func (rt *ResultTable) Update() {
+	rt.shown = make([]*ResultRow, 0)
+	for _, r := range rt.rows {
+		if rt.match(r) {
+			rt.shown = append(rt.shown, r)
+		}
+	}
+	col := rt.sortcol
+	desc := rt.desc
+	sort.Stable(rowsBy{rt.shown, func(a, b *ResultRow) bool {
+		if desc {
+			return a.Values[col] > b.Values[col]
+		}
+		return a.Values[col] < b.Values[col]
+	}})
+	if rt.adapter != nil {
+		rt.syncing = true
+		rt.adapter.SetItems(rt.shown)
+		rt.syncing = false
+		order := "ASC"
+		if rt.desc {
+			order = "DESC"
+		}
+		rt.window.SetTitle(fmt.Sprintf("%s PERIOD: %s SORT: %s %s (%d/%d)", rt.title(), rt.Period, rt.Columns[rt.sortcol], order, len(rt.shown), len(rt.rows)))
+	}
+}
+
+func (rt *ResultTable) title() string {
+	switch rt.Kind {
+	default:
+		return "NODE"
+	case TABLE_ELEM:
+		return "ELEM"
+	}
+}
+
+type rowsBy struct {
+	rows []*ResultRow
+	less func(a, b *ResultRow) bool
+}
+
+func (r rowsBy) Len() int           { return len(r.rows) }
+func (r rowsBy) Swap(i, j int)      { r.rows[i], r.rows[j] = r.rows[j], r.rows[i] }
+func (r rowsBy) Less(i, j int) bool { return r.less(r.rows[i], r.rows[j]) }
+
+// Show opens the window of the table.
+func (rt *ResultTable) Show() {
+	stw := rt.stw
+	theme := stw.theme
+	rt.adapter = gxui.CreateDefaultAdapter()
+	rt.adapter.SetItemSizeAsLargest(theme)
+	rt.list = theme.CreateList()
+	rt.list.SetAdapter(rt.adapter)
+	rt.list.OnSelectionChanged(func(item gxui.AdapterItem) {
+		if rt.syncing {
+			return
+		}
+		if r, ok := item.(*ResultRow); ok {
+			rt.selectRow(r)
+		}
+	})
+	filter := theme.CreateTextBox()
+	filter.SetDesiredWidth(300)
+	filter.OnTextChanged(func([]gxui.TextBoxEdit) {
+		rt.SetFilter(filter.Text())
+	})
+	buttons := theme.CreateLinearLayout()
+	buttons.SetDirection(gxui.LeftToRight)
+	for i, c := range rt.Columns {
+		col := i
+		button := theme.CreateButton()
+		button.SetText(c)
+		button.OnClick(func(gxui.MouseEvent) {
+			rt.SortBy(col)
+		})
+		buttons.AddChild(button)
+	}
+	rt.label = theme.CreateLabel()
+	rt.label.SetText(tableheader(rt.Kind, rt.Columns))
+	layout := theme.CreateLinearLayout()
+	layout.SetDirection(gxui.TopToBottom)
+	layout.AddChild(filter)
+	layout.AddChild(buttons)
+	layout.AddChild(rt.label)
+	layout.AddChild(rt.list)
+	rt.window = theme.CreateWindow(900, 600, rt.title())
+	rt.window.AddChild(layout)
+	rt.window.OnClose(func() {
+		if stw.table == rt {
+			stw.table = nil
+		}
+	})
+	rt.Build()
+}
+
+// selectRow selects the entity of r and brings it to the center of the canvas.
+func (rt *ResultTable) selectRow(r *ResultRow) {
+	stw := rt.stw
+	if stw.Frame != rt.frame {
+		return
+	}
+	stw.Deselect()
+	var coord []float64
+	if r.Node != nil {
+		stw.SelectNode = []*st.Node{r.Node}
+		coord = r.Node.Coord
+	} else if r.Elem != nil {
+		stw.SelectElem = []*st.Elem{r.Elem}
+		coord = r.Elem.MidPoint()
+	}
+	if coord == nil {
+		stw.Redraw()
+		return
+	}
+	view := stw.Frame.View.Copy()
+	view.Focus = []float64{coord[0], coord[1], coord[2]}
+	view.Center[0] = float64(stw.CanvasSize[0]) * 0.5
+	view.Center[1] = float64(stw.CanvasSize[1]) * 0.5
+	stw.Animate(view)
+}
+
+// Sync rebuilds the rows when the frame, the period or the results (resultgen) are changed and selects the row of the selected entity.
+func (rt *ResultTable) Sync() {
+	stw := rt.stw
+	if stw.Frame == nil {
+		return
+	}
+	if stw.Frame != rt.frame || stw.Frame.Show.Period != rt.Period || stw.resultgen != rt.gen {
+		rt.Build()
+	}
+	var item *ResultRow
+	for _, r := range rt.shown {
+		if r.Node != nil && len(stw.SelectNode) > 0 && r.Node == stw.SelectNode[0] {
+			item = r
+			break
+		}
+		if r.Elem != nil && len(stw.SelectElem) > 0 && r.Elem == stw.SelectElem[0] {
+			item = r
+			break
+		}
+	}
+	if item == nil || rt.list.Selected() == item {
+		return
+	}
+	rt.syncing = true
+	rt.list.Select(item)
+	rt.list.ScrollToItem(item)
+	rt.syncing = false
+}
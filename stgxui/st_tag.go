@@ -0,0 +1,114 @@
+package stgxui
+
+import (
+	"errors"
+	"fmt"
+	"github.com/yofu/st/stlib"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const tagdir = ".sttags"
+
+func (stw *Window) TagDir() string {
+	if stw.Frame == nil {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(stw.Frame.Path), tagdir, st.PruneExt(filepath.Base(stw.Frame.Path)))
+}
+
+func (stw *Window) TagFileName(name string) string {
+	return filepath.Join(stw.TagDir(), fmt.Sprintf("%s.inp", name))
+}
+
+func (stw *Window) TagNames() []string {
+	names := make(map[string]bool)
+	for name := range stw.taggedFrame {
+		names[name] = true
+	}
+	if stw.Frame != nil {
+		fns, err := filepath.Glob(filepath.Join(stw.TagDir(), "*.inp"))
+		if err == nil {
+			for _, fn := range fns {
+				names[st.PruneExt(filepath.Base(fn))] = true
+			}
+		}
+	}
+	rtn := make([]string, len(names))
+	i := 0
+	for name := range names {
+		rtn[i] = name
+		i++
+	}
+	sort.Strings(rtn)
+	return rtn
+}
+
+func (stw *Window) TagExists(name string) bool {
+	if _, ok := stw.taggedFrame[name]; ok {
+		return true
+	}
+	return stw.Frame != nil && st.FileExists(stw.TagFileName(name))
+}
+
+func (stw *Window) SaveTag(name string, frame *st.Frame) error {
+	if strings.ContainsAny(name, "/\\:*?\"<>| ") {
+		return errors.New(fmt.Sprintf("SaveTag: invalid tag name %s", name))
+	}
+	err := os.MkdirAll(stw.TagDir(), 0755)
+	if err != nil {
+		return err
+	}
+	path := frame.Path
+	err = frame.WriteInp(stw.TagFileName(name))
+	frame.Path = path
+	if err != nil {
+		return err
+	}
+	stw.taggedFrame[name] = frame
+	return nil
+}
+
+func (stw *Window) TaggedFrame(name string) (*st.Frame, error) {
+	if f, ok := stw.taggedFrame[name]; ok {
+		return f, nil
+	}
+	if stw.Frame == nil {
+		return nil, errors.New(fmt.Sprintf("tag %s doesn't exist", name))
+	}
+	fn := stw.TagFileName(name)
+	if !st.FileExists(fn) {
+		return nil, errors.New(fmt.Sprintf("tag %s doesn't exist", name))
+	}
+	frame := st.NewFrame()
+	err := frame.ReadInp(fn, []float64{0.0, 0.0, 0.0}, 0.0, false)
+	if err != nil {
+		return nil, err
+	}
+	frame.Path = stw.Frame.Path
+	frame.Name = stw.Frame.Name
+	frame.Home = stw.Frame.Home
+	frame.View = stw.Frame.View.Copy()
+	frame.Show = stw.Frame.Show
+	for snum := range frame.Sects {
+		if _, ok := frame.Show.Sect[snum]; !ok {
+			frame.Show.Sect[snum] = true
+		}
+	}
+	stw.taggedFrame[name] = frame
+	return frame, nil
+}
+
+func (stw *Window) DeleteTag(name string) error {
+	if !stw.TagExists(name) {
+		return errors.New(fmt.Sprintf("tag %s doesn't exist", name))
+	}
+	delete(stw.taggedFrame, name)
+	fn := stw.TagFileName(name)
+	if st.FileExists(fn) {
+		return os.Remove(fn)
+	}
+	return nil
+}
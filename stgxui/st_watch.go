@@ -0,0 +1,176 @@
+package stgxui
+
+import (
+	"errors"
+	"fmt"
+	"github.com/yofu/st/stlib"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// WatchExts are the sibling files of the frame reloaded by :watch, in the order they are read.
+// .inp comes first because Reload discards the results read before it.
+var WatchExts = []string{".inp", ".otl", ".ohx", ".ohy", ".rat2", ".lst", ".wgt", ".kjn"}
+
+var watchinterval = 2 * time.Second
+
+// Watcher polls the modification times of the sibling files of the frame in the UI thread.
+// A file is reloaded when its time has changed and stays the same for one more interval,
+// so that a file being written by an analysis is read after it is finished.
+// The watched path follows stw.Frame.Path at each poll.
+type Watcher struct {
+	sync.Mutex
+	stw      *Window
+	path     string
+	interval time.Duration
+	mtimes   map[string]time.Time
+	pending  map[string]time.Time
+	stop     chan struct{}
+}
+
+func NewWatcher(stw *Window, interval time.Duration) (*Watcher, error) {
+	if stw.Frame == nil {
+		return nil, errors.New("NewWatcher: no frame")
+	}
+	w := &Watcher{
+		stw:      stw,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+	w.SetPath(stw.Frame.Path)
+	go w.watch()
+	return w, nil
+}
+
+// SetPath changes the watched frame and takes the current files as read.
+func (w *Watcher) SetPath(path string) {
+	w.Lock()
+	defer w.Unlock()
+	w.path = path
+	w.mtimes = make(map[string]time.Time)
+	w.pending = make(map[string]time.Time)
+	for _, ext := range WatchExts {
+		fn := st.Ce(path, ext)
+		if fi, err := os.Stat(fn); err == nil {
+			w.mtimes[fn] = fi.ModTime()
+		}
+	}
+}
+
+// Touch takes fn as read, for the files written by stx itself.
+func (w *Watcher) Touch(fn string) {
+	w.Lock()
+	defer w.Unlock()
+	for _, ext := range WatchExts {
+		if st.Ce(w.path, ext) != fn {
+			continue
+		}
+		if fi, err := os.Stat(fn); err == nil {
+			w.mtimes[fn] = fi.ModTime()
+		}
+		delete(w.pending, fn)
+	}
+}
+
+func (w *Watcher) Stop() {
+	close(w.stop)
+}
+
+func (w *Watcher) String() string {
+	w.Lock()
+	defer w.Unlock()
+	return fmt.Sprintf("watch: %s (%s) every %s", filepath.Base(w.path), w.path, w.interval)
+}
+
+func (w *Watcher) watch() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.stw.driver.Call(func() {
+				w.stw.pollWatched(w)
+			})
+		}
+	}
+}
+
+// poll returns the files which have been changed and settled since the last poll.
+func (w *Watcher) poll() []string {
+	w.Lock()
+	defer w.Unlock()
+	rtn := make([]string, 0)
+	for _, ext := range WatchExts {
+		fn := st.Ce(w.path, ext)
+		fi, err := os.Stat(fn)
+		if err != nil {
+			delete(w.pending, fn)
+			continue
+		}
+		mtime := fi.ModTime()
+		if old, ok := w.mtimes[fn]; ok && old.Equal(mtime) {
+			delete(w.pending, fn)
+			continue
+		}
+		if p, ok := w.pending[fn]; ok && p.Equal(mtime) {
+			delete(w.pending, fn)
+			w.mtimes[fn] = mtime
+			rtn = append(rtn, fn)
+			continue
+		}
+		w.pending[fn] = mtime
+	}
+	return rtn
+}
+
+// pollWatched reads the changed files, preserving View and Show as Reload does.
+// The .inp is not reloaded over unsaved changes. Reloading it drops the results,
+// so the result files which exist are read again after it.
+func (stw *Window) pollWatched(w *Watcher) {
+	if stw.watcher != w || stw.Frame == nil {
+		return
+	}
+	if stw.Frame.Path != w.path {
+		w.SetPath(stw.Frame.Path)
+		return
+	}
+	fns := w.poll()
+	if len(fns) == 0 {
+		return
+	}
+	if filepath.Ext(fns[0]) == ".inp" {
+		if stw.Changed {
+			stw.History(fmt.Sprintf("WATCH: %s is changed on disk; not reloaded because of unsaved changes", fns[0]))
+			fns = fns[1:]
+		} else {
+			fns = fns[:1]
+			for _, ext := range WatchExts[1:] {
+				if fn := st.Ce(w.path, ext); st.FileExists(fn) {
+					fns = append(fns, fn)
+				}
+			}
+		}
+	}
+	v := stw.Frame.View
+	s := stw.Frame.Show
+	for _, fn := range fns {
+		if filepath.Ext(fn) == ".inp" {
+			stw.Reload()
+			stw.History(fmt.Sprintf("WATCH: reloaded %s", fn))
+			continue
+		}
+		err := stw.ReadFile(fn)
+		if err != nil {
+			stw.ErrorMessage(errors.New(fmt.Sprintf("WATCH: %s: %s", fn, err.Error())), ERROR)
+			continue
+		}
+		stw.History(fmt.Sprintf("WATCH: reloaded %s", fn))
+	}
+	stw.Frame.View = v
+	stw.Frame.Show = s
+	stw.Redraw()
+}
@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"github.com/google/gxui"
 	gxmath "github.com/google/gxui/math"
+	"github.com/yofu/abbrev"
 	"github.com/yofu/st/stlib"
 	"github.com/yofu/st/stsvg"
+	"github.com/yuin/gopher-lua"
 	"log"
 	"path/filepath"
 	"math"
@@ -16,6 +18,7 @@ import (
 	"os/exec"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -161,6 +164,10 @@ type Window struct { // {{{
 	cline   gxui.TextBox
 	history gxui.TextBox
 
+	completepopup   gxui.BubbleOverlay
+	completelist    gxui.List
+	completeadapter *gxui.DefaultAdapter
+
 	CanvasSize []int // width, height
 
 	SelectNode []*st.Node
@@ -190,13 +197,28 @@ type Window struct { // {{{
 	InpModified bool
 	Changed     bool
 
-	exmodech  chan (interface{})
-	exmodeend chan (int)
+	pipe *PipeValue
 
 	comhist     []string
 	recentfiles []string
 	undostack   []*st.Frame
 	taggedFrame map[string]*st.Frame
+	compare     *Comparison
+	jobs        *JobManager
+
+	combinations []*LoadCombination
+	envelopes    []*Envelope
+
+	table    *ResultTable
+	aiparam  *AiParameter
+	colormap *ColorMap
+
+	variables   map[string]string
+	scriptdepth int
+	lua         *lua.LState
+	server      *Server
+	watcher     *Watcher
+	resultgen   int
 }
 
 // }}}
@@ -252,11 +274,38 @@ func (stw *Window) initCommandLineArea() {
 	stw.cline.OnKeyDown(func (ev gxui.KeyboardEvent) {
 		switch ev.Key {
 		default:
-			break
+			stw.hideCompletes()
+		case gxui.KeyLeftShift, gxui.KeyRightShift, gxui.KeyLeftControl, gxui.KeyRightControl, gxui.KeyLeftAlt, gxui.KeyRightAlt:
+			return
 		case gxui.KeyEscape:
+			stw.hideCompletes()
 			stw.cline.SetText("")
 		case gxui.KeyEnter:
+			stw.hideCompletes()
 			stw.feedCommand()
+		case gxui.KeyTab:
+			if prevkey == int(gxui.KeyTab) {
+				if ev.Modifier.Shift() {
+					stw.setClineText(PrevComplete(stw.cline.Text()))
+				} else {
+					stw.setClineText(NextComplete(stw.cline.Text()))
+				}
+			} else {
+				stw.setClineText(stw.CompleteCommand(stw.cline.Text()))
+			}
+			stw.showCompletes()
+		case gxui.KeyUp:
+			stw.hideCompletes()
+			if prevkey != int(gxui.KeyUp) && prevkey != int(gxui.KeyDown) {
+				clineinput = stw.cline.Text()
+			}
+			stw.PrevCommand(clineinput)
+		case gxui.KeyDown:
+			stw.hideCompletes()
+			if prevkey != int(gxui.KeyUp) && prevkey != int(gxui.KeyDown) {
+				clineinput = stw.cline.Text()
+			}
+			stw.NextCommand(clineinput)
 		// case gxui.KeySemicolon:
 		// 	val := stw.cline.Text()
 		// 	if ev.Modifier.Shift() {
@@ -273,10 +322,25 @@ func (stw *Window) initCommandLineArea() {
 		// 		}
 		// 	}
 		}
+		prevkey = int(ev.Key)
 	})
 	stw.cline.SetDesiredWidth(800)
 }
 
+func (stw *Window) initCompletePopup() {
+	stw.completeadapter = gxui.CreateDefaultAdapter()
+	stw.completelist = stw.theme.CreateList()
+	stw.completelist.SetAdapter(stw.completeadapter)
+	stw.completelist.OnItemClicked(func (ev gxui.MouseEvent, item gxui.AdapterItem) {
+		if str, ok := item.(string); ok {
+			stw.setClineText(str)
+		}
+		stw.hideCompletes()
+		stw.dlg.SetFocus(stw.cline)
+	})
+	stw.completepopup = stw.theme.CreateBubbleOverlay()
+}
+
 func (stw *Window) initDrawAreaCallback() {
 	stw.draw.OnMouseUp(func (ev gxui.MouseEvent) {
 		if stw.Frame != nil {
@@ -363,6 +427,7 @@ func NewWindow(driver gxui.Driver, theme gxui.Theme, homedir string) *Window {
 
 	stw.initHistoryArea()
 	stw.initCommandLineArea()
+	stw.initCompletePopup()
 
 	table := theme.CreateTableLayout()
 	table.SetGrid(20, 20)
@@ -374,7 +439,16 @@ func NewWindow(driver gxui.Driver, theme gxui.Theme, homedir string) *Window {
 
 	stw.dlg = theme.CreateWindow(1200, 900, "stx")
 	stw.dlg.AddChild(table)
-	stw.dlg.OnClose(driver.Terminate)
+	stw.dlg.AddChild(stw.completepopup)
+	stw.dlg.OnClose(func() {
+		if stw.Frame != nil {
+			err := stw.SaveSession()
+			if err != nil {
+				stw.ErrorMessage(err, ERROR)
+			}
+		}
+		driver.Terminate()
+	})
 	stw.dlg.OnKeyDown(func (ev gxui.KeyboardEvent) {
 		if _, ok := stw.dlg.Focus().(gxui.TextBox); ok {
 			return
@@ -404,10 +478,13 @@ func NewWindow(driver gxui.Driver, theme gxui.Theme, homedir string) *Window {
 	stw.SetRecently()
 	stw.undostack = make([]*st.Frame, nUndo)
 	stw.taggedFrame = make(map[string]*st.Frame)
+	stw.jobs = NewJobManager(stw)
+	stw.combinations = make([]*LoadCombination, 0)
+	stw.envelopes = make([]*Envelope, 0)
+	stw.aiparam = NewAiParameter()
+	stw.variables = make(map[string]string)
 	undopos = 0
 	StartLogging()
-	stw.exmodech = make(chan interface{})
-	stw.exmodeend = make(chan int)
 
 	return stw
 }
@@ -649,6 +726,9 @@ func (stw *Window) SaveFile(fn string) error {
 	}
 	stw.ErrorMessage(errors.New(fmt.Sprintf("SAVE: %s", fn)), INFO)
 	stw.Changed = false
+	if stw.watcher != nil {
+		stw.watcher.Touch(fn)
+	}
 	return nil
 }
 
@@ -790,10 +870,18 @@ func (stw *Window) Reload() {
 func (stw *Window) OpenFile(filename string, readrcfile bool) error {
 	var err error
 	var s *st.Show
+	var oldpath string
 	fn := st.ToUtf8string(filename)
 	frame := st.NewFrame()
 	if stw.Frame != nil {
 		s = stw.Frame.Show
+		oldpath = stw.Frame.Path
+		if oldpath != fn {
+			err = stw.SaveSession()
+			if err != nil {
+				stw.ErrorMessage(err, ERROR)
+			}
+		}
 	}
 	stw.SetCanvasSize()
 	frame.View.Center[0] = float64(stw.CanvasSize[0]) * 0.5
@@ -823,6 +911,13 @@ func (stw *Window) OpenFile(filename string, readrcfile bool) error {
 			}
 		}
 	}
+	stw.compare = nil
+	stw.aiparam = AiParameterOf(stw.Frame)
+	if stw.Frame.Path != oldpath {
+		stw.taggedFrame = make(map[string]*st.Frame)
+		stw.combinations = make([]*LoadCombination, 0)
+		stw.envelopes = make([]*Envelope, 0)
+	}
 	openstr := fmt.Sprintf("OPEN: %s", fn)
 	stw.History(openstr)
 	stw.dlg.SetTitle(stw.Frame.Name)
@@ -886,6 +981,13 @@ func (stw *Window) ReadAll() {
 	}
 }
 
+// ResultsRead recomputes the combinations and rebuilds the result table after results are read without ReadFile.
+func (stw *Window) ResultsRead() {
+	stw.UpdateCombinations()
+	stw.resultgen++
+	stw.Redraw()
+}
+
 func (stw *Window) ReadFile(filename string) error {
 	var err error
 	switch filepath.Ext(filename) {
@@ -900,6 +1002,9 @@ func (stw *Window) ReadFile(filename string) error {
 		err = stw.Frame.ReadData(filename)
 	case ".otl", ".ohx", ".ohy":
 		err = stw.Frame.ReadResult(filename, st.UpdateResult)
+		if err == nil {
+			stw.UpdateCombinations()
+		}
 	case ".rat", ".rat2":
 		err = stw.Frame.ReadRat(filename)
 	case ".lst":
@@ -916,6 +1021,7 @@ func (stw *Window) ReadFile(filename string) error {
 	if err != nil {
 		return err
 	}
+	stw.resultgen++
 	return nil
 }
 
@@ -941,7 +1047,10 @@ func (stw *Window) CompleteFileName(str string) string {
 	if envval.MatchString(str) {
 		efs := envval.FindStringSubmatch(str)
 		if len(efs) >= 2 {
-			val := os.Getenv(strings.ToUpper(efs[1]))
+			val, ok := stw.variables[efs[1]]
+			if !ok {
+				val = os.Getenv(strings.ToUpper(efs[1]))
+			}
 			if val != "" {
 				str = strings.Replace(str, efs[0], val, 1)
 			}
@@ -1014,6 +1123,130 @@ func NextComplete(str string) string {
 	return completes[completepos]
 }
 
+func (stw *Window) SectCandidates(str string) []string {
+	if stw.Frame == nil {
+		return nil
+	}
+	snums := make([]int, len(stw.Frame.Sects))
+	num := 0
+	for snum := range stw.Frame.Sects {
+		if strings.HasPrefix(fmt.Sprintf("%d", snum), str) {
+			snums[num] = snum
+			num++
+		}
+	}
+	snums = snums[:num]
+	sort.Ints(snums)
+	rtn := make([]string, num)
+	for i, snum := range snums {
+		rtn[i] = fmt.Sprintf("%d", snum)
+	}
+	return rtn
+}
+
+func (stw *Window) KijunCandidates(str string) []string {
+	if stw.Frame == nil {
+		return nil
+	}
+	rtn := make([]string, 0)
+	for name := range stw.Frame.Kijuns {
+		if strings.HasPrefix(name, strings.ToLower(str)) {
+			rtn = append(rtn, name)
+		}
+	}
+	sort.Strings(rtn)
+	return rtn
+}
+
+func (stw *Window) CompleteCommand(str string) string {
+	lis := strings.Split(str, " ")
+	last := lis[len(lis)-1]
+	var cands []string
+	switch {
+	default:
+		return stw.CompleteFileName(str)
+	case strings.HasPrefix(str, ":"):
+		if len(lis) == 1 {
+			cands = exmodecandidates(last)
+			break
+		}
+		cname, _, _ := exmodecomplete(lis[0])
+		switch cname {
+		default:
+			return stw.CompleteFileName(str)
+		case "section", "section+", "nminteraction", "gohanlst", "amountlst":
+			cands = stw.SectCandidates(last)
+		case "elem", "bond":
+			if len(lis) >= 3 && strings.EqualFold(lis[len(lis)-2], "sect") {
+				cands = stw.SectCandidates(last)
+			} else {
+				return str
+			}
+		case "tag", "checkout", "diff":
+			cands = make([]string, 0)
+			for _, name := range stw.TagNames() {
+				if strings.HasPrefix(name, last) {
+					cands = append(cands, name)
+				}
+			}
+		}
+	case strings.HasPrefix(str, "'"):
+		if len(lis) == 1 {
+			cands = stw.fig2keywordcandidates(last)
+			break
+		}
+		key, _ := fig2keywordcomplete(strings.TrimPrefix(strings.ToLower(lis[0]), "'!"))
+		switch key {
+		default:
+			return str
+		case "section", "section+", "section-", "alias", "anonymous", "stress":
+			cands = stw.SectCandidates(last)
+		case "period":
+			cands = stw.PeriodCandidates(last)
+		case "measure":
+			if len(lis) >= 3 && abbrev.For("k/ijun", strings.ToLower(lis[1])) {
+				cands = stw.KijunCandidates(last)
+			} else {
+				return str
+			}
+		}
+	}
+	if len(cands) == 0 {
+		completes = make([]string, 0)
+		return str
+	}
+	completes = make([]string, len(cands))
+	for i, c := range cands {
+		lis[len(lis)-1] = c
+		completes[i] = strings.Join(lis, " ")
+	}
+	completepos = 0
+	return completes[0]
+}
+
+func (stw *Window) showCompletes() {
+	if len(completes) <= 1 {
+		stw.hideCompletes()
+		return
+	}
+	stw.completeadapter.SetItems(completes)
+	stw.completelist.Select(completes[completepos])
+	size := stw.dlg.Size()
+	stw.completepopup.Show(stw.completelist, gxmath.Point{X: size.W / 4, Y: size.H - stw.cline.Size().H})
+}
+
+func (stw *Window) hideCompletes() {
+	if stw.completepopup != nil {
+		stw.completepopup.Hide()
+	}
+}
+
+func (stw *Window) setClineText(str string) {
+	stw.cline.SetText(str)
+	l := len([]rune(str))
+	stw.cline.Select(gxui.TextSelectionList{gxui.CreateTextSelection(l, l, false)})
+}
+
 func (stw *Window) SearchFile(fn string) (string, error) {
 	if _, err := os.Stat(fn); err == nil {
 		return fn, nil
@@ -1174,6 +1407,27 @@ func EtypeFilter(str string) (func(*st.Elem) bool, string) {
 	return filterfunc, hstr
 }
 
+// onPlane reports whether el lies on a plane normal to axis.
+func onPlane(el *st.Elem, axis int) bool {
+	if el.IsLineElem() {
+		return el.Direction(false)[axis] == 0.0
+	} else {
+		n := el.Normal(false)
+		if n == nil {
+			return false
+		}
+		for i := 0; i < 3; i++ {
+			if i == axis {
+				continue
+			}
+			if n[i] != 0.0 {
+				return false
+			}
+		}
+		return true
+	}
+}
+
 func (stw *Window) FilterElem(els []*st.Elem, str string) ([]*st.Elem, error) {
 	l := len(els)
 	if els == nil || l == 0 {
@@ -1236,23 +1490,7 @@ func (stw *Window) FilterElem(els []*st.Elem, str string) ([]*st.Elem, error) {
 			axis = i
 		}
 		filterfunc = func(el *st.Elem) bool {
-			if el.IsLineElem() {
-				return el.Direction(false)[axis] == 0.0
-			} else {
-				n := el.Normal(false)
-				if n == nil {
-					return false
-				}
-				for i := 0; i < 3; i++ {
-					if i == axis {
-						continue
-					}
-					if n[i] != 0.0 {
-						return false
-					}
-				}
-				return true
-			}
+			return onPlane(el, axis)
 		}
 	case re_sectnum.MatchString(str):
 		filterfunc, hstr = SectFilter(str)
@@ -1478,8 +1716,7 @@ func (stw *Window) PrevCommand(str string) {
 			return
 		}
 		if strings.HasPrefix(stw.comhist[comhistpos], str) {
-			stw.cline.SetText(stw.comhist[comhistpos])
-			// stw.cline.SetAttribute("CARETPOS", fmt.Sprintf("%d", len(stw.comhist[comhistpos])))
+			stw.setClineText(stw.comhist[comhistpos])
 			return
 		}
 	}
@@ -1493,8 +1730,7 @@ func (stw *Window) NextCommand(str string) {
 			return
 		}
 		if strings.HasPrefix(stw.comhist[comhistpos], str) {
-			stw.cline.SetText(stw.comhist[comhistpos])
-			// stw.cline.SetAttribute("CARETPOS", fmt.Sprintf("%d", len(stw.comhist[comhistpos])))
+			stw.setClineText(stw.comhist[comhistpos])
 			return
 		}
 	}
@@ -1508,6 +1744,7 @@ func (stw *Window) ExecCommand(com *Command) {
 }
 
 func (stw *Window) execAliasCommand(al string) {
+	al = stw.ExpandVariables(al)
 	if stw.Frame == nil {
 		if strings.HasPrefix(al, ":") {
 			err := stw.exmode(al)
@@ -1648,7 +1885,7 @@ func (stw *Window) EscapeAll() {
 
 // Message
 func (stw *Window) History(str string) {
-	if str == "" {
+	if str == "" || stw.history == nil {
 		return
 	}
 	current := stw.history.Text()
@@ -2515,8 +2752,14 @@ func (stw *Window) RedrawNode() {
 }
 
 func (stw *Window) Redraw() {
+	if stw.driver == nil { // headless (Server)
+		return
+	}
 	canvas := stw.DrawFrame()
 	stw.draw.SetCanvas(canvas)
+	if stw.table != nil {
+		stw.table.Sync()
+	}
 }
 
 func (stw *Window) ShapeData(sh st.Shape) {
@@ -2529,6 +2772,10 @@ func (stw *Window) ShapeData(sh st.Shape) {
 		tb.Position = []int{stw.CanvasSize[0] - 300, 200}
 		stw.TextBox["SHAPE"] = tb
 	}
+	tb.Value = strings.Split(ShapeDataString(sh), "\n")
+}
+
+func ShapeDataString(sh st.Shape) string {
 	var otp bytes.Buffer
 	otp.WriteString(fmt.Sprintf("%s\n", sh.String()))
 	otp.WriteString(fmt.Sprintf("A   = %10.4f [cm2]\n", sh.A()))
@@ -2538,14 +2785,26 @@ func (stw *Window) ShapeData(sh st.Shape) {
 	otp.WriteString(fmt.Sprintf("Iy  = %10.4f [cm4]\n", sh.Iy()))
 	otp.WriteString(fmt.Sprintf("J   = %10.4f [cm4]\n", sh.J()))
 	otp.WriteString(fmt.Sprintf("Zx  = %10.4f [cm3]\n", sh.Zx()))
-	otp.WriteString(fmt.Sprintf("Zy  = %10.4f [cm3]\n", sh.Zy()))
-	tb.Value = strings.Split(otp.String(), "\n")
+	otp.WriteString(fmt.Sprintf("Zy  = %10.4f [cm3]", sh.Zy()))
+	return otp.String()
 }
 
 func  Vim(fn string) {
 	cmd := exec.Command("gvim", fn)
 	cmd.Start()
 }
+func  Browse(fn string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("cmd", "/C", "start", fn)
+	case "darwin":
+		cmd = exec.Command("open", fn)
+	default:
+		cmd = exec.Command("xdg-open", fn)
+	}
+	cmd.Start()
+}
 func  EditReadme(dir string) {
 	fn := filepath.Join(dir, "readme.txt")
 	Vim(fn)
@@ -2567,21 +2826,5 @@ func max(x, y int) int {
 }
 
 func (stw *Window) ReadResource(filename string) error {
-	f, err := os.Open(filename)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	s := bufio.NewScanner(f)
-	for s.Scan() {
-		txt := s.Text()
-		if strings.HasPrefix(txt, "#") {
-			continue
-		}
-		stw.execAliasCommand(txt)
-	}
-	if err := s.Err(); err != nil {
-		return err
-	}
-	return nil
+	return stw.SourceFile(filename)
 }
@@ -25,7 +25,8 @@ func appMain(driver gxui.Driver) {
 			theme.SetDefaultFont(font)
 		}
 	}
-	stgxui.NewWindow(driver, theme, HOME)
+	stw := stgxui.NewWindow(driver, theme, HOME)
+	stw.OpenLastSession()
 }
 
 func main() {